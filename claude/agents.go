@@ -0,0 +1,114 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BuiltinTools lists the tool names the CLI provides without MCP servers.
+var BuiltinTools = []string{
+	"AskUserQuestion", "Bash", "BashOutput", "Edit", "EnterPlanMode",
+	"ExitPlanMode", "Glob", "Grep", "KillShell", "LS", "MultiEdit",
+	"NotebookEdit", "NotebookRead", "Read", "Skill", "SlashCommand", "Task",
+	"TaskOutput", "TodoWrite", "WebFetch", "WebSearch", "Write",
+}
+
+// IsBuiltinTool reports whether name is one of BuiltinTools.
+func IsBuiltinTool(name string) bool {
+	for _, tool := range BuiltinTools {
+		if tool == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AgentModelInherit makes a subagent use the main conversation's model.
+const AgentModelInherit = "inherit"
+
+var agentModelAliases = map[string]bool{"sonnet": true, "opus": true, "haiku": true, AgentModelInherit: true}
+
+var agentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// AgentDefinition describes a subagent the main agent can delegate to with
+// the Task tool. Tools restricts the subagent to the listed tools; nil
+// inherits all of them. Model is an alias (sonnet, opus, haiku, inherit)
+// or a full model id; empty uses the CLI default for subagents.
+type AgentDefinition struct {
+	Description string   `json:"description"`
+	Prompt      string   `json:"prompt"`
+	Tools       []string `json:"tools,omitempty"`
+	Model       string   `json:"model,omitempty"`
+}
+
+// Validate checks the definition. Tool names must be built-in tools or
+// follow the MCP convention mcp__<server> or mcp__<server>__<tool>.
+func (d AgentDefinition) Validate() error {
+	var errs []error
+	if strings.TrimSpace(d.Description) == "" {
+		errs = append(errs, fmt.Errorf("description is empty"))
+	}
+	if strings.TrimSpace(d.Prompt) == "" {
+		errs = append(errs, fmt.Errorf("prompt is empty"))
+	}
+	for _, tool := range d.Tools {
+		if err := validateToolName(tool); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if d.Model != "" && !agentModelAliases[d.Model] && !strings.HasPrefix(d.Model, "claude-") {
+		errs = append(errs, fmt.Errorf("model %q is not an alias or claude model id", d.Model))
+	}
+	return errors.Join(errs...)
+}
+
+func validateToolName(name string) error {
+	if IsBuiltinTool(name) {
+		return nil
+	}
+	if strings.HasPrefix(name, "mcp__") {
+		if _, _, ok := ParseMCPToolName(name); ok {
+			return nil
+		}
+		server := strings.TrimPrefix(name, "mcp__")
+		if server != "" && !strings.Contains(server, "__") && !strings.ContainsAny(server, " \t\n") {
+			return nil
+		}
+		return fmt.Errorf("tool %q does not follow mcp__<server>__<tool>", name)
+	}
+	return fmt.Errorf("tool %q is not a built-in tool", name)
+}
+
+// ValidateAgents validates every definition and its name, which must be
+// lowercase letters, digits and hyphens.
+func ValidateAgents(agents map[string]AgentDefinition) error {
+	names := make([]string, 0, len(agents))
+	for name := range agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if !agentNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("agent name %q is invalid", name))
+			continue
+		}
+		if err := agents[name].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("agent %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func marshalAgents(agents map[string]AgentDefinition) (string, error) {
+	data, err := json.Marshal(agents)
+	if err != nil {
+		return "", fmt.Errorf("marshal agents: %w", err)
+	}
+	return string(data), nil
+}
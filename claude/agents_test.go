@@ -0,0 +1,62 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAgentDefinitionValidate(t *testing.T) {
+	valid := AgentDefinition{
+		Description: "Reviews diffs",
+		Prompt:      "You review code.",
+		Tools:       []string{"Read", "Grep", "mcp__github", "mcp__github__get_pr"},
+		Model:       "haiku",
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	invalid := AgentDefinition{
+		Tools: []string{"Reed", "mcp__", "mcp__a__"},
+		Model: "gpt-4",
+	}
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatalf("Validate() error = nil")
+	}
+	for _, want := range []string{"description is empty", "prompt is empty", `"Reed" is not a built-in tool`, `"mcp__"`, `"mcp__a__"`, `model "gpt-4"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("Validate() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestClientBuilderWithAgents(t *testing.T) {
+	agents := map[string]AgentDefinition{
+		"reviewer": {Description: "Reviews diffs", Prompt: "Review.", Tools: []string{"Read"}, Model: AgentModelInherit},
+	}
+	fake := newFakeCLI(sessionScript("s1", "/w", 0))
+	client, err := fake.builder().WithAgents(agents).Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	drainClient(t, client)
+
+	var got map[string]AgentDefinition
+	if err := json.Unmarshal([]byte(argValue(fake.lastArgs(), "--agents")), &got); err != nil {
+		t.Fatalf("--agents is not JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, agents) {
+		t.Fatalf("--agents = %+v, want %+v", got, agents)
+	}
+
+	_, err = NewClientBuilder().WithAgents(map[string]AgentDefinition{
+		"Bad Name": {Description: "d", Prompt: "p"},
+	}).Build(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `agent name "Bad Name"`) {
+		t.Fatalf("Build() error = %v, want invalid agent name", err)
+	}
+}
@@ -0,0 +1,284 @@
+// Package audit keeps a tamper-evident record of the tools an agent used:
+// one hash-chained JSON line per tool use with its permission decision and
+// result.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+)
+
+// DefaultSummaryLimit is the default length in bytes of Record.Result.
+const DefaultSummaryLimit = 512
+
+// Record is one audited tool use.
+type Record struct {
+	Seq             uint64          `json:"seq"`
+	Time            time.Time       `json:"time"`
+	SessionID       string          `json:"session_id,omitempty"`
+	ToolUseID       string          `json:"tool_use_id"`
+	ParentToolUseID string          `json:"parent_tool_use_id,omitempty"`
+	ToolName        string          `json:"tool_name"`
+	Input           json.RawMessage `json:"input,omitempty"`
+	// Decision is the permission callback's answer: "allow", "deny" or
+	// "error". It is empty when the CLI decided without asking.
+	Decision string `json:"decision,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// PermissionDenied is set when the permission callback denied the tool
+	// use or the turn's result lists it in permission_denials. A tool use
+	// the CLI denied without asking has already been written by the time
+	// the result arrives, so it is followed by a second record carrying
+	// only the tool use and PermissionDenied.
+	PermissionDenied bool `json:"permission_denied,omitempty"`
+	// Completed is set once the tool result was seen.
+	Completed  bool   `json:"completed"`
+	Result     string `json:"result,omitempty"`
+	IsError    bool   `json:"is_error,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	// PrevHash is the Hash of the previous record, or empty for the first.
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+type decision struct {
+	decision string
+	reason   string
+}
+
+// Logger builds records from a client's messages and permission callback
+// and writes them to w. Each record is written when its tool result
+// arrives; tool uses still without a result are written with the turn's
+// result message or by Flush.
+type Logger struct {
+	mu           sync.Mutex
+	w            io.Writer
+	now          func() time.Time
+	summaryLimit int
+
+	seq       uint64
+	prevHash  string
+	sessionID string
+	pending   []*Record
+	byToolUse map[string]*Record
+	decisions map[string]decision
+	// written holds the records written during the current turn, so the
+	// result's permission denials can be matched against them.
+	written map[string]*Record
+}
+
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{
+		w:            w,
+		now:          time.Now,
+		summaryLimit: DefaultSummaryLimit,
+		byToolUse:    map[string]*Record{},
+		decisions:    map[string]decision{},
+		written:      map[string]*Record{},
+	}
+}
+
+// Continue chains new records after last, typically the record returned
+// by Verify on the existing log.
+func (l *Logger) Continue(last *Record) *Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if last != nil {
+		l.seq = last.Seq
+		l.prevHash = last.Hash
+	}
+	return l
+}
+
+// WithSummaryLimit caps Record.Result at n bytes; 0 or less omits results.
+func (l *Logger) WithSummaryLimit(n int) *Logger {
+	l.summaryLimit = n
+	return l
+}
+
+// Observe records tool uses from msg. It is meant for
+// claude.ClientBuilder.WithObserver.
+func (l *Logger) Observe(ctx context.Context, msg claude.Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *claude.SystemInitMessage:
+		if m.SessionID != "" {
+			l.sessionID = m.SessionID
+		}
+	case *claude.AssistantMessage:
+		if m.SessionID != "" {
+			l.sessionID = m.SessionID
+		}
+		for _, block := range m.Message.Content {
+			if block.ToolUse != nil {
+				l.startRecord(block.ToolUse, m.ParentToolUseID)
+			}
+		}
+	case *claude.UserMessage:
+		var results []*claude.ToolResultContentBlock
+		for _, block := range m.Message.Content {
+			if block.ToolResult != nil {
+				results = append(results, block.ToolResult)
+			}
+		}
+		for _, result := range results {
+			rec, ok := l.byToolUse[result.ToolUseID]
+			if !ok {
+				continue
+			}
+			rec.Completed = true
+			rec.IsError = result.IsError
+			rec.Result = l.summarize(result.Text())
+			// tool_use_result describes the message's only tool result.
+			if m.ToolUseResult != nil && len(results) == 1 {
+				rec.DurationMS = m.ToolUseResult.DurationMS
+			}
+			if rec.Decision == string(claude.PermissionDecisionDeny) {
+				rec.PermissionDenied = true
+			}
+		}
+		return l.writeCompletedLocked()
+	case *claude.ResultMessage:
+		for _, denial := range m.PermissionDenials {
+			rec, ok := l.byToolUse[denial.ToolUseID]
+			if !ok {
+				if done, ok := l.written[denial.ToolUseID]; ok && done.PermissionDenied {
+					continue
+				}
+				rec = l.startRecord(&claude.ToolUseContentBlock{ID: denial.ToolUseID, Name: denial.ToolName, Input: denial.ToolInput}, nil)
+			}
+			rec.PermissionDenied = true
+		}
+		// Decisions whose tool use never showed up will not be claimed.
+		clear(l.decisions)
+		if err := l.flushLocked(); err != nil {
+			return err
+		}
+		clear(l.written)
+	}
+	return nil
+}
+
+func (l *Logger) startRecord(use *claude.ToolUseContentBlock, parentToolUseID *string) *Record {
+	if rec, ok := l.byToolUse[use.ID]; ok {
+		return rec
+	}
+	rec := &Record{
+		Time:      l.now().UTC(),
+		SessionID: l.sessionID,
+		ToolUseID: use.ID,
+		ToolName:  use.Name,
+		Input:     use.Input,
+	}
+	if parentToolUseID != nil {
+		rec.ParentToolUseID = *parentToolUseID
+	}
+	if d, ok := l.decisions[use.ID]; ok {
+		rec.Decision, rec.Reason = d.decision, d.reason
+		delete(l.decisions, use.ID)
+	}
+	l.pending = append(l.pending, rec)
+	l.byToolUse[use.ID] = rec
+	return rec
+}
+
+func (l *Logger) summarize(text string) string {
+	if l.summaryLimit <= 0 {
+		return ""
+	}
+	if len(text) <= l.summaryLimit {
+		return text
+	}
+	cut := l.summaryLimit
+	// Back off to a rune boundary.
+	for cut > 0 && text[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return text[:cut] + "…"
+}
+
+// CanUseTool wraps next so its decisions are recorded with the tool use.
+// Pass the result to claude.ClientBuilder.WithCanUseTool.
+func (l *Logger) CanUseTool(next claude.CanUseToolFunc) claude.CanUseToolFunc {
+	return func(ctx context.Context, req claude.ToolPermissionRequest) (claude.PermissionResult, error) {
+		result, err := next(ctx, req)
+		d := decision{decision: string(result.Decision), reason: result.Message}
+		if err != nil {
+			d = decision{decision: "error", reason: err.Error()}
+		}
+		l.mu.Lock()
+		if rec, ok := l.byToolUse[req.ToolUseID]; ok {
+			rec.Decision, rec.Reason = d.decision, d.reason
+		} else {
+			l.decisions[req.ToolUseID] = d
+		}
+		l.mu.Unlock()
+		return result, err
+	}
+}
+
+// Flush writes the records still waiting for a result message, such as
+// those of an interrupted turn.
+func (l *Logger) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flushLocked()
+}
+
+func (l *Logger) flushLocked() error {
+	return l.writePendingLocked(func(*Record) bool { return true })
+}
+
+// writeCompletedLocked writes the pending records whose tool result was
+// seen.
+func (l *Logger) writeCompletedLocked() error {
+	return l.writePendingLocked(func(rec *Record) bool { return rec.Completed })
+}
+
+func (l *Logger) writePendingLocked(ready func(*Record) bool) error {
+	kept := l.pending[:0]
+	var err error
+	for _, rec := range l.pending {
+		if err != nil || !ready(rec) {
+			kept = append(kept, rec)
+			continue
+		}
+		if err = l.write(rec); err != nil {
+			// Keep the unwritten record for the next attempt.
+			kept = append(kept, rec)
+			continue
+		}
+		delete(l.byToolUse, rec.ToolUseID)
+		l.written[rec.ToolUseID] = rec
+	}
+	clear(l.pending[len(kept):])
+	l.pending = kept
+	return err
+}
+
+func (l *Logger) write(rec *Record) error {
+	rec.Seq = l.seq + 1
+	rec.PrevHash = l.prevHash
+	hash, err := recordHash(rec)
+	if err != nil {
+		return err
+	}
+	rec.Hash = hash
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal audit record: %w", err)
+	}
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write audit record: %w", err)
+	}
+	l.seq = rec.Seq
+	l.prevHash = rec.Hash
+	return nil
+}
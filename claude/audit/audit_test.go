@@ -0,0 +1,215 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+const turn = `{"type":"system","subtype":"init","session_id":"s1"}
+{"type":"assistant","session_id":"s1","message":{"content":[{"type":"tool_use","id":"tu1","name":"Bash","input":{"command":"ls"}},{"type":"tool_use","id":"tu2","name":"Bash","input":{"command":"curl x"}}]}}
+{"type":"user","session_id":"s1","message":{"content":[{"type":"tool_result","tool_use_id":"tu1","content":"a.go\nb.go"}]},"tool_use_result":{"stdout":"a.go\nb.go","durationMs":42}}
+{"type":"user","session_id":"s1","message":{"content":[{"type":"tool_result","tool_use_id":"tu2","content":"denied","is_error":true}]}}
+{"type":"result","subtype":"success","session_id":"s1","permission_denials":[{"tool_name":"Bash","tool_use_id":"tu2","tool_input":{"command":"curl x"}},{"tool_name":"WebFetch","tool_use_id":"tu3","tool_input":{"url":"https://x.test"}}]}
+`
+
+func observeTurn(t *testing.T, l *Logger) {
+	t.Helper()
+	ctx := context.Background()
+	canUseTool := l.CanUseTool(func(ctx context.Context, req claude.ToolPermissionRequest) (claude.PermissionResult, error) {
+		if strings.Contains(string(req.Input), "curl") {
+			return claude.PermissionResult{Decision: claude.PermissionDecisionDeny, Message: "no curl"}, nil
+		}
+		return claude.PermissionResult{Decision: claude.PermissionDecisionAllow}, nil
+	})
+	parser := claude.NewMessageParser(strings.NewReader(turn))
+	for i := 0; ; i++ {
+		msg, err := parser.Next()
+		if clerrors.IsEOF(err) {
+			return
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if err := l.Observe(ctx, msg); err != nil {
+			t.Fatalf("Observe() error = %v", err)
+		}
+		if i == 1 {
+			for _, req := range []claude.ToolPermissionRequest{
+				{ToolName: "Bash", ToolUseID: "tu1", Input: json.RawMessage(`{"command":"ls"}`)},
+				{ToolName: "Bash", ToolUseID: "tu2", Input: json.RawMessage(`{"command":"curl x"}`)},
+			} {
+				if _, err := canUseTool(ctx, req); err != nil {
+					t.Fatalf("CanUseTool() error = %v", err)
+				}
+			}
+		}
+	}
+}
+
+func TestLoggerRecordsToolUses(t *testing.T) {
+	var out bytes.Buffer
+	l := NewLogger(&out)
+	l.now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	observeTurn(t, l)
+
+	var records []Record
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 3 {
+		t.Fatalf("records = %d, want 3:\n%s", len(records), out.String())
+	}
+	ls, curl, fetch := records[0], records[1], records[2]
+	if ls.SessionID != "s1" || ls.Decision != "allow" || ls.Result != "a.go\nb.go" || ls.DurationMS != 42 || !ls.Completed || ls.PermissionDenied {
+		t.Fatalf("ls record = %+v", ls)
+	}
+	if curl.Decision != "deny" || curl.Reason != "no curl" || !curl.IsError || !curl.PermissionDenied {
+		t.Fatalf("curl record = %+v", curl)
+	}
+	if fetch.ToolName != "WebFetch" || fetch.Completed || !fetch.PermissionDenied || string(fetch.Input) != `{"url":"https://x.test"}` {
+		t.Fatalf("fetch record = %+v", fetch)
+	}
+	if ls.Seq != 1 || curl.PrevHash != ls.Hash || fetch.PrevHash != curl.Hash {
+		t.Fatalf("records are not chained: %+v", records)
+	}
+}
+
+func TestLoggerWritesOnToolResult(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	l := NewLogger(&out)
+	canUseTool := l.CanUseTool(func(ctx context.Context, req claude.ToolPermissionRequest) (claude.PermissionResult, error) {
+		return claude.PermissionResult{Decision: claude.PermissionDecisionAllow}, nil
+	})
+	observe := func(line string) {
+		t.Helper()
+		msg, err := claude.NewMessageParser(strings.NewReader("")).ParseLine([]byte(line))
+		if err != nil {
+			t.Fatalf("ParseLine() error = %v", err)
+		}
+		if err := l.Observe(ctx, msg); err != nil {
+			t.Fatalf("Observe() error = %v", err)
+		}
+	}
+
+	// A decision for a tool use that never shows up is dropped at the
+	// result.
+	if _, err := canUseTool(ctx, claude.ToolPermissionRequest{ToolName: "Bash", ToolUseID: "ghost"}); err != nil {
+		t.Fatalf("CanUseTool() error = %v", err)
+	}
+	observe(`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"tu1","name":"Bash","input":{"command":"rm x"}}]}}`)
+	if out.Len() != 0 {
+		t.Fatalf("record written before its tool result:\n%s", out.String())
+	}
+	observe(`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"tu1","content":"denied","is_error":true}]}}`)
+	if n := strings.Count(out.String(), "\n"); n != 1 {
+		t.Fatalf("records after tool result = %d, want 1:\n%s", n, out.String())
+	}
+	observe(`{"type":"result","subtype":"success","permission_denials":[{"tool_name":"Bash","tool_use_id":"tu1","tool_input":{"command":"rm x"}}]}`)
+	if len(l.decisions) != 0 || len(l.pending) != 0 || len(l.byToolUse) != 0 || len(l.written) != 0 {
+		t.Fatalf("state kept after result: decisions %v, pending %v, byToolUse %v, written %v", l.decisions, l.pending, l.byToolUse, l.written)
+	}
+
+	// The CLI denied tu1 without asking, so the denial follows as its own
+	// record.
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("records = %d, want 2:\n%s", len(lines), out.String())
+	}
+	var first, second Record
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !first.Completed || first.PermissionDenied || first.Decision != "" {
+		t.Fatalf("first record = %+v", first)
+	}
+	if second.ToolUseID != "tu1" || second.Completed || !second.PermissionDenied || second.PrevHash != first.Hash {
+		t.Fatalf("second record = %+v", second)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	var out bytes.Buffer
+	observeTurn(t, NewLogger(&out))
+
+	last, err := Verify(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if last == nil || last.Seq != 3 {
+		t.Fatalf("Verify() last = %+v, want seq 3", last)
+	}
+
+	// A resumed logger continues the chain.
+	resumed := bytes.NewBuffer(nil)
+	observeTurn(t, NewLogger(resumed).Continue(last))
+	if last, err := Verify(bytes.NewReader(out.Bytes()), resumed); err != nil || last.Seq != 6 {
+		t.Fatalf("Verify(resumed) = %+v, %v, want seq 6", last, err)
+	}
+
+	tampered := strings.Replace(out.String(), `"command":"curl x"`, `"command":"true"`, 1)
+	if _, err := Verify(strings.NewReader(tampered)); !errors.Is(err, clerrors.ErrAuditChainBroken) {
+		t.Fatalf("Verify(tampered) error = %v, want ErrAuditChainBroken", err)
+	}
+	lines := strings.SplitAfter(out.String(), "\n")
+	removed := lines[0] + lines[2]
+	if _, err := Verify(strings.NewReader(removed)); !errors.Is(err, clerrors.ErrAuditChainBroken) {
+		t.Fatalf("Verify(removed) error = %v, want ErrAuditChainBroken", err)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	f, err := OpenRotatingFile(path, 1500, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	l := NewLogger(f)
+	for i := 0; i < 3; i++ {
+		observeTurn(t, l)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	files := f.Files()
+	if len(files) != 3 || files[0] != path+".2" || files[2] != path {
+		t.Fatalf("Files() = %v, want two backups and the current file", files)
+	}
+	var readers []*os.File
+	for _, name := range files {
+		r, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		defer r.Close()
+		if info, _ := r.Stat(); info.Size() > 1500 {
+			t.Fatalf("%s is %d bytes, want at most 1500", name, info.Size())
+		}
+		readers = append(readers, r)
+	}
+	last, err := Verify(readers[0], readers[1], readers[2])
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if last.Seq != 9 {
+		t.Fatalf("last seq = %d, want 9", last.Seq)
+	}
+}
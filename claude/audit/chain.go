@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// recordHash is the SHA-256 of the record's JSON with Hash empty. PrevHash
+// is part of the hashed JSON, which chains each record to the one before.
+func recordHash(rec *Record) (string, error) {
+	unsigned := *rec
+	unsigned.Hash = ""
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", fmt.Errorf("marshal audit record: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Verify checks the hash chain across readers, read in order (oldest
+// rotated file first), and returns the last record, or nil if there are
+// none. A modified, removed or reordered record yields an error wrapping
+// ErrAuditChainBroken.
+func Verify(readers ...io.Reader) (*Record, error) {
+	var last *Record
+	for _, r := range readers {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var rec Record
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				return last, fmt.Errorf("%w: decode record after seq %d: %v", clerrors.ErrAuditChainBroken, lastSeq(last), err)
+			}
+			if last != nil && (rec.PrevHash != last.Hash || rec.Seq != last.Seq+1) {
+				return last, fmt.Errorf("%w: seq %d does not follow seq %d", clerrors.ErrAuditChainBroken, rec.Seq, last.Seq)
+			}
+			hash, err := recordHash(&rec)
+			if err != nil {
+				return last, err
+			}
+			if hash != rec.Hash {
+				return last, fmt.Errorf("%w: seq %d hash mismatch", clerrors.ErrAuditChainBroken, rec.Seq)
+			}
+			last = &rec
+		}
+		if err := scanner.Err(); err != nil {
+			return last, fmt.Errorf("read audit log: %w", err)
+		}
+	}
+	return last, nil
+}
+
+func lastSeq(rec *Record) uint64 {
+	if rec == nil {
+		return 0
+	}
+	return rec.Seq
+}
@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an append-only file that is rotated to path.1, path.2,
+// ... once it would grow past maxBytes. Each write is synced to disk.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+// OpenRotatingFile opens path for appending. maxBytes of 0 disables
+// rotation; at most maxBackups rotated files are kept.
+func OpenRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("stat audit file: %w", err)
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would not fit. A single write is
+// never split across files.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, r.f.Sync()
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return fmt.Errorf("close audit file: %w", err)
+	}
+	r.f = nil
+	if r.maxBackups <= 0 {
+		if err := os.Remove(r.path); err != nil {
+			return fmt.Errorf("rotate audit file: %w", err)
+		}
+		return r.open()
+	}
+	_ = os.Remove(r.backup(r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(r.backup(i), r.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotate audit file: %w", err)
+		}
+	}
+	if err := os.Rename(r.path, r.backup(1)); err != nil {
+		return fmt.Errorf("rotate audit file: %w", err)
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
+
+// Files returns the existing log files oldest first, ending with the
+// current one, in the order Verify expects them.
+func (r *RotatingFile) Files() []string {
+	var files []string
+	for i := r.maxBackups; i >= 1; i-- {
+		if _, err := os.Stat(r.backup(i)); err == nil {
+			files = append(files, r.backup(i))
+		}
+	}
+	return append(files, r.path)
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,220 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+type BudgetScope string
+
+const (
+	BudgetScopeTurn    BudgetScope = "turn"
+	BudgetScopeSession BudgetScope = "session"
+	BudgetScopeTenant  BudgetScope = "tenant"
+)
+
+// BudgetLimits configures client-side spend enforcement. Zero limits are
+// not enforced. Spend is estimated from streaming usage with Prices (nil
+// uses DefaultPriceTable) until the turn's result reports the actual cost.
+type BudgetLimits struct {
+	TurnUSD    float64
+	SessionUSD float64
+	Tenant     *TenantBudget
+	Prices     PriceTable
+}
+
+// BudgetError is returned by Client.NextMessage in place of the message
+// that pushed spend over a limit, after the turn has been interrupted, and
+// by SendUserInput when a session or tenant budget is already spent. It
+// matches clerrors.ErrBudgetExceeded.
+type BudgetError struct {
+	Scope     BudgetScope
+	Tenant    string
+	SessionID string
+	LimitUSD  float64
+	SpentUSD  float64
+	Message   Message
+}
+
+func (e *BudgetError) Error() string {
+	scope := string(e.Scope)
+	if e.Tenant != "" {
+		scope += " " + e.Tenant
+	}
+	return fmt.Sprintf("%s budget exceeded: spent $%.4f of $%.4f", scope, e.SpentUSD, e.LimitUSD)
+}
+
+func (e *BudgetError) Unwrap() error {
+	return clerrors.ErrBudgetExceeded
+}
+
+// TenantBudget is a spend limit shared by every client built with it.
+// In-flight turns count toward it with their running estimate.
+type TenantBudget struct {
+	name     string
+	limitUSD float64
+
+	mu       sync.Mutex
+	spent    float64
+	inflight map[*budgetEnforcer]float64
+}
+
+func NewTenantBudget(name string, limitUSD float64) *TenantBudget {
+	return &TenantBudget{name: name, limitUSD: limitUSD, inflight: map[*budgetEnforcer]float64{}}
+}
+
+func (t *TenantBudget) Name() string {
+	return t.name
+}
+
+func (t *TenantBudget) LimitUSD() float64 {
+	return t.limitUSD
+}
+
+// SpentUSD returns completed spend plus the running estimate of turns in
+// progress.
+func (t *TenantBudget) SpentUSD() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.spentLocked()
+}
+
+func (t *TenantBudget) spentLocked() float64 {
+	total := t.spent
+	for _, v := range t.inflight {
+		total += v
+	}
+	return total
+}
+
+func (t *TenantBudget) update(e *budgetEnforcer, inflight float64) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inflight[e] = inflight
+	return t.spentLocked()
+}
+
+func (t *TenantBudget) commit(e *budgetEnforcer, cost float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.inflight, e)
+	t.spent += cost
+}
+
+// settle commits e's running estimate as spent, for turns that end without
+// a result, and returns it.
+func (t *TenantBudget) settle(e *budgetEnforcer) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v := t.inflight[e]
+	delete(t.inflight, e)
+	t.spent += v
+	return v
+}
+
+// budgetEnforcer watches one client. Session spend covers the turns seen on
+// that client; resumed sessions start again from zero.
+type budgetEnforcer struct {
+	limits BudgetLimits
+	client *Client
+	costs  *CostTracker
+	stream *costStream
+
+	mu      sync.Mutex
+	tripped bool
+	// settled is the tenant spend already committed for the current turn
+	// from its estimate, netted out when the turn's result arrives.
+	settled float64
+}
+
+func newBudgetEnforcer(limits BudgetLimits) *budgetEnforcer {
+	costs := NewCostTracker(limits.Prices)
+	return &budgetEnforcer{limits: limits, costs: costs, stream: costs.newStream(nil)}
+}
+
+func (e *budgetEnforcer) beforeInput(ctx context.Context, input UserInput) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tripped = false
+	e.settleTenant()
+	e.settled = 0
+	if err := e.check(nil, false); err != nil {
+		e.tripped = true
+		return err
+	}
+	return nil
+}
+
+func (e *budgetEnforcer) observe(ctx context.Context, msg Message) error {
+	e.stream.observe(msg)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := msg.(*ResultMessage); ok {
+		turns := e.costs.Turns()
+		if e.limits.Tenant != nil && len(turns) > 0 {
+			e.limits.Tenant.commit(e, turns[len(turns)-1].Total().CostUSD-e.settled)
+		}
+		e.settled = 0
+		e.tripped = false
+		return nil
+	}
+	if e.tripped {
+		return nil
+	}
+	if err := e.check(msg, true); err != nil {
+		e.tripped = true
+		// Without streaming input the interrupt is a SIGINT and no result
+		// follows, so the estimate is all the tenant will ever see.
+		e.settleTenant()
+		if interruptErr := e.client.Interrupt(ctx); interruptErr != nil {
+			return fmt.Errorf("%w (interrupt failed: %v)", err, interruptErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// close settles the running estimate when the client goes away mid-turn.
+func (e *budgetEnforcer) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settleTenant()
+}
+
+func (e *budgetEnforcer) settleTenant() {
+	if e.limits.Tenant != nil {
+		e.settled += e.limits.Tenant.settle(e)
+	}
+}
+
+// check compares current spend with every limit. The turn limit only
+// applies mid-turn; before a turn only the accumulated budgets are checked.
+func (e *budgetEnforcer) check(msg Message, inTurn bool) error {
+	var turnSpend float64
+	var sessionID string
+	if inTurn {
+		if turn, ok := e.costs.runningTurn(e.stream); ok {
+			turnSpend = turn.Total().CostUSD
+			sessionID = turn.SessionID
+		}
+	}
+	sessionSpend := e.costs.Total().CostUSD
+
+	if inTurn && e.limits.TurnUSD > 0 && turnSpend > e.limits.TurnUSD {
+		return &BudgetError{Scope: BudgetScopeTurn, SessionID: sessionID, LimitUSD: e.limits.TurnUSD, SpentUSD: turnSpend, Message: msg}
+	}
+	if e.limits.SessionUSD > 0 && sessionSpend >= e.limits.SessionUSD && (!inTurn || sessionSpend > e.limits.SessionUSD) {
+		return &BudgetError{Scope: BudgetScopeSession, SessionID: sessionID, LimitUSD: e.limits.SessionUSD, SpentUSD: sessionSpend, Message: msg}
+	}
+	if tenant := e.limits.Tenant; tenant != nil {
+		spent := tenant.update(e, turnSpend)
+		if tenant.limitUSD > 0 && spent >= tenant.limitUSD && (!inTurn || spent > tenant.limitUSD) {
+			return &BudgetError{Scope: BudgetScopeTenant, Tenant: tenant.name, SessionID: sessionID, LimitUSD: tenant.limitUSD, SpentUSD: spent, Message: msg}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+func TestBudgetInterruptsTurn(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	script := `cat >/dev/null
+echo '{"type":"system","subtype":"init","session_id":"s1","model":"claude-sonnet-4-5"}'
+echo '{"type":"assistant","session_id":"s1","message":{"id":"m1","model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":100000}}}'
+echo '{"type":"stream_event","session_id":"s1","event":{"type":"message_start","message":{"id":"m2","model":"claude-sonnet-4-5","usage":{"input_tokens":100000}}}}'
+echo '{"type":"stream_event","session_id":"s1","event":{"type":"message_delta","delta":{},"usage":{"output_tokens":100000}}}'
+exec sleep 30
+`
+	client, err := newFakeCLI(script).builder().
+		WithBudget(BudgetLimits{TurnUSD: 1}).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if err := client.SendUserInput(ctx, UserInput{Prompt: "hi"}); err != nil {
+		t.Fatalf("SendUserInput() error = %v", err)
+	}
+	_ = client.stdin.Close()
+
+	var budgetErr *BudgetError
+	for {
+		msg, err := client.NextMessage(ctx)
+		if errors.As(err, &budgetErr) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextMessage() error = %v, want budget error", err)
+		}
+		if ev, ok := msg.(*StreamEventMessage); ok && ev.Event.MessageDelta != nil {
+			t.Fatalf("message_delta delivered past the budget")
+		}
+	}
+	if !errors.Is(budgetErr, clerrors.ErrBudgetExceeded) {
+		t.Fatalf("errors.Is(ErrBudgetExceeded) = false")
+	}
+	if budgetErr.Scope != BudgetScopeTurn || budgetErr.SessionID != "s1" || !approxEqual(budgetErr.SpentUSD, 0.3+0.3+1.5) {
+		t.Fatalf("budget error = %+v", budgetErr)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- client.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("process not interrupted")
+	}
+}
+
+func TestTenantBudgetSharedAcrossClients(t *testing.T) {
+	ctx := context.Background()
+	tenant := NewTenantBudget("acme", 2)
+
+	first, err := newFakeCLI(sessionScript("s1", "/w", 3)).builder().
+		WithBudget(BudgetLimits{Tenant: tenant}).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	drainClient(t, first)
+	if !approxEqual(tenant.SpentUSD(), 3) {
+		t.Fatalf("SpentUSD() = %v, want 3", tenant.SpentUSD())
+	}
+
+	fake := newFakeCLI(sessionScript("s2", "/w", 1))
+	second, err := fake.builder().WithBudget(BudgetLimits{Tenant: tenant}).Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer func() { _ = second.Close() }()
+	err = second.SendUserInput(ctx, UserInput{Prompt: "hi"})
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Scope != BudgetScopeTenant || budgetErr.Tenant != "acme" {
+		t.Fatalf("SendUserInput() error = %v, want tenant budget error", err)
+	}
+}
+
+func TestTenantBudgetKeepsSpendOfInterruptedTurn(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	script := `cat >/dev/null
+echo '{"type":"system","subtype":"init","session_id":"s1","model":"claude-sonnet-4-5"}'
+echo '{"type":"assistant","session_id":"s1","message":{"id":"m1","model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":100000}}}'
+echo '{"type":"stream_event","session_id":"s1","event":{"type":"message_start","message":{"id":"m2","model":"claude-sonnet-4-5","usage":{"input_tokens":100000}}}}'
+exec sleep 30
+`
+	tenant := NewTenantBudget("acme", 0.5)
+	client, err := newFakeCLI(script).builder().
+		WithBudget(BudgetLimits{Tenant: tenant}).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if err := client.SendUserInput(ctx, UserInput{Prompt: "hi"}); err != nil {
+		t.Fatalf("SendUserInput() error = %v", err)
+	}
+	_ = client.stdin.Close()
+
+	var budgetErr *BudgetError
+	for {
+		_, err := client.NextMessage(ctx)
+		if errors.As(err, &budgetErr) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextMessage() error = %v, want budget error", err)
+		}
+	}
+	if budgetErr.Scope != BudgetScopeTenant {
+		t.Fatalf("budget error = %+v, want tenant scope", budgetErr)
+	}
+	if !approxEqual(tenant.SpentUSD(), 0.6) {
+		t.Fatalf("SpentUSD() after trip = %v, want 0.6", tenant.SpentUSD())
+	}
+
+	err = client.SendUserInput(ctx, UserInput{Prompt: "again"})
+	if !errors.As(err, &budgetErr) || !approxEqual(budgetErr.SpentUSD, 0.6) {
+		t.Fatalf("SendUserInput() error = %v, want tenant budget error at 0.6", err)
+	}
+	_ = client.Close()
+	if !approxEqual(tenant.SpentUSD(), 0.6) {
+		t.Fatalf("SpentUSD() after Close = %v, want 0.6", tenant.SpentUSD())
+	}
+}
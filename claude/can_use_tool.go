@@ -0,0 +1,83 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CanUseToolFunc decides a can_use_tool control request, the CLI asking
+// whether a tool call may run. Returning an error answers the request with
+// a control error.
+type CanUseToolFunc func(ctx context.Context, req ToolPermissionRequest) (PermissionResult, error)
+
+// ToolPermissionRequest is a can_use_tool control request.
+type ToolPermissionRequest struct {
+	RequestID   string
+	ToolName    string
+	ToolUseID   string
+	Input       json.RawMessage
+	BlockedPath string
+	Suggestions json.RawMessage
+}
+
+// PermissionResult answers a ToolPermissionRequest.
+type PermissionResult struct {
+	Decision PermissionDecision
+	// UpdatedInput replaces the tool input on allow; nil keeps the
+	// original.
+	UpdatedInput json.RawMessage
+	// Message tells the model why the call was denied.
+	Message string
+	// Interrupt stops the turn on deny.
+	Interrupt bool
+}
+
+type permissionResponse struct {
+	Behavior     PermissionDecision `json:"behavior"`
+	UpdatedInput json.RawMessage    `json:"updatedInput,omitempty"`
+	Message      string             `json:"message,omitempty"`
+	Interrupt    bool               `json:"interrupt,omitempty"`
+}
+
+// answerCanUseTool runs the CanUseToolFunc for msg and writes its
+// control_response.
+func (c *Client) answerCanUseTool(ctx context.Context, msg *ControlRequestMessage) error {
+	req := ToolPermissionRequest{
+		RequestID:   msg.RequestID,
+		ToolName:    msg.Request.ToolName,
+		ToolUseID:   msg.Request.ToolUseID,
+		Input:       msg.Request.Input,
+		BlockedPath: msg.Request.BlockedPath,
+		Suggestions: msg.Request.PermissionSuggestions,
+	}
+	resp := ControlResponse{Subtype: ControlResponseSuccess, RequestID: msg.RequestID}
+	result, err := c.canUseTool(ctx, req)
+	if err == nil {
+		resp.Response, err = marshalPermissionResult(req, result)
+	}
+	if err != nil {
+		resp = ControlResponse{Subtype: ControlResponseError, RequestID: msg.RequestID, Error: err.Error()}
+	}
+	return c.sendControlResponse(ctx, resp)
+}
+
+func marshalPermissionResult(req ToolPermissionRequest, result PermissionResult) (json.RawMessage, error) {
+	body := permissionResponse{Behavior: result.Decision}
+	switch result.Decision {
+	case PermissionDecisionAllow:
+		body.UpdatedInput = result.UpdatedInput
+		if body.UpdatedInput == nil {
+			body.UpdatedInput = req.Input
+		}
+		if body.UpdatedInput == nil {
+			body.UpdatedInput = json.RawMessage("{}")
+		}
+	case PermissionDecisionDeny:
+		body.Message = result.Message
+		body.Interrupt = result.Interrupt
+	default:
+		return nil, fmt.Errorf("unsupported permission decision: %s", result.Decision)
+	}
+	return json.Marshal(body)
+}
@@ -0,0 +1,95 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientCanUseTool(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out := filepath.Join(t.TempDir(), "responses")
+	script := `echo '{"type":"control_request","request_id":"perm-1","request":{"subtype":"can_use_tool","tool_name":"Bash","tool_use_id":"tu1","input":{"command":"ls"}}}'
+read line
+printf '%s\n' "$line" >> "$OUT"
+echo '{"type":"control_request","request_id":"perm-2","request":{"subtype":"can_use_tool","tool_name":"Bash","tool_use_id":"tu2","input":{"command":"rm -rf /"}}}'
+read line
+printf '%s\n' "$line" >> "$OUT"
+echo '{"type":"result","subtype":"success","result":"ok"}'
+cat >/dev/null
+`
+	fake := newFakeCLI(script)
+	var seen []string
+	client, err := fake.builder().
+		WithEnv("OUT", out).
+		WithStreamingInput(true).
+		WithCanUseTool(func(ctx context.Context, req ToolPermissionRequest) (PermissionResult, error) {
+			seen = append(seen, req.ToolUseID)
+			if strings.Contains(string(req.Input), "rm -rf") {
+				return PermissionResult{Decision: PermissionDecisionDeny, Message: "destructive"}, nil
+			}
+			return PermissionResult{Decision: PermissionDecisionAllow}, nil
+		}).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if got := argValue(fake.lastArgs(), "--permission-prompt-tool"); got != "stdio" {
+		t.Fatalf("--permission-prompt-tool = %q, want stdio", got)
+	}
+
+	for {
+		msg, err := client.NextMessage(ctx)
+		if err != nil {
+			t.Fatalf("NextMessage() error = %v", err)
+		}
+		if _, ok := msg.(*ResultMessage); ok {
+			break
+		}
+	}
+	if strings.Join(seen, ",") != "tu1,tu2" {
+		t.Fatalf("callback saw %v, want tu1,tu2", seen)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("responses = %q, want 2 lines", data)
+	}
+	var allow, deny ControlResponseMessage
+	if err := json.Unmarshal([]byte(lines[0]), &allow); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &deny); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if allow.Response.RequestID != "perm-1" || string(allow.Response.Response) != `{"behavior":"allow","updatedInput":{"command":"ls"}}` {
+		t.Fatalf("allow response = %+v (%s)", allow.Response, allow.Response.Response)
+	}
+	if deny.Response.RequestID != "perm-2" || string(deny.Response.Response) != `{"behavior":"deny","message":"destructive"}` {
+		t.Fatalf("deny response = %+v (%s)", deny.Response, deny.Response.Response)
+	}
+}
+
+func TestClientBuilderCanUseToolValidation(t *testing.T) {
+	allow := func(context.Context, ToolPermissionRequest) (PermissionResult, error) {
+		return PermissionResult{Decision: PermissionDecisionAllow}, nil
+	}
+	if err := NewClientBuilder().WithCanUseTool(allow).validateSettings(); err == nil {
+		t.Fatalf("validateSettings() error = nil, want streaming input required")
+	}
+	b := NewClientBuilder().WithCanUseTool(allow).WithStreamingInput(true).WithPermissionPromptTool("mcp__perm__prompt")
+	if err := b.validateSettings(); err == nil {
+		t.Fatalf("validateSettings() error = nil, want prompt tool conflict")
+	}
+}
@@ -0,0 +1,190 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// TurnResult is one completed chat turn.
+type TurnResult struct {
+	Prompt    string
+	SessionID string
+	// Messages are all messages the turn produced, ending with Result.
+	Messages []Message
+	Result   *ResultMessage
+	// Usage is the turn's token usage as reported by the result.
+	Usage *Usage
+	// CostUSD is the turn's cost, including retried attempts; the result's
+	// total_cost_usd is cumulative per process.
+	CostUSD float64
+	// Attempts counts the times the prompt was sent; above 1 only with
+	// WithRetry.
+	Attempts int
+}
+
+// Text returns the final assistant text of the turn.
+func (t *TurnResult) Text() string {
+	if t.Result == nil {
+		return ""
+	}
+	return t.Result.Result
+}
+
+// errCLIExited marks a turn the CLI died before starting, which Send retries
+// on a restarted process.
+var errCLIExited = errors.New("cli exited before the turn started")
+
+// Chat runs a multi-turn conversation on one streaming-input CLI process,
+// keeping the history of turns. If the process dies between turns, the
+// next Send restarts it resuming the same session. Chat is safe for
+// concurrent use; turns run one at a time.
+type Chat struct {
+	builder *ClientBuilder
+
+	mu        sync.Mutex
+	retry     *RetryPolicy
+	client    *Client
+	lastCost  float64
+	sessionID string
+	turns     []*TurnResult
+}
+
+// NewChat returns a chat that builds its client from b, switched to
+// streaming input. The CLI is started on the first Send.
+func NewChat(b *ClientBuilder) *Chat {
+	return &Chat{builder: b.WithStreamingInput(true)}
+}
+
+// Send runs one turn and returns it once the result message arrives. A
+// result with IsError set is returned as a turn, not an error, after any
+// retries configured with WithRetry. A CLI that
+// exits mid-turn fails the turn without a retry, since tools may already
+// have run.
+func (c *Chat) Send(ctx context.Context, text string) (*TurnResult, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("chat message is empty")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	turn, err := c.sendWithRetry(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	c.turns = append(c.turns, turn)
+	return turn, nil
+}
+
+// send runs one attempt of a turn, restarting a CLI that died between
+// turns.
+func (c *Chat) send(ctx context.Context, text string) (*TurnResult, error) {
+	restarted := false
+	for {
+		if c.client == nil {
+			if err := c.start(ctx); err != nil {
+				return nil, err
+			}
+		}
+		turn, err := c.runTurn(ctx, text)
+		if err == nil {
+			return turn, nil
+		}
+		// The stream is out of step after a failed turn; the next Send
+		// resumes the session on a fresh process.
+		_ = c.closeClient()
+		if !errors.Is(err, errCLIExited) || restarted {
+			return nil, err
+		}
+		restarted = true
+	}
+}
+
+func (c *Chat) start(ctx context.Context) error {
+	if c.sessionID != "" {
+		c.builder.WithResume(c.sessionID).WithContinue(false).WithForkSession(false).WithResumeSessionAt("")
+	}
+	client, err := c.builder.Build(ctx)
+	if err != nil {
+		return err
+	}
+	c.client = client
+	c.lastCost = 0
+	return nil
+}
+
+func (c *Chat) runTurn(ctx context.Context, text string) (*TurnResult, error) {
+	if err := c.client.SendUserInput(ctx, UserInput{Type: UserInputTypePrompt, Prompt: text}); err != nil {
+		if errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed) {
+			return nil, fmt.Errorf("%w: %v", errCLIExited, err)
+		}
+		return nil, err
+	}
+	turn := &TurnResult{Prompt: text, SessionID: c.sessionID}
+	for {
+		msg, err := c.client.NextMessage(ctx)
+		if err != nil {
+			if clerrors.IsEOF(err) && len(turn.Messages) == 0 {
+				return nil, fmt.Errorf("%w: %v", errCLIExited, err)
+			}
+			return nil, fmt.Errorf("chat turn: %w", err)
+		}
+		turn.Messages = append(turn.Messages, msg)
+		switch m := msg.(type) {
+		case *SystemInitMessage:
+			if m.SessionID != "" {
+				c.sessionID = m.SessionID
+				turn.SessionID = m.SessionID
+			}
+		case *ResultMessage:
+			if m.SessionID != "" {
+				c.sessionID = m.SessionID
+				turn.SessionID = m.SessionID
+			}
+			turn.Result = m
+			turn.Usage = m.Usage
+			turn.CostUSD = max(m.TotalCostUSD-c.lastCost, 0)
+			c.lastCost = m.TotalCostUSD
+			return turn, nil
+		}
+	}
+}
+
+// SessionID returns the current session ID, or "" before the first turn
+// has started.
+func (c *Chat) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
+}
+
+// History returns the completed turns, oldest first.
+func (c *Chat) History() []*TurnResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*TurnResult(nil), c.turns...)
+}
+
+// Close stops the CLI process. A later Send starts a new one resuming the
+// session.
+func (c *Chat) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeClient()
+}
+
+func (c *Chat) closeClient() error {
+	if c.client == nil {
+		return nil
+	}
+	client := c.client
+	c.client = nil
+	_ = client.CloseInput()
+	return client.Close()
+}
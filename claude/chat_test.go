@@ -0,0 +1,127 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// chatScript answers each stream-json user message with an assistant reply
+// and a result carrying cumulative cost. The first process exits after
+// $EXIT_AFTER turns; MID_TURN makes the second turn die after the
+// assistant message.
+const chatScript = `n=0
+first=0
+if [ ! -f "$STATE" ]; then : > "$STATE"; first=1; fi
+while read -r line; do
+  n=$((n+1))
+  if [ $n = 1 ]; then echo '{"type":"system","subtype":"init","session_id":"s1"}'; fi
+  echo "{\"type\":\"assistant\",\"session_id\":\"s1\",\"message\":{\"content\":[{\"type\":\"text\",\"text\":\"reply $n\"}]}}"
+  if [ -n "$MID_TURN" ] && [ $n = 2 ]; then exit 1; fi
+  echo "{\"type\":\"result\",\"subtype\":\"success\",\"session_id\":\"s1\",\"result\":\"reply $n\",\"total_cost_usd\":0.$n,\"usage\":{\"input_tokens\":$n,\"output_tokens\":1}}"
+  if [ $first = 1 ] && [ "$EXIT_AFTER" = $n ]; then exit 0; fi
+done
+`
+
+func newTestChat(t *testing.T, env map[string]string) (*Chat, *fakeCLI) {
+	t.Helper()
+	fake := newFakeCLI(chatScript)
+	b := fake.builder().WithEnv("STATE", filepath.Join(t.TempDir(), "state"))
+	for k, v := range env {
+		b.WithEnv(k, v)
+	}
+	chat := NewChat(b)
+	t.Cleanup(func() { _ = chat.Close() })
+	return chat, fake
+}
+
+func TestChatSendKeepsHistory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	chat, fake := newTestChat(t, nil)
+
+	for i, want := range []string{"reply 1", "reply 2", "reply 3"} {
+		turn, err := chat.Send(ctx, "message")
+		if err != nil {
+			t.Fatalf("Send(%d) error = %v", i, err)
+		}
+		if turn.Text() != want || turn.SessionID != "s1" {
+			t.Fatalf("turn %d = %q in %q, want %q in s1", i, turn.Text(), turn.SessionID, want)
+		}
+		if math.Abs(turn.CostUSD-0.1) > 1e-9 {
+			t.Fatalf("turn %d cost = %v, want 0.1", i, turn.CostUSD)
+		}
+		if turn.Usage == nil || turn.Usage.InputTokens != int64(i+1) {
+			t.Fatalf("turn %d usage = %+v", i, turn.Usage)
+		}
+	}
+	if got := fake.launchCount(); got != 1 {
+		t.Fatalf("launches = %d, want one process for all turns", got)
+	}
+	if got := argValue(fake.lastArgs(), "--input-format"); got != "stream-json" {
+		t.Fatalf("--input-format = %q, want stream-json", got)
+	}
+	if chat.SessionID() != "s1" {
+		t.Fatalf("SessionID() = %q, want s1", chat.SessionID())
+	}
+	history := chat.History()
+	if len(history) != 3 || history[0].Prompt != "message" || len(history[0].Messages) != 3 || len(history[1].Messages) != 2 {
+		t.Fatalf("History() = %+v", history)
+	}
+	if _, err := chat.Send(ctx, "  "); err == nil {
+		t.Fatalf("Send(empty) error = nil, want error")
+	}
+}
+
+func TestChatRestartsDeadCLI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	chat, fake := newTestChat(t, map[string]string{"EXIT_AFTER": "1"})
+
+	if _, err := chat.Send(ctx, "first"); err != nil {
+		t.Fatalf("Send(first) error = %v", err)
+	}
+	turn, err := chat.Send(ctx, "second")
+	if err != nil {
+		t.Fatalf("Send(second) error = %v", err)
+	}
+	if got := fake.launchCount(); got != 2 {
+		t.Fatalf("launches = %d, want a restart", got)
+	}
+	if got := argValue(fake.lastArgs(), "--resume"); got != "s1" {
+		t.Fatalf("--resume = %q, want s1 (args %v)", got, fake.lastArgs())
+	}
+	// The restarted process reports cost from zero again.
+	if turn.Text() != "reply 1" || math.Abs(turn.CostUSD-0.1) > 1e-9 {
+		t.Fatalf("turn = %q cost %v, want reply 1 cost 0.1", turn.Text(), turn.CostUSD)
+	}
+	if len(chat.History()) != 2 {
+		t.Fatalf("History() = %d turns, want 2", len(chat.History()))
+	}
+}
+
+func TestChatMidTurnExitIsNotRetried(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	chat, fake := newTestChat(t, map[string]string{"MID_TURN": "1"})
+
+	if _, err := chat.Send(ctx, "first"); err != nil {
+		t.Fatalf("Send(first) error = %v", err)
+	}
+	_, err := chat.Send(ctx, "second")
+	if !clerrors.IsEOF(err) || errors.Is(err, errCLIExited) {
+		t.Fatalf("Send(second) error = %v, want EOF mid-turn", err)
+	}
+	if got := fake.launchCount(); got != 1 {
+		t.Fatalf("launches = %d, want no retry", got)
+	}
+	if !strings.Contains(err.Error(), "chat turn") || len(chat.History()) != 1 {
+		t.Fatalf("err = %v, history = %d", err, len(chat.History()))
+	}
+}
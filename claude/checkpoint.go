@@ -0,0 +1,315 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// FileSnapshot is the content of a file as it was when a checkpoint was
+// taken. Exists is false for files the agent created afterwards, which a
+// rewind removes.
+type FileSnapshot struct {
+	Path    string
+	Exists  bool
+	Content []byte
+	Mode    fs.FileMode
+}
+
+// Checkpoint marks the start of one user turn. MessageUUID is the uuid of
+// the last top-level assistant message before the turn, the point to pass
+// to --resume-session-at; it is empty for the first turn of a session.
+// Files holds the pre-turn content of every file the turn's Write/Edit tool
+// uses touched.
+type Checkpoint struct {
+	SessionID   string
+	MessageUUID string
+	CreatedAt   time.Time
+	Files       map[string]FileSnapshot
+}
+
+// Checkpointer snapshots files modified by file-editing tool uses on the
+// clients it is attached to, and rewinds the working tree to an earlier
+// turn. Snapshots are taken when the tool_use is seen on the stream and
+// corrected from the tool result's originalFile when the CLI reports it,
+// since the tool may already have run by the time the message is read.
+type Checkpointer struct {
+	cwd string
+	now func() time.Time
+
+	mu          sync.Mutex
+	sessionID   string
+	lastUUID    string
+	checkpoints []*Checkpoint
+	pending     map[string]string
+}
+
+// fileEditTools maps the CLI's file-modifying tools to the input field
+// naming the file they write.
+var fileEditTools = map[string]string{
+	"Write":        "file_path",
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
+// NewCheckpointer creates a checkpointer resolving relative tool paths
+// against cwd; an empty cwd falls back to the cwd reported by the init
+// message, then to the process working directory.
+func NewCheckpointer(cwd string) *Checkpointer {
+	return &Checkpointer{cwd: cwd, now: time.Now, pending: map[string]string{}}
+}
+
+// Checkpoints returns the recorded checkpoints, oldest first.
+func (c *Checkpointer) Checkpoints() []Checkpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Checkpoint, len(c.checkpoints))
+	for i, cp := range c.checkpoints {
+		out[i] = *cp
+		out[i].Files = make(map[string]FileSnapshot, len(cp.Files))
+		for path, snap := range cp.Files {
+			out[i].Files[path] = snap
+		}
+	}
+	return out
+}
+
+func (c *Checkpointer) beforeInput(ctx context.Context, input UserInput) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkpoints = append(c.checkpoints, &Checkpoint{
+		SessionID:   c.sessionID,
+		MessageUUID: c.lastUUID,
+		CreatedAt:   c.now(),
+		Files:       map[string]FileSnapshot{},
+	})
+	return nil
+}
+
+func (c *Checkpointer) observe(ctx context.Context, msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *SystemInitMessage:
+		if m.SessionID != "" {
+			c.setSession(m.SessionID)
+		}
+		if c.cwd == "" {
+			c.cwd = m.CWD
+		}
+	case *AssistantMessage:
+		c.setSession(m.SessionID)
+		if m.ParentToolUseID == nil && m.UUID != "" {
+			c.lastUUID = m.UUID
+		}
+		for _, block := range m.Message.Content {
+			if block.ToolUse == nil {
+				continue
+			}
+			if err := c.snapshotToolUse(block.ToolUse); err != nil {
+				return err
+			}
+		}
+	case *UserMessage:
+		c.setSession(m.SessionID)
+		c.correctFromResult(m)
+	}
+	return nil
+}
+
+func (c *Checkpointer) setSession(id string) {
+	if id == "" {
+		return
+	}
+	c.sessionID = id
+	for _, cp := range c.checkpoints {
+		if cp.SessionID == "" {
+			cp.SessionID = id
+		}
+	}
+}
+
+func (c *Checkpointer) current() *Checkpoint {
+	if len(c.checkpoints) == 0 {
+		// Input written before the checkpointer was attached, e.g. via the
+		// raw protocol; still record the turn.
+		c.checkpoints = append(c.checkpoints, &Checkpoint{
+			SessionID: c.sessionID,
+			CreatedAt: c.now(),
+			Files:     map[string]FileSnapshot{},
+		})
+	}
+	return c.checkpoints[len(c.checkpoints)-1]
+}
+
+func (c *Checkpointer) snapshotToolUse(use *ToolUseContentBlock) error {
+	field, ok := fileEditTools[use.Name]
+	if !ok {
+		return nil
+	}
+	var input map[string]any
+	if err := json.Unmarshal(use.Input, &input); err != nil {
+		return nil
+	}
+	raw, _ := input[field].(string)
+	if raw == "" {
+		return nil
+	}
+	path := c.resolve(raw)
+
+	cp := c.current()
+	if _, seen := cp.Files[path]; seen {
+		return nil
+	}
+	snap, err := readSnapshot(path)
+	if err != nil {
+		return fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	cp.Files[path] = snap
+	c.pending[use.ID] = path
+	return nil
+}
+
+// correctFromResult replaces a snapshot with the pre-edit content the CLI
+// reports in tool_use_result, which is authoritative when the tool ran
+// before the tool_use message was observed.
+func (c *Checkpointer) correctFromResult(m *UserMessage) {
+	if m.ToolUseResult == nil {
+		return
+	}
+	for _, block := range m.Message.Content {
+		if block.ToolResult == nil {
+			continue
+		}
+		path, ok := c.pending[block.ToolResult.ToolUseID]
+		if !ok {
+			continue
+		}
+		delete(c.pending, block.ToolResult.ToolUseID)
+
+		cp := c.current()
+		snap := cp.Files[path]
+		switch {
+		case m.ToolUseResult.Type == "create":
+			snap = FileSnapshot{Path: path}
+		case m.ToolUseResult.OriginalFile != nil:
+			snap.Exists = true
+			snap.Content = []byte(*m.ToolUseResult.OriginalFile)
+			if snap.Mode == 0 {
+				snap.Mode = 0o644
+			}
+		default:
+			continue
+		}
+		cp.Files[path] = snap
+	}
+}
+
+func (c *Checkpointer) resolve(path string) string {
+	if !filepath.IsAbs(path) && c.cwd != "" {
+		path = filepath.Join(c.cwd, path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
+
+func readSnapshot(path string) (FileSnapshot, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return FileSnapshot{Path: path}, nil
+	}
+	if err != nil {
+		return FileSnapshot{}, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return FileSnapshot{}, err
+	}
+	return FileSnapshot{Path: path, Exists: true, Content: content, Mode: info.Mode().Perm()}, nil
+}
+
+// Rewind restores every file touched since the checkpoint whose
+// MessageUUID is toMessageUUID and drops that checkpoint and all later
+// ones. It returns the checkpoint rewound to; pass it to ResumeAt to
+// continue the conversation from the same point.
+func (c *Checkpointer) Rewind(toMessageUUID string) (*Checkpoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx := -1
+	for i, cp := range c.checkpoints {
+		if cp.MessageUUID == toMessageUUID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: %q", clerrors.ErrCheckpointNotFound, toMessageUUID)
+	}
+
+	// Walk newest to oldest so the earliest snapshot of a file wins.
+	restore := map[string]FileSnapshot{}
+	for i := len(c.checkpoints) - 1; i >= idx; i-- {
+		for path, snap := range c.checkpoints[i].Files {
+			restore[path] = snap
+		}
+	}
+	var errs []error
+	for path, snap := range restore {
+		if err := restoreSnapshot(snap); err != nil {
+			errs = append(errs, fmt.Errorf("restore %s: %w", path, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	target := c.checkpoints[idx]
+	c.checkpoints = c.checkpoints[:idx]
+	c.lastUUID = target.MessageUUID
+	c.pending = map[string]string{}
+	return target, nil
+}
+
+func restoreSnapshot(snap FileSnapshot) error {
+	if !snap.Exists {
+		if err := os.Remove(snap.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(snap.Path), 0o755); err != nil {
+		return err
+	}
+	mode := snap.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := os.WriteFile(snap.Path, snap.Content, mode); err != nil {
+		return err
+	}
+	return os.Chmod(snap.Path, mode)
+}
+
+// ResumeAt configures b to resume cp's session truncated to the message the
+// checkpoint was taken at. A checkpoint from the first turn has nothing to
+// resume, so b then starts a fresh session.
+func (c *Checkpointer) ResumeAt(b *ClientBuilder, cp *Checkpoint) *ClientBuilder {
+	b.WithCheckpointer(c)
+	if cp == nil || cp.SessionID == "" || cp.MessageUUID == "" {
+		return b.WithResume("").WithResumeSessionAt("").WithContinue(false)
+	}
+	return b.WithResume(cp.SessionID).WithResumeSessionAt(cp.MessageUUID).WithContinue(false)
+}
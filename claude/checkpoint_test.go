@@ -0,0 +1,114 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// editTurnScript emits one assistant turn whose tool_use edits path. When
+// early is set the file is written before the tool_use is printed, as
+// happens when the CLI runs the tool before the reader catches up.
+func editTurnScript(assistantUUID, tool, path, content, original string, early bool) string {
+	write := fmt.Sprintf("printf '%s' > '%s'\n", content, path)
+	result := `{"type":"create","filePath":"` + path + `"}`
+	if original != "" {
+		result = `{"type":"update","filePath":"` + path + `","originalFile":"` + original + `"}`
+	}
+	script := "cat >/dev/null\n"
+	script += `echo '{"type":"system","subtype":"init","session_id":"s1","cwd":"/"}'` + "\n"
+	if early {
+		script += write
+	}
+	script += fmt.Sprintf(`echo '{"type":"assistant","uuid":"%s","session_id":"s1","parent_tool_use_id":null,"message":{"role":"assistant","content":[{"type":"tool_use","id":"t-%s","name":"%s","input":{"file_path":"%s"}}]}}'`+"\n",
+		assistantUUID, assistantUUID, tool, path)
+	if !early {
+		script += write
+	}
+	script += fmt.Sprintf(`echo '{"type":"user","session_id":"s1","parent_tool_use_id":null,"message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t-%s","content":"ok"}]},"tool_use_result":%s}'`+"\n",
+		assistantUUID, result)
+	script += `echo '{"type":"result","subtype":"success","session_id":"s1"}'` + "\n"
+	return script
+}
+
+func TestCheckpointerRewind(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "main.txt")
+	created := filepath.Join(dir, "new.txt")
+	if err := os.WriteFile(existing, []byte("v0"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cp := NewCheckpointer(dir)
+	turns := []string{
+		editTurnScript("a1", "Edit", existing, "v1", "v0", false),
+		editTurnScript("a2", "Write", created, "fresh", "", false),
+		editTurnScript("a3", "Edit", existing, "v3", "v1", true),
+	}
+	for _, script := range turns {
+		client, err := newFakeCLI(script).builder().WithCheckpointer(cp).Build(ctx)
+		if err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+		drainClient(t, client)
+	}
+
+	var uuids []string
+	for _, c := range cp.Checkpoints() {
+		uuids = append(uuids, c.MessageUUID)
+		if c.SessionID != "s1" {
+			t.Fatalf("checkpoint session = %q, want s1", c.SessionID)
+		}
+	}
+	if want := []string{"", "a1", "a2"}; !reflect.DeepEqual(uuids, want) {
+		t.Fatalf("checkpoint uuids = %v, want %v", uuids, want)
+	}
+	if snap := cp.Checkpoints()[2].Files[existing]; string(snap.Content) != "v1" {
+		t.Fatalf("snapshot = %q, want v1 from originalFile", snap.Content)
+	}
+
+	target, err := cp.Rewind("a1")
+	if err != nil {
+		t.Fatalf("Rewind() error = %v", err)
+	}
+	if got, _ := os.ReadFile(existing); string(got) != "v1" {
+		t.Fatalf("main.txt = %q, want v1", got)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatalf("new.txt stat error = %v, want not exist", err)
+	}
+	if info, _ := os.Stat(existing); info.Mode().Perm() != 0o600 {
+		t.Fatalf("main.txt mode = %v, want 0600", info.Mode().Perm())
+	}
+	if len(cp.Checkpoints()) != 1 {
+		t.Fatalf("checkpoints after rewind = %d, want 1", len(cp.Checkpoints()))
+	}
+
+	fake := newFakeCLI(sessionScript("s1", dir, 0))
+	client, err := cp.ResumeAt(fake.builder(), target).Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	drainClient(t, client)
+	args := fake.lastArgs()
+	if argValue(args, "--resume") != "s1" || argValue(args, "--resume-session-at") != "a1" {
+		t.Fatalf("resume args = %v", args)
+	}
+
+	if _, err := cp.Rewind(""); err != nil {
+		t.Fatalf("Rewind(first) error = %v", err)
+	}
+	if got, _ := os.ReadFile(existing); string(got) != "v0" {
+		t.Fatalf("main.txt = %q, want v0", got)
+	}
+	if _, err := cp.Rewind("a1"); !errors.Is(err, clerrors.ErrCheckpointNotFound) {
+		t.Fatalf("Rewind(dropped) error = %v, want ErrCheckpointNotFound", err)
+	}
+}
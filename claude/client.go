@@ -2,27 +2,136 @@ package claude
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/flaneur2020/agentkit-go/claude/jsonschema"
 )
 
 type Client struct {
-	cmd      *exec.Cmd
-	protocol Protocol
-	stdin    io.WriteCloser
-	stdout   io.ReadCloser
-}
-
+	cmd       *exec.Cmd
+	protocol  Protocol
+	stdin     io.WriteCloser
+	stdout    io.ReadCloser
+	tempFiles []string
+	mcp       *mcpMonitor
+	observers []func(ctx context.Context, msg Message) error
+	// inputObservers run before each user input is written.
+	inputObservers []func(ctx context.Context, input UserInput) error
+	jsonSchema     *jsonschema.Schema
+	streamingInput bool
+	canUseTool     CanUseToolFunc
+	// closers run once Close has stopped the CLI.
+	closers []func()
+
+	stateMu        sync.Mutex
+	queued         []Message
+	permissionMode PermissionMode
+}
+
+// SendUserInput writes input. With WithStreamingInput, prompts are sent as
+// stream-json user messages.
 func (c *Client) SendUserInput(ctx context.Context, input UserInput) error {
+	for _, observe := range c.inputObservers {
+		if err := observe(ctx, input); err != nil {
+			return err
+		}
+	}
+	if c.streamingInput && (input.Type == UserInputTypePrompt || (input.Type == "" && input.Prompt != "")) {
+		input = UserInput{
+			Type: UserInputTypeUser,
+			UUID: input.UUID,
+			Message: &UserInputMessage{
+				Role:    "user",
+				Content: []UserInputContentBlock{{Type: "text", Text: input.Prompt}},
+			},
+		}
+	}
 	return c.protocol.SendUserInput(ctx, input)
 }
 
 func (c *Client) NextMessage(ctx context.Context) (Message, error) {
-	return c.protocol.NextMessage(ctx)
+	if msg, ok := c.dequeue(); ok {
+		return msg, nil
+	}
+	return c.readMessage(ctx)
+}
+
+func (c *Client) enqueue(msg Message) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.queued = append(c.queued, msg)
+}
+
+func (c *Client) dequeue() (Message, bool) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if len(c.queued) == 0 {
+		return nil, false
+	}
+	msg := c.queued[0]
+	c.queued = c.queued[1:]
+	return msg, true
+}
+
+// readMessage reads the next message from the CLI and runs the observers;
+// NextMessage and control requests waiting for a reply share it.
+func (c *Client) readMessage(ctx context.Context) (Message, error) {
+	msg, err := c.protocol.NextMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch m := msg.(type) {
+	case *SystemInitMessage:
+		if m.PermissionMode != "" {
+			c.setPermissionMode(m.PermissionMode)
+		}
+	case *StatusMessage:
+		if m.PermissionMode != "" {
+			c.setPermissionMode(m.PermissionMode)
+		}
+	case *ControlRequestMessage:
+		if m.Request.Subtype == ControlSubtypeCanUseTool && c.canUseTool != nil {
+			if err := c.answerCanUseTool(ctx, m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if sys, ok := msg.(*SystemInitMessage); ok && c.mcp != nil {
+		if err := c.mcp.observe(sys); err != nil {
+			return nil, err
+		}
+	}
+	for _, observe := range c.observers {
+		if err := observe(ctx, msg); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+func (c *Client) setPermissionMode(mode PermissionMode) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.permissionMode = mode
+}
+
+// MCPStatus returns the MCP server report built from the most recent init
+// message, or nil if none has been seen or no expectations were configured.
+func (c *Client) MCPStatus() *MCPStatusReport {
+	if c.mcp == nil {
+		return nil
+	}
+	return c.mcp.latest()
 }
 
 func (c *Client) MCPInitialize(ctx context.Context, params InitializeParams) (*InitializeResult, error) {
@@ -41,6 +150,47 @@ func (c *Client) MCPToolsCall(ctx context.Context, params ToolsCallParams) (*Too
 	return c.protocol.MCPToolsCall(ctx, params)
 }
 
+// Interrupt stops the turn in progress. With WithStreamingInput it sends an
+// interrupt control request and the session stays usable; otherwise the
+// CLI process is sent an interrupt signal. Messages already written remain
+// readable with NextMessage.
+func (c *Client) Interrupt(ctx context.Context) error {
+	if c.streamingInput {
+		_, err := c.sendControlRequest(ctx, ControlRequest{Subtype: ControlSubtypeInterrupt})
+		return err
+	}
+	if c.cmd == nil || c.cmd.Process == nil {
+		return fmt.Errorf("interrupt requires a claude process")
+	}
+	if err := c.cmd.Process.Signal(os.Interrupt); err != nil {
+		return fmt.Errorf("interrupt claude: %w", err)
+	}
+	return nil
+}
+
+// Compact asks the CLI to summarise the conversation so far, optionally
+// steered by instructions, by sending the /compact command. The CLI reports
+// completion with a compact_boundary system message.
+func (c *Client) Compact(ctx context.Context, instructions string) error {
+	prompt := "/compact"
+	if instructions = strings.TrimSpace(instructions); instructions != "" {
+		prompt += " " + instructions
+	}
+	return c.SendUserInput(ctx, UserInput{Type: UserInputTypePrompt, Prompt: prompt})
+}
+
+// CloseInput closes the CLI's stdin. In print mode the CLI starts the turn
+// once its input is closed.
+func (c *Client) CloseInput() error {
+	if c.stdin == nil {
+		return nil
+	}
+	if err := c.stdin.Close(); err != nil {
+		return fmt.Errorf("close stdin: %w", err)
+	}
+	return nil
+}
+
 func (c *Client) Close() error {
 	var firstErr error
 	if c.protocol != nil {
@@ -61,6 +211,16 @@ func (c *Client) Close() error {
 			firstErr = err
 		}
 	}
+	for _, path := range c.tempFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.tempFiles = nil
+	for _, closer := range c.closers {
+		closer()
+	}
+	c.closers = nil
 	return firstErr
 }
 
@@ -88,11 +248,41 @@ type ClientBuilder struct {
 	allowedTools               []string
 	disallowedTools            []string
 	mcpConfigPath              string
+	mcpServers                 map[string]MCPServerConfig
+	strictMCPConfig            bool
+	requiredMCPServers         []string
+	expectedMCPTools           map[string][]string
+	mcpStatusHandler           func(*MCPStatusReport)
+	mcpPreflight               *UserInput
 	includePartialMessages     bool
 	dangerouslySkipPermissions bool
 	resumeSessionID            string
 	continueSession            bool
-	permissionMode             string
+	forkSession                bool
+	resumeSessionAt            string
+	checkpointer               *Checkpointer
+	costTracker                *CostTracker
+	costTags                   []string
+	budget                     *BudgetLimits
+	contextTracker             *ContextTracker
+	jsonSchema                 *jsonschema.Schema
+	agents                     map[string]AgentDefinition
+	conversationTree           *ConversationTree
+	settings                   *Settings
+	settingsPath               string
+	settingSources             []SettingSource
+	settingSourcesSet          bool
+	addDirs                    []string
+	fallbackModel              string
+	permissionPromptTool       string
+	canUseTool                 CanUseToolFunc
+	observers                  []func(ctx context.Context, msg Message) error
+	middleware                 []Middleware
+	maxThinkingTokens          *int
+	allowRules                 []PermissionRule
+	denyRules                  []PermissionRule
+	permissionMode             PermissionMode
+	streamingInput             bool
 	cwd                        string
 	env                        map[string]string
 	writer                     io.Writer
@@ -109,6 +299,30 @@ func NewClientBuilder() *ClientBuilder {
 	}
 }
 
+// clone copies b so that options applied to the copy, including appended
+// observers and env entries, do not leak back into b.
+func (b *ClientBuilder) clone() *ClientBuilder {
+	c := *b
+	c.allowedTools = slices.Clip(b.allowedTools)
+	c.disallowedTools = slices.Clip(b.disallowedTools)
+	c.requiredMCPServers = slices.Clip(b.requiredMCPServers)
+	c.costTags = slices.Clip(b.costTags)
+	c.settingSources = slices.Clip(b.settingSources)
+	c.addDirs = slices.Clip(b.addDirs)
+	c.observers = slices.Clip(b.observers)
+	c.middleware = slices.Clip(b.middleware)
+	c.allowRules = slices.Clip(b.allowRules)
+	c.denyRules = slices.Clip(b.denyRules)
+	c.mcpServers = maps.Clone(b.mcpServers)
+	c.expectedMCPTools = maps.Clone(b.expectedMCPTools)
+	c.agents = maps.Clone(b.agents)
+	c.env = maps.Clone(b.env)
+	if c.env == nil {
+		c.env = map[string]string{}
+	}
+	return &c
+}
+
 func (b *ClientBuilder) WithBinary(path string) *ClientBuilder {
 	b.binary = strings.TrimSpace(path)
 	return b
@@ -149,11 +363,146 @@ func (b *ClientBuilder) WithDisallowedTools(tools ...string) *ClientBuilder {
 	return b
 }
 
+// WithAllowRules adds permission rules to --allowed-tools after the tools
+// given to WithAllowedTools.
+func (b *ClientBuilder) WithAllowRules(rules ...PermissionRule) *ClientBuilder {
+	b.allowRules = append(b.allowRules, rules...)
+	return b
+}
+
+// WithDenyRules adds permission rules to --disallowed-tools after the tools
+// given to WithDisallowedTools.
+func (b *ClientBuilder) WithDenyRules(rules ...PermissionRule) *ClientBuilder {
+	b.denyRules = append(b.denyRules, rules...)
+	return b
+}
+
+// WithSettings passes settings inline as --settings JSON. It is mutually
+// exclusive with WithSettingsFile.
+func (b *ClientBuilder) WithSettings(settings Settings) *ClientBuilder {
+	b.settings = &settings
+	return b
+}
+
+func (b *ClientBuilder) WithSettingsFile(path string) *ClientBuilder {
+	b.settingsPath = strings.TrimSpace(path)
+	return b
+}
+
+// WithSettingSources limits which settings files the CLI loads. Calling it
+// with no sources loads none of them.
+func (b *ClientBuilder) WithSettingSources(sources ...SettingSource) *ClientBuilder {
+	b.settingSources = append([]SettingSource(nil), sources...)
+	b.settingSourcesSet = true
+	return b
+}
+
+// WithAddDirs gives tools access to directories outside the working
+// directory.
+func (b *ClientBuilder) WithAddDirs(dirs ...string) *ClientBuilder {
+	b.addDirs = append(b.addDirs, dirs...)
+	return b
+}
+
+// WithFallbackModel names the model to switch to when the main model is
+// overloaded.
+func (b *ClientBuilder) WithFallbackModel(model string) *ClientBuilder {
+	b.fallbackModel = strings.TrimSpace(model)
+	return b
+}
+
+// WithPermissionPromptTool routes permission prompts to an MCP tool
+// (mcp__<server>__<tool>) instead of denying them in print mode.
+func (b *ClientBuilder) WithPermissionPromptTool(tool string) *ClientBuilder {
+	b.permissionPromptTool = strings.TrimSpace(tool)
+	return b
+}
+
+// WithCanUseTool answers the CLI's tool permission prompts with fn
+// (--permission-prompt-tool stdio). It requires WithStreamingInput; the
+// answered control requests are still returned by NextMessage.
+func (b *ClientBuilder) WithCanUseTool(fn CanUseToolFunc) *ClientBuilder {
+	b.canUseTool = fn
+	return b
+}
+
+// WithObserver runs fn on every message NextMessage returns, after the
+// built-in trackers. An error from fn is returned by NextMessage.
+func (b *ClientBuilder) WithObserver(fn func(ctx context.Context, msg Message) error) *ClientBuilder {
+	b.observers = append(b.observers, fn)
+	return b
+}
+
+// WithMiddleware adds middleware around the client's protocol. Repeated
+// calls append; the first middleware added is outermost.
+func (b *ClientBuilder) WithMiddleware(middleware ...Middleware) *ClientBuilder {
+	b.middleware = append(b.middleware, middleware...)
+	return b
+}
+
+func (b *ClientBuilder) WithMaxThinkingTokens(n int) *ClientBuilder {
+	b.maxThinkingTokens = &n
+	return b
+}
+
+// WithMCPConfig passes path as --mcp-config. Build reads the file and rejects
+// servers the CLI could not start (no command, no url, unknown type); fields
+// the CLI ignores are left alone. Use MCPConfig.Validate for a stricter check.
 func (b *ClientBuilder) WithMCPConfig(path string) *ClientBuilder {
 	b.mcpConfigPath = strings.TrimSpace(path)
 	return b
 }
 
+// WithMCPServers writes servers to a temporary --mcp-config file at Build
+// time; the file is removed by Client.Close.
+func (b *ClientBuilder) WithMCPServers(servers map[string]MCPServerConfig) *ClientBuilder {
+	b.mcpServers = make(map[string]MCPServerConfig, len(servers))
+	for name, server := range servers {
+		b.mcpServers[name] = server
+	}
+	return b
+}
+
+func (b *ClientBuilder) WithStrictMCPConfig(enabled bool) *ClientBuilder {
+	b.strictMCPConfig = enabled
+	return b
+}
+
+// WithRequiredMCPServers makes NextMessage fail with *MCPStatusError when
+// the init message reports any of these servers as not connected. The CLI
+// only emits init after the first input; use WithMCPPreflight to have Build
+// send that input and fail instead.
+func (b *ClientBuilder) WithRequiredMCPServers(names ...string) *ClientBuilder {
+	b.requiredMCPServers = append([]string(nil), names...)
+	return b
+}
+
+// WithExpectedMCPTools declares tools (without the mcp__ prefix) that server
+// should expose; missing ones are listed in MCPStatusReport.MissingTools.
+func (b *ClientBuilder) WithExpectedMCPTools(server string, tools ...string) *ClientBuilder {
+	if b.expectedMCPTools == nil {
+		b.expectedMCPTools = map[string][]string{}
+	}
+	b.expectedMCPTools[server] = append([]string(nil), tools...)
+	return b
+}
+
+func (b *ClientBuilder) WithMCPStatusHandler(handler func(*MCPStatusReport)) *ClientBuilder {
+	b.mcpStatusHandler = handler
+	return b
+}
+
+// WithMCPPreflight makes Build send input and read up to the init message,
+// so MCP expectations are checked before Build returns: a required server
+// that is not connected fails Build with *MCPStatusError. Messages read
+// meanwhile, init included, are still returned by NextMessage. Without
+// WithStreamingInput, input is the whole print-mode turn and stdin is closed
+// after it.
+func (b *ClientBuilder) WithMCPPreflight(input UserInput) *ClientBuilder {
+	b.mcpPreflight = &input
+	return b
+}
+
 func (b *ClientBuilder) WithIncludePartialMessages(enabled bool) *ClientBuilder {
 	b.includePartialMessages = enabled
 	return b
@@ -174,8 +523,79 @@ func (b *ClientBuilder) WithContinue(enabled bool) *ClientBuilder {
 	return b
 }
 
-func (b *ClientBuilder) WithPermissionMode(mode string) *ClientBuilder {
-	b.permissionMode = strings.TrimSpace(mode)
+// WithForkSession makes a resumed session continue under a new session id,
+// leaving the original conversation untouched.
+func (b *ClientBuilder) WithForkSession(enabled bool) *ClientBuilder {
+	b.forkSession = enabled
+	return b
+}
+
+// WithResumeSessionAt truncates a resumed session after the given message
+// uuid, dropping the turns that followed it.
+func (b *ClientBuilder) WithResumeSessionAt(messageUUID string) *ClientBuilder {
+	b.resumeSessionAt = strings.TrimSpace(messageUUID)
+	return b
+}
+
+// WithCheckpointer records file snapshots for every user turn on the built
+// client so the working tree can be rewound with cp.Rewind.
+func (b *ClientBuilder) WithCheckpointer(cp *Checkpointer) *ClientBuilder {
+	b.checkpointer = cp
+	return b
+}
+
+// WithCostTracker records the built client's spend on tracker, attributing
+// every turn to tags in addition to its session and models.
+func (b *ClientBuilder) WithCostTracker(tracker *CostTracker, tags ...string) *ClientBuilder {
+	b.costTracker = tracker
+	b.costTags = tags
+	return b
+}
+
+// WithBudget enforces spend limits on the built client, interrupting the
+// turn as soon as estimated spend crosses one. --max-budget-usd is still
+// the CLI's own backstop. Without WithStreamingInput the interrupt is a
+// SIGINT that ends the CLI, so the client cannot be used after a trip and
+// the turn's estimated spend is what counts toward a tenant budget.
+func (b *ClientBuilder) WithBudget(limits BudgetLimits) *ClientBuilder {
+	b.budget = &limits
+	return b
+}
+
+func (b *ClientBuilder) WithContextTracker(tracker *ContextTracker) *ClientBuilder {
+	b.contextTracker = tracker
+	return b
+}
+
+// WithJSONSchema asks the CLI for structured output matching schema; it is
+// returned in ResultMessage.StructuredOutput. See jsonschema.For to derive
+// a schema from a Go type and QueryTyped to decode it.
+func (b *ClientBuilder) WithJSONSchema(schema *jsonschema.Schema) *ClientBuilder {
+	b.jsonSchema = schema
+	return b
+}
+
+// WithAgents defines subagents for the session, passed as --agents JSON.
+func (b *ClientBuilder) WithAgents(agents map[string]AgentDefinition) *ClientBuilder {
+	b.agents = agents
+	return b
+}
+
+func (b *ClientBuilder) WithConversationTree(tree *ConversationTree) *ClientBuilder {
+	b.conversationTree = tree
+	return b
+}
+
+func (b *ClientBuilder) WithPermissionMode(mode PermissionMode) *ClientBuilder {
+	b.permissionMode = mode
+	return b
+}
+
+// WithStreamingInput makes the CLI read stream-json from stdin
+// (--input-format stream-json). The session then accepts several user
+// messages and control requests such as SetPermissionMode and Interrupt.
+func (b *ClientBuilder) WithStreamingInput(enabled bool) *ClientBuilder {
+	b.streamingInput = enabled
 	return b
 }
 
@@ -208,6 +628,23 @@ func (b *ClientBuilder) WithWriter(w io.Writer) *ClientBuilder {
 }
 
 func (b *ClientBuilder) Build(ctx context.Context) (*Client, error) {
+	client, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if b.mcpPreflight != nil {
+		if err := client.awaitInit(ctx, *b.mcpPreflight); err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+func (b *ClientBuilder) build(ctx context.Context) (*Client, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
 	hasReader := b.reader != nil
 	hasWriter := b.writer != nil
 	if hasReader || hasWriter {
@@ -215,8 +652,9 @@ func (b *ClientBuilder) Build(ctx context.Context) (*Client, error) {
 			return nil, fmt.Errorf("withReadWriter requires both stdin and stdout")
 		}
 
-		p := NewProtocol(b.reader, b.writer)
-		client := &Client{protocol: p}
+		p := withMiddleware(NewProtocol(b.reader, b.writer), b.middleware)
+		client := &Client{protocol: p, mcp: b.newMCPMonitor(), jsonSchema: b.jsonSchema, streamingInput: b.streamingInput, canUseTool: b.canUseTool}
+		b.attachObservers(client)
 		if stdin, ok := b.writer.(io.WriteCloser); ok {
 			client.stdin = stdin
 		}
@@ -230,7 +668,25 @@ func (b *ClientBuilder) Build(ctx context.Context) (*Client, error) {
 		return nil, fmt.Errorf("binary is empty")
 	}
 
-	args := b.buildArgs()
+	args, err := b.buildArgs()
+	if err != nil {
+		return nil, err
+	}
+	var tempFiles []string
+	if len(b.mcpServers) > 0 {
+		path, err := writeMCPConfigFile(MCPConfig{MCPServers: b.mcpServers})
+		if err != nil {
+			return nil, err
+		}
+		tempFiles = append(tempFiles, path)
+		args = append(args, "--mcp-config", path)
+	}
+	removeTempFiles := func() {
+		for _, path := range tempFiles {
+			_ = os.Remove(path)
+		}
+	}
+
 	cmd := b.commandFactory(ctx, b.binary, args...)
 	if b.cwd != "" {
 		cmd.Dir = b.cwd
@@ -249,30 +705,190 @@ func (b *ClientBuilder) Build(ctx context.Context) (*Client, error) {
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
+		removeTempFiles()
 		return nil, fmt.Errorf("open stdin pipe: %w", err)
 	}
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
+		removeTempFiles()
 		return nil, fmt.Errorf("open stdout pipe: %w", err)
 	}
 
 	if err := cmd.Start(); err != nil {
 		_ = stdin.Close()
 		_ = stdout.Close()
+		removeTempFiles()
 		return nil, fmt.Errorf("start %s: %w", b.binary, err)
 	}
 
-	p := NewProtocol(stdout, stdin)
-	return &Client{
-		cmd:      cmd,
-		protocol: p,
-		stdin:    stdin,
-		stdout:   stdout,
-	}, nil
+	p := withMiddleware(NewProtocol(stdout, stdin), b.middleware)
+	client := &Client{
+		cmd:            cmd,
+		protocol:       p,
+		stdin:          stdin,
+		stdout:         stdout,
+		tempFiles:      tempFiles,
+		mcp:            b.newMCPMonitor(),
+		jsonSchema:     b.jsonSchema,
+		streamingInput: b.streamingInput,
+		canUseTool:     b.canUseTool,
+	}
+	b.attachObservers(client)
+	return client, nil
+}
+
+func (b *ClientBuilder) attachObservers(client *Client) {
+	if cp := b.checkpointer; cp != nil {
+		client.inputObservers = append(client.inputObservers, cp.beforeInput)
+		client.observers = append(client.observers, cp.observe)
+	}
+	if b.costTracker != nil {
+		stream := b.costTracker.newStream(b.costTags)
+		client.observers = append(client.observers, stream.observeContext)
+	}
+	if b.conversationTree != nil {
+		client.observers = append(client.observers, b.conversationTree.observe)
+	}
+	if b.contextTracker != nil {
+		client.observers = append(client.observers, b.contextTracker.observe)
+	}
+	client.observers = append(client.observers, b.observers...)
+	if b.budget != nil {
+		enforcer := newBudgetEnforcer(*b.budget)
+		enforcer.client = client
+		client.inputObservers = append(client.inputObservers, enforcer.beforeInput)
+		client.observers = append(client.observers, enforcer.observe)
+		client.closers = append(client.closers, enforcer.close)
+	}
+}
+
+func (b *ClientBuilder) newMCPMonitor() *mcpMonitor {
+	if len(b.requiredMCPServers) == 0 && len(b.expectedMCPTools) == 0 && b.mcpStatusHandler == nil {
+		return nil
+	}
+	expected := make(map[string][]string, len(b.expectedMCPTools))
+	for server, tools := range b.expectedMCPTools {
+		expected[server] = tools
+	}
+	for _, server := range b.requiredMCPServers {
+		if _, ok := expected[server]; !ok {
+			expected[server] = nil
+		}
+	}
+	return &mcpMonitor{
+		required:      append([]string(nil), b.requiredMCPServers...),
+		expectedTools: expected,
+		handler:       b.mcpStatusHandler,
+	}
+}
+
+// validate checks every option, whether Build launches the CLI or wraps
+// WithReader and WithWriter.
+func (b *ClientBuilder) validate() error {
+	if err := b.validateMCPConfig(); err != nil {
+		return err
+	}
+	if b.jsonSchema != nil {
+		if _, err := json.Marshal(b.jsonSchema); err != nil {
+			return fmt.Errorf("marshal json schema: %w", err)
+		}
+	}
+	if err := ValidateAgents(b.agents); err != nil {
+		return fmt.Errorf("invalid agents: %w", err)
+	}
+	if err := b.validateSettings(); err != nil {
+		return err
+	}
+	return b.validatePermissionMode()
+}
+
+func (b *ClientBuilder) validateMCPConfig() error {
+	if b.mcpConfigPath != "" && len(b.mcpServers) > 0 {
+		return fmt.Errorf("WithMCPConfig and WithMCPServers are mutually exclusive")
+	}
+	if b.strictMCPConfig && b.mcpConfigPath == "" && len(b.mcpServers) == 0 {
+		return fmt.Errorf("strict mcp config requires WithMCPConfig or WithMCPServers")
+	}
+	if b.mcpConfigPath != "" {
+		cfg, err := LoadMCPConfig(b.mcpConfigPath)
+		if err != nil {
+			return err
+		}
+		if err := cfg.validate(false); err != nil {
+			return fmt.Errorf("invalid mcp config %s: %w", b.mcpConfigPath, err)
+		}
+	}
+	if len(b.mcpServers) > 0 {
+		if err := (MCPConfig{MCPServers: b.mcpServers}).validate(false); err != nil {
+			return fmt.Errorf("invalid mcp servers: %w", err)
+		}
+	}
+	return nil
+}
+
+func (b *ClientBuilder) validatePermissionMode() error {
+	if b.permissionMode == "" {
+		return nil
+	}
+	if err := b.permissionMode.Validate(); err != nil {
+		return err
+	}
+	if b.dangerouslySkipPermissions && b.permissionMode != PermissionModeBypassPermissions {
+		return fmt.Errorf("WithDangerouslySkipPermissions conflicts with permission mode %s", b.permissionMode)
+	}
+	return nil
+}
+
+func (b *ClientBuilder) validateSettings() error {
+	var errs []error
+	if b.settings != nil && b.settingsPath != "" {
+		errs = append(errs, fmt.Errorf("WithSettings and WithSettingsFile are mutually exclusive"))
+	}
+	if b.settings != nil {
+		if err := b.settings.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid settings: %w", err))
+		}
+		if _, err := json.Marshal(b.settings); err != nil {
+			errs = append(errs, fmt.Errorf("marshal settings: %w", err))
+		}
+	}
+	for _, source := range b.settingSources {
+		if err := source.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, rule := range append(append([]PermissionRule(nil), b.allowRules...), b.denyRules...) {
+		if err := rule.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if b.fallbackModel != "" && b.fallbackModel == b.model {
+		errs = append(errs, fmt.Errorf("fallback model must differ from model %q", b.model))
+	}
+	if b.permissionPromptTool != "" {
+		if _, _, ok := ParseMCPToolName(b.permissionPromptTool); !ok {
+			errs = append(errs, fmt.Errorf("permission prompt tool %q is not an mcp tool name", b.permissionPromptTool))
+		}
+	}
+	if b.canUseTool != nil {
+		if b.permissionPromptTool != "" {
+			errs = append(errs, fmt.Errorf("WithCanUseTool conflicts with permission prompt tool %q", b.permissionPromptTool))
+		}
+		if !b.streamingInput {
+			errs = append(errs, fmt.Errorf("WithCanUseTool requires WithStreamingInput"))
+		}
+	}
+	if b.maxThinkingTokens != nil && *b.maxThinkingTokens < 0 {
+		errs = append(errs, fmt.Errorf("max thinking tokens must not be negative"))
+	}
+	return errors.Join(errs...)
 }
 
-func (b *ClientBuilder) buildArgs() []string {
+func (b *ClientBuilder) buildArgs() ([]string, error) {
 	args := []string{"--print", "--output-format", "stream-json", "--verbose"}
+	if b.streamingInput {
+		args = append(args, "--input-format", "stream-json")
+	}
 
 	if b.model != "" {
 		args = append(args, "--model", b.model)
@@ -289,15 +905,18 @@ func (b *ClientBuilder) buildArgs() []string {
 	if b.appendSystemPrompt != "" {
 		args = append(args, "--append-system-prompt", b.appendSystemPrompt)
 	}
-	if len(b.allowedTools) > 0 {
-		args = append(args, "--allowed-tools", strings.Join(b.allowedTools, ","))
+	if allowed := append(append([]string(nil), b.allowedTools...), ruleStrings(b.allowRules)...); len(allowed) > 0 {
+		args = append(args, "--allowed-tools", strings.Join(allowed, ","))
 	}
-	if len(b.disallowedTools) > 0 {
-		args = append(args, "--disallowed-tools", strings.Join(b.disallowedTools, ","))
+	if disallowed := append(append([]string(nil), b.disallowedTools...), ruleStrings(b.denyRules)...); len(disallowed) > 0 {
+		args = append(args, "--disallowed-tools", strings.Join(disallowed, ","))
 	}
 	if b.mcpConfigPath != "" {
 		args = append(args, "--mcp-config", b.mcpConfigPath)
 	}
+	if b.strictMCPConfig {
+		args = append(args, "--strict-mcp-config")
+	}
 	if b.includePartialMessages {
 		args = append(args, "--include-partial-messages")
 	}
@@ -310,9 +929,60 @@ func (b *ClientBuilder) buildArgs() []string {
 	if b.continueSession {
 		args = append(args, "--continue")
 	}
+	if b.forkSession {
+		args = append(args, "--fork-session")
+	}
+	if b.resumeSessionAt != "" {
+		args = append(args, "--resume-session-at", b.resumeSessionAt)
+	}
 	if b.permissionMode != "" {
-		args = append(args, "--permission-mode", b.permissionMode)
+		args = append(args, "--permission-mode", string(b.permissionMode))
+	}
+	if b.jsonSchema != nil {
+		schema, err := json.Marshal(b.jsonSchema)
+		if err != nil {
+			return nil, fmt.Errorf("marshal json schema: %w", err)
+		}
+		args = append(args, "--json-schema", string(schema))
+	}
+	if len(b.agents) > 0 {
+		agents, err := marshalAgents(b.agents)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--agents", agents)
+	}
+	if b.settings != nil {
+		settings, err := json.Marshal(b.settings)
+		if err != nil {
+			return nil, fmt.Errorf("marshal settings: %w", err)
+		}
+		args = append(args, "--settings", string(settings))
+	}
+	if b.settingsPath != "" {
+		args = append(args, "--settings", b.settingsPath)
+	}
+	if b.settingSourcesSet {
+		sources := make([]string, len(b.settingSources))
+		for i, source := range b.settingSources {
+			sources[i] = string(source)
+		}
+		args = append(args, "--setting-sources", strings.Join(sources, ","))
+	}
+	for _, dir := range b.addDirs {
+		args = append(args, "--add-dir", dir)
+	}
+	if b.fallbackModel != "" {
+		args = append(args, "--fallback-model", b.fallbackModel)
+	}
+	if b.permissionPromptTool != "" {
+		args = append(args, "--permission-prompt-tool", b.permissionPromptTool)
+	} else if b.canUseTool != nil {
+		args = append(args, "--permission-prompt-tool", "stdio")
+	}
+	if b.maxThinkingTokens != nil {
+		args = append(args, "--max-thinking-tokens", strconv.Itoa(*b.maxThinkingTokens))
 	}
 
-	return args
+	return args, nil
 }
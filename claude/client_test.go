@@ -3,6 +3,8 @@ package claude
 import (
 	"bytes"
 	"context"
+	"errors"
+	"math"
 	"os"
 	"reflect"
 	"strings"
@@ -10,6 +12,7 @@ import (
 	"time"
 
 	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+	"github.com/flaneur2020/agentkit-go/claude/jsonschema"
 )
 
 func TestClientBuilderCommandArgs(t *testing.T) {
@@ -26,9 +29,14 @@ func TestClientBuilderCommandArgs(t *testing.T) {
 		WithDangerouslySkipPermissions(true).
 		WithResume("session-1").
 		WithContinue(true).
+		WithForkSession(true).
+		WithResumeSessionAt("msg-1").
 		WithPermissionMode("acceptEdits")
 
-	args := builder.buildArgs()
+	args, err := builder.buildArgs()
+	if err != nil {
+		t.Fatalf("buildArgs() error = %v", err)
+	}
 	expected := []string{
 		"--print", "--output-format", "stream-json", "--verbose",
 		"--model", "sonnet",
@@ -43,6 +51,8 @@ func TestClientBuilderCommandArgs(t *testing.T) {
 		"--dangerously-skip-permissions",
 		"--resume", "session-1",
 		"--continue",
+		"--fork-session",
+		"--resume-session-at", "msg-1",
 		"--permission-mode", "acceptEdits",
 	}
 	if !reflect.DeepEqual(args, expected) {
@@ -90,7 +100,7 @@ func TestClientBuildAndChatWithRealClaude(t *testing.T) {
 		}
 
 		switch m := msg.(type) {
-		case *SystemMessage:
+		case *SystemInitMessage:
 			gotSystem = true
 		case *ResultMessage:
 			gotResult = true
@@ -269,3 +279,40 @@ func TestClientBuildWithRealClaudeFileEdit(t *testing.T) {
 		t.Fatalf("file content = %q, want contains NEW_VALUE", string(content))
 	}
 }
+
+func TestClientBuilderWithObserver(t *testing.T) {
+	in := strings.NewReader(`{"type":"keep_alive"}` + "\n" + `{"type":"result","subtype":"success","result":"ok"}` + "\n")
+	var seen []MessageType
+	stop := errors.New("stop")
+	client, err := NewClientBuilder().
+		WithReader(in).
+		WithWriter(&bytes.Buffer{}).
+		WithObserver(func(ctx context.Context, msg Message) error {
+			seen = append(seen, msg.GetType())
+			if msg.GetType() == MessageTypeResult {
+				return stop
+			}
+			return nil
+		}).
+		Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if _, err := client.NextMessage(context.Background()); err != nil {
+		t.Fatalf("NextMessage() error = %v", err)
+	}
+	if _, err := client.NextMessage(context.Background()); !errors.Is(err, stop) {
+		t.Fatalf("NextMessage() error = %v, want observer error", err)
+	}
+	if !reflect.DeepEqual(seen, []MessageType{MessageTypeKeepAlive, MessageTypeResult}) {
+		t.Fatalf("observed %v", seen)
+	}
+}
+
+func TestClientBuilderBuildArgsMarshalError(t *testing.T) {
+	nan := math.NaN()
+	b := NewClientBuilder().WithJSONSchema(&jsonschema.Schema{Type: jsonschema.SchemaType{"number"}, Minimum: &nan})
+	if args, err := b.buildArgs(); err == nil {
+		t.Fatalf("buildArgs() = %v, want marshal error", args)
+	}
+}
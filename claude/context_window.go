@@ -0,0 +1,195 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultContextWindow is assumed for models whose window the CLI has not
+// reported yet in a result's modelUsage.
+const DefaultContextWindow int64 = 200_000
+
+// CompactMetadata is carried by CompactBoundaryMessage, the compact_boundary
+// system message the CLI emits when it summarises the conversation to free
+// context.
+type CompactMetadata struct {
+	Trigger   string `json:"trigger,omitempty"`
+	PreTokens int64  `json:"pre_tokens,omitempty"`
+}
+
+// ContextManagement reports the context edits the API applied before
+// generating an assistant message.
+type ContextManagement struct {
+	AppliedEdits []ContextEdit `json:"applied_edits,omitempty"`
+}
+
+type ContextEdit struct {
+	Type                 string `json:"type"`
+	ClearedToolUses      int    `json:"cleared_tool_uses,omitempty"`
+	ClearedThinkingTurns int    `json:"cleared_thinking_turns,omitempty"`
+	ClearedInputTokens   int64  `json:"cleared_input_tokens,omitempty"`
+}
+
+// ParseContextManagement decodes the raw context_management field. It
+// returns nil when the field is absent or null.
+func (p AssistantPayload) ParseContextManagement() (*ContextManagement, error) {
+	raw := bytes.TrimSpace(p.ContextManagement)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	var cm ContextManagement
+	if err := json.Unmarshal(raw, &cm); err != nil {
+		return nil, fmt.Errorf("parse context_management: %w", err)
+	}
+	return &cm, nil
+}
+
+// ContextUsage is the context occupancy after an assistant message: the
+// prompt tokens it consumed, including cache reads and writes, against the
+// model's window.
+type ContextUsage struct {
+	Model        string
+	UsedTokens   int64
+	WindowTokens int64
+}
+
+func (u ContextUsage) Fraction() float64 {
+	if u.WindowTokens <= 0 {
+		return 0
+	}
+	return float64(u.UsedTokens) / float64(u.WindowTokens)
+}
+
+// ContextTracker follows context occupancy on a client. When occupancy
+// crosses threshold, onThreshold is called once; it is re-armed by the
+// next compaction. The callback runs inside NextMessage and may call
+// Client.Compact.
+type ContextTracker struct {
+	threshold   float64
+	onThreshold func(ContextUsage)
+
+	mu          sync.Mutex
+	windows     map[string]int64
+	reported    map[string]int64
+	model       string
+	usage       ContextUsage
+	fired       bool
+	compactions []CompactMetadata
+	edits       []ContextEdit
+}
+
+// NewContextTracker creates a tracker; a threshold of zero disables the
+// callback.
+func NewContextTracker(threshold float64, onThreshold func(ContextUsage)) *ContextTracker {
+	return &ContextTracker{
+		threshold:   threshold,
+		onThreshold: onThreshold,
+		windows:     map[string]int64{},
+		reported:    map[string]int64{},
+	}
+}
+
+// SetWindow overrides the context window for models named model or
+// starting with it, taking precedence over the window the CLI reports.
+func (t *ContextTracker) SetWindow(model string, tokens int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.windows[model] = tokens
+}
+
+func (t *ContextTracker) Usage() ContextUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+// Compactions returns the compact_boundary metadata seen so far.
+func (t *ContextTracker) Compactions() []CompactMetadata {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]CompactMetadata(nil), t.compactions...)
+}
+
+// Edits returns the context_management edits applied so far.
+func (t *ContextTracker) Edits() []ContextEdit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]ContextEdit(nil), t.edits...)
+}
+
+func (t *ContextTracker) window(model string) int64 {
+	best, window := "", int64(0)
+	for key, v := range t.windows {
+		if strings.HasPrefix(model, key) && len(key) >= len(best) && v > 0 {
+			best, window = key, v
+		}
+	}
+	if window > 0 {
+		return window
+	}
+	if v, ok := t.reported[model]; ok {
+		return v
+	}
+	return DefaultContextWindow
+}
+
+func (t *ContextTracker) observe(ctx context.Context, msg Message) error {
+	var crossed *ContextUsage
+
+	t.mu.Lock()
+	switch m := msg.(type) {
+	case *SystemInitMessage:
+		if m.Model != "" {
+			t.model = m.Model
+		}
+	case *CompactBoundaryMessage:
+		t.compactions = append(t.compactions, m.CompactMetadata)
+		t.usage.UsedTokens = 0
+		t.fired = false
+	case *AssistantMessage:
+		// Subagents run in their own context window.
+		if m.ParentToolUseID != nil {
+			break
+		}
+		// A malformed context_management field is ignored on purpose: an
+		// observer error would fail NextMessage, and the edits only feed
+		// Edits, not the usage tracked below.
+		if cm, err := m.Message.ParseContextManagement(); err == nil && cm != nil {
+			t.edits = append(t.edits, cm.AppliedEdits...)
+		}
+		if m.Message.Usage == nil {
+			break
+		}
+		model := m.Message.Model
+		if model == "" {
+			model = t.model
+		}
+		u := m.Message.Usage
+		t.usage = ContextUsage{
+			Model:        model,
+			UsedTokens:   u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputToken,
+			WindowTokens: t.window(model),
+		}
+		if t.threshold > 0 && !t.fired && t.usage.Fraction() >= t.threshold {
+			t.fired = true
+			usage := t.usage
+			crossed = &usage
+		}
+	case *ResultMessage:
+		for model, mu := range m.ModelUsage {
+			if mu.ContextWindow > 0 {
+				t.reported[model] = mu.ContextWindow
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	if crossed != nil && t.onThreshold != nil {
+		t.onThreshold(*crossed)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+func TestParseContextManagement(t *testing.T) {
+	payload := AssistantPayload{ContextManagement: []byte(`{"applied_edits":[{"type":"clear_tool_uses_20250919","cleared_tool_uses":3,"cleared_input_tokens":1200}]}`)}
+	cm, err := payload.ParseContextManagement()
+	if err != nil {
+		t.Fatalf("ParseContextManagement() error = %v", err)
+	}
+	want := &ContextManagement{AppliedEdits: []ContextEdit{{Type: "clear_tool_uses_20250919", ClearedToolUses: 3, ClearedInputTokens: 1200}}}
+	if !reflect.DeepEqual(cm, want) {
+		t.Fatalf("ParseContextManagement() = %+v, want %+v", cm, want)
+	}
+
+	payload.ContextManagement = []byte("null")
+	if cm, err := payload.ParseContextManagement(); cm != nil || err != nil {
+		t.Fatalf("ParseContextManagement(null) = %v, %v", cm, err)
+	}
+}
+
+func TestContextTrackerThresholdAndCompaction(t *testing.T) {
+	lines := strings.Join([]string{
+		`{"type":"system","subtype":"init","session_id":"s1","model":"claude-sonnet-4-5"}`,
+		`{"type":"assistant","message":{"model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":1000,"cache_read_input_tokens":80000,"cache_creation_input_tokens":19000}}}`,
+		`{"type":"assistant","parent_tool_use_id":"t1","message":{"model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":190000}}}`,
+		`{"type":"result","subtype":"success","modelUsage":{"claude-sonnet-4-5":{"contextWindow":125000}}}`,
+		`{"type":"assistant","message":{"model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":100000},"context_management":{"applied_edits":[{"type":"clear_tool_uses_20250919","cleared_tool_uses":2}]}}}`,
+		`{"type":"assistant","message":{"model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":110000}}}`,
+		`{"type":"system","subtype":"compact_boundary","session_id":"s1","compact_metadata":{"trigger":"manual","pre_tokens":110000}}`,
+		`{"type":"assistant","message":{"model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":120000}}}`,
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	var client *Client
+	var crossings []ContextUsage
+	tracker := NewContextTracker(0.75, func(u ContextUsage) {
+		crossings = append(crossings, u)
+		if err := client.Compact(context.Background(), "keep the todo list"); err != nil {
+			t.Errorf("Compact() error = %v", err)
+		}
+	})
+	client, err := NewClientBuilder().
+		WithReader(strings.NewReader(lines)).
+		WithWriter(&out).
+		WithContextTracker(tracker).
+		Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	var usages []int64
+	for {
+		msg, err := client.NextMessage(context.Background())
+		if clerrors.IsEOF(err) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextMessage() error = %v", err)
+		}
+		if _, ok := msg.(*AssistantMessage); ok {
+			usages = append(usages, tracker.Usage().UsedTokens)
+		}
+	}
+
+	if want := []int64{100000, 100000, 100000, 110000, 120000}; !reflect.DeepEqual(usages, want) {
+		t.Fatalf("usages = %v, want %v", usages, want)
+	}
+	if len(crossings) != 2 {
+		t.Fatalf("crossings = %+v, want 2", crossings)
+	}
+	// The first message is at 50% of the default window; the result then
+	// reports the real window.
+	if crossings[0].UsedTokens != 100000 || crossings[0].WindowTokens != 125000 || crossings[1].UsedTokens != 120000 {
+		t.Fatalf("crossings = %+v", crossings)
+	}
+	if got := crossings[0].Fraction(); got != 0.8 {
+		t.Fatalf("Fraction() = %v, want 0.8", got)
+	}
+	if got := out.String(); got != strings.Repeat("/compact keep the todo list", 2) {
+		t.Fatalf("written = %q", got)
+	}
+	if want := []CompactMetadata{{Trigger: "manual", PreTokens: 110000}}; !reflect.DeepEqual(tracker.Compactions(), want) {
+		t.Fatalf("Compactions() = %+v, want %+v", tracker.Compactions(), want)
+	}
+	if edits := tracker.Edits(); len(edits) != 1 || edits[0].ClearedToolUses != 2 {
+		t.Fatalf("Edits() = %+v", edits)
+	}
+}
+
+func TestClientCompactStreamingInput(t *testing.T) {
+	var out bytes.Buffer
+	client, err := NewClientBuilder().
+		WithReader(strings.NewReader("")).
+		WithWriter(&out).
+		WithStreamingInput(true).
+		Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if err := client.Compact(context.Background(), " keep the todo list "); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	var sent UserInput
+	if err := json.Unmarshal(out.Bytes(), &sent); err != nil {
+		t.Fatalf("unmarshal %q: %v", out.String(), err)
+	}
+	if sent.Message == nil || len(sent.Message.Content) != 1 || sent.Message.Content[0].Text != "/compact keep the todo list" {
+		t.Fatalf("sent = %s", out.String())
+	}
+}
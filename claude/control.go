@@ -0,0 +1,190 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// ControlSubtype names a control request on the stream-json control
+// channel.
+type ControlSubtype string
+
+const (
+	ControlSubtypeInitialize        ControlSubtype = "initialize"
+	ControlSubtypeInterrupt         ControlSubtype = "interrupt"
+	ControlSubtypeCanUseTool        ControlSubtype = "can_use_tool"
+	ControlSubtypeHookCallback      ControlSubtype = "hook_callback"
+	ControlSubtypeMCPMessage        ControlSubtype = "mcp_message"
+	ControlSubtypeSetPermissionMode ControlSubtype = "set_permission_mode"
+	ControlSubtypeSetModel          ControlSubtype = "set_model"
+)
+
+// ControlRequest is the body of a control_request. Fields are populated
+// according to Subtype; Payload keeps the full body for subtypes this
+// package does not model.
+type ControlRequest struct {
+	Subtype ControlSubtype `json:"subtype"`
+
+	// can_use_tool
+	ToolName              string          `json:"tool_name,omitempty"`
+	Input                 json.RawMessage `json:"input,omitempty"`
+	ToolUseID             string          `json:"tool_use_id,omitempty"`
+	PermissionSuggestions json.RawMessage `json:"permission_suggestions,omitempty"`
+	BlockedPath           string          `json:"blocked_path,omitempty"`
+
+	// hook_callback
+	CallbackID string `json:"callback_id,omitempty"`
+
+	// mcp_message
+	ServerName string          `json:"server_name,omitempty"`
+	Message    json.RawMessage `json:"message,omitempty"`
+
+	// set_permission_mode and set_model
+	Mode  string `json:"mode,omitempty"`
+	Model string `json:"model,omitempty"`
+
+	Payload json.RawMessage `json:"-"`
+}
+
+func (r *ControlRequest) UnmarshalJSON(data []byte) error {
+	type alias ControlRequest
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*r = ControlRequest(decoded)
+	r.Payload = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// ControlRequestMessage is a control request sent by the CLI, such as a
+// tool permission prompt, which expects a control_response with the same
+// RequestID.
+type ControlRequestMessage struct {
+	messageRaw
+	Type      MessageType    `json:"type"`
+	RequestID string         `json:"request_id"`
+	Request   ControlRequest `json:"request"`
+}
+
+func (m *ControlRequestMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *ControlRequestMessage) UnmarshalJSON(data []byte) error {
+	type alias ControlRequestMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = ControlRequestMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
+const (
+	ControlResponseSuccess = "success"
+	ControlResponseError   = "error"
+)
+
+type ControlResponse struct {
+	Subtype   string          `json:"subtype"`
+	RequestID string          `json:"request_id"`
+	Response  json.RawMessage `json:"response,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// ControlResponseMessage answers a control request.
+type ControlResponseMessage struct {
+	messageRaw
+	Type     MessageType     `json:"type"`
+	Response ControlResponse `json:"response"`
+}
+
+func (m *ControlResponseMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *ControlResponseMessage) UnmarshalJSON(data []byte) error {
+	type alias ControlResponseMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = ControlResponseMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
+// ControlCancelRequestMessage withdraws a pending control request, e.g. a
+// permission prompt made moot by an interrupt.
+type ControlCancelRequestMessage struct {
+	messageRaw
+	Type      MessageType `json:"type"`
+	RequestID string      `json:"request_id"`
+}
+
+func (m *ControlCancelRequestMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *ControlCancelRequestMessage) UnmarshalJSON(data []byte) error {
+	type alias ControlCancelRequestMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = ControlCancelRequestMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
+// ControlError is a control request rejected by the CLI.
+type ControlError struct {
+	RequestID string
+	Subtype   ControlSubtype
+	Message   string
+}
+
+func (e *ControlError) Error() string {
+	return fmt.Sprintf("control request %s (%s) failed: %s", e.RequestID, e.Subtype, e.Message)
+}
+
+var controlRequestSeq atomic.Int64
+
+// sendControlRequest writes req on the control channel and returns its
+// request id. The CLI only reads control requests with stream-json input.
+func (c *Client) sendControlRequest(ctx context.Context, req ControlRequest) (string, error) {
+	if !c.streamingInput {
+		return "", fmt.Errorf("control request %s requires WithStreamingInput", req.Subtype)
+	}
+	requestID := fmt.Sprintf("req_%d", controlRequestSeq.Add(1))
+	line, err := json.Marshal(struct {
+		Type      MessageType    `json:"type"`
+		RequestID string         `json:"request_id"`
+		Request   ControlRequest `json:"request"`
+	}{MessageTypeControlRequest, requestID, req})
+	if err != nil {
+		return "", fmt.Errorf("marshal control request: %w", err)
+	}
+	if err := c.protocol.SendUserInput(ctx, UserInput{Type: UserInputTypeRaw, Raw: string(line) + "\n"}); err != nil {
+		return "", fmt.Errorf("send control request: %w", err)
+	}
+	return requestID, nil
+}
+
+func (c *Client) sendControlResponse(ctx context.Context, resp ControlResponse) error {
+	line, err := json.Marshal(struct {
+		Type     MessageType     `json:"type"`
+		Response ControlResponse `json:"response"`
+	}{MessageTypeControlResponse, resp})
+	if err != nil {
+		return fmt.Errorf("marshal control response: %w", err)
+	}
+	if err := c.protocol.SendUserInput(ctx, UserInput{Type: UserInputTypeRaw, Raw: string(line) + "\n"}); err != nil {
+		return fmt.Errorf("send control response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// SubagentToolName is the tool the main agent uses to start a subagent.
+const SubagentToolName = "Task"
+
+type SubagentStatus string
+
+const (
+	SubagentStatusRunning   SubagentStatus = "running"
+	SubagentStatusCompleted SubagentStatus = "completed"
+	SubagentStatusFailed    SubagentStatus = "failed"
+)
+
+// ConversationNode is the main conversation (the root, with an empty
+// ToolUseID) or one subagent started by a Task tool use. Messages holds the
+// assistant and user messages produced at this level, in stream order.
+type ConversationNode struct {
+	ToolUseID    string
+	SubagentType string
+	Description  string
+	Prompt       string
+	Status       SubagentStatus
+	// Result is the subagent's final answer, taken from the Task tool
+	// result; for the root it is the result message text.
+	Result   string
+	Messages []Message
+	Parent   *ConversationNode
+	Children []*ConversationNode
+
+	usage map[string]*costMessage
+	order []string
+}
+
+// Cost estimates this node's own spend from assistant usage, excluding
+// children.
+func (n *ConversationNode) Cost(prices PriceTable) CostTotals {
+	var total CostTotals
+	for _, id := range n.order {
+		msg := n.usage[id]
+		totals := CostTotals{
+			InputTokens:              msg.usage.InputTokens,
+			OutputTokens:             msg.usage.OutputTokens,
+			CacheReadInputTokens:     msg.usage.CacheReadInputTokens,
+			CacheCreationInputTokens: msg.usage.CacheCreationInputToken,
+			Estimated:                true,
+		}
+		if cost, ok := prices.Cost(msg.model, msg.usage); ok {
+			totals.CostUSD = cost
+		}
+		total.add(totals)
+	}
+	return total
+}
+
+// TotalCost is Cost including every descendant subagent.
+func (n *ConversationNode) TotalCost(prices PriceTable) CostTotals {
+	total := n.Cost(prices)
+	for _, child := range n.Children {
+		total.add(child.TotalCost(prices))
+	}
+	return total
+}
+
+// Walk visits n and its descendants depth first.
+func (n *ConversationNode) Walk(fn func(n *ConversationNode, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *ConversationNode) walk(fn func(n *ConversationNode, depth int), depth int) {
+	fn(n, depth)
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+func (n *ConversationNode) recordUsage(id, model string, usage Usage) {
+	if n.usage == nil {
+		n.usage = map[string]*costMessage{}
+	}
+	msg, ok := n.usage[id]
+	if !ok {
+		msg = &costMessage{}
+		n.usage[id] = msg
+		n.order = append(n.order, id)
+	}
+	msg.merge(model, usage)
+}
+
+// ConversationTree nests the messages of Task subagents under the tool use
+// that started them, using ParentToolUseID. Attach it with
+// ClientBuilder.WithConversationTree or feed messages to Observe; the tree
+// must not be read while Observe runs on another goroutine.
+type ConversationTree struct {
+	prices PriceTable
+
+	mu        sync.Mutex
+	root      *ConversationNode
+	byToolUse map[string]*ConversationNode
+}
+
+// NewConversationTree creates an empty tree estimating cost with prices;
+// nil uses DefaultPriceTable.
+func NewConversationTree(prices PriceTable) *ConversationTree {
+	if prices == nil {
+		prices = DefaultPriceTable()
+	}
+	return &ConversationTree{
+		prices:    prices,
+		root:      &ConversationNode{Status: SubagentStatusRunning},
+		byToolUse: map[string]*ConversationNode{},
+	}
+}
+
+func (t *ConversationTree) Root() *ConversationNode {
+	return t.root
+}
+
+// Subagent returns the node started by the Task tool use toolUseID.
+func (t *ConversationTree) Subagent(toolUseID string) (*ConversationNode, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.byToolUse[toolUseID]
+	return n, ok
+}
+
+func (t *ConversationTree) observe(ctx context.Context, msg Message) error {
+	t.Observe(msg)
+	return nil
+}
+
+func (t *ConversationTree) Observe(msg Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		node := t.nodeFor(m.ParentToolUseID)
+		node.Messages = append(node.Messages, m)
+		if m.Message.Usage != nil {
+			id := m.Message.ID
+			if id == "" {
+				id = m.UUID
+			}
+			node.recordUsage(id, m.Message.Model, *m.Message.Usage)
+		}
+		for _, block := range m.Message.Content {
+			if block.ToolUse != nil && block.ToolUse.Name == SubagentToolName {
+				t.startSubagent(node, block.ToolUse)
+			}
+		}
+	case *UserMessage:
+		node := t.nodeFor(m.ParentToolUseID)
+		node.Messages = append(node.Messages, m)
+		for _, block := range m.Message.Content {
+			if block.ToolResult == nil {
+				continue
+			}
+			child, ok := t.byToolUse[block.ToolResult.ToolUseID]
+			if !ok {
+				continue
+			}
+			child.Result = block.ToolResult.Text()
+			child.Status = SubagentStatusCompleted
+			if block.ToolResult.IsError {
+				child.Status = SubagentStatusFailed
+			}
+		}
+	case *ResultMessage:
+		t.root.Result = m.Result
+		t.root.Status = SubagentStatusCompleted
+		if m.IsError {
+			t.root.Status = SubagentStatusFailed
+		}
+	}
+}
+
+// nodeFor returns the node messages with parentToolUseID belong to. A
+// parent not seen yet, e.g. when observation started mid-stream, gets a
+// placeholder under the root.
+func (t *ConversationTree) nodeFor(parentToolUseID *string) *ConversationNode {
+	if parentToolUseID == nil || *parentToolUseID == "" {
+		return t.root
+	}
+	if n, ok := t.byToolUse[*parentToolUseID]; ok {
+		return n
+	}
+	n := &ConversationNode{ToolUseID: *parentToolUseID, Status: SubagentStatusRunning, Parent: t.root}
+	t.root.Children = append(t.root.Children, n)
+	t.byToolUse[n.ToolUseID] = n
+	return n
+}
+
+func (t *ConversationTree) startSubagent(parent *ConversationNode, use *ToolUseContentBlock) {
+	var input struct {
+		Description  string `json:"description"`
+		Prompt       string `json:"prompt"`
+		SubagentType string `json:"subagent_type"`
+	}
+	_ = json.Unmarshal(use.Input, &input)
+
+	n, ok := t.byToolUse[use.ID]
+	if !ok {
+		n = &ConversationNode{ToolUseID: use.ID, Status: SubagentStatusRunning}
+		t.byToolUse[use.ID] = n
+	} else if n.Parent != nil {
+		// A placeholder created before its Task tool use was seen.
+		n.Parent.Children = removeNode(n.Parent.Children, n)
+	}
+	n.SubagentType = input.SubagentType
+	n.Description = input.Description
+	n.Prompt = input.Prompt
+	n.Parent = parent
+	parent.Children = append(parent.Children, n)
+}
+
+func removeNode(nodes []*ConversationNode, target *ConversationNode) []*ConversationNode {
+	out := nodes[:0]
+	for _, n := range nodes {
+		if n != target {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Render writes an indented outline of the tree, one line per node with
+// its status, message count and estimated cost including subagents.
+func (t *ConversationTree) Render(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var err error
+	t.root.Walk(func(n *ConversationNode, depth int) {
+		if err != nil {
+			return
+		}
+		label := "main"
+		if n.ToolUseID != "" {
+			label = n.ToolUseID
+			if n.SubagentType != "" {
+				label += " " + n.SubagentType
+			}
+			if n.Description != "" {
+				label += ": " + n.Description
+			}
+		}
+		cost := n.TotalCost(t.prices)
+		_, err = fmt.Fprintf(w, "%s%s [%s] messages=%d cost=$%.4f\n",
+			strings.Repeat("  ", depth), label, n.Status, len(n.Messages), cost.CostUSD)
+	})
+	return err
+}
+
+func (t *ConversationTree) String() string {
+	var b strings.Builder
+	_ = t.Render(&b)
+	return b.String()
+}
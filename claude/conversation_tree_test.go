@@ -0,0 +1,64 @@
+package claude
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestConversationTree(t *testing.T) {
+	tree := NewConversationTree(nil)
+	for _, msg := range parseLines(t,
+		`{"type":"assistant","message":{"id":"m1","model":"claude-sonnet-4-5","content":[{"type":"tool_use","id":"t1","name":"Task","input":{"description":"Find tests","prompt":"List test files","subagent_type":"Explore"}},{"type":"tool_use","id":"t2","name":"Task","input":{"description":"Lint","prompt":"Run lint","subagent_type":"general-purpose"}}],"usage":{"input_tokens":1000000}}}`,
+		`{"type":"assistant","parent_tool_use_id":"t1","message":{"id":"m2","model":"claude-haiku-4-5","content":[{"type":"tool_use","id":"g1","name":"Glob","input":{}}],"usage":{"input_tokens":1000000}}}`,
+		`{"type":"user","parent_tool_use_id":"t1","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"g1","content":"a_test.go"}]}}`,
+		`{"type":"assistant","parent_tool_use_id":"t1","message":{"id":"m2","model":"claude-haiku-4-5","content":[{"type":"text","text":"found"}],"usage":{"input_tokens":1000000}}}`,
+		`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"text","text":"a_test.go"},{"type":"text","text":"b_test.go"}]}]}}`,
+		`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":"lint crashed","is_error":true}]}}`,
+		`{"type":"assistant","parent_tool_use_id":"t9","message":{"id":"m3","content":[{"type":"text","text":"orphan"}]}}`,
+		`{"type":"result","subtype":"success","result":"done"}`,
+	) {
+		tree.Observe(msg)
+	}
+
+	root := tree.Root()
+	if root.Status != SubagentStatusCompleted || root.Result != "done" || len(root.Messages) != 3 {
+		t.Fatalf("root = %+v", root)
+	}
+	if len(root.Children) != 3 {
+		t.Fatalf("root children = %d, want 3", len(root.Children))
+	}
+
+	explore, ok := tree.Subagent("t1")
+	if !ok || explore.Parent != root || explore.SubagentType != "Explore" || explore.Description != "Find tests" {
+		t.Fatalf("t1 = %+v", explore)
+	}
+	if explore.Status != SubagentStatusCompleted || explore.Result != "a_test.go\nb_test.go" || len(explore.Messages) != 3 {
+		t.Fatalf("t1 = %+v", explore)
+	}
+	if cost := explore.Cost(DefaultPriceTable()); math.Abs(cost.CostUSD-0.8) > 1e-9 {
+		t.Fatalf("t1 cost = %+v, want 0.8 counted once per message id", cost)
+	}
+	if cost := root.TotalCost(DefaultPriceTable()); math.Abs(cost.CostUSD-3.8) > 1e-9 {
+		t.Fatalf("total cost = %+v, want 3.8", cost)
+	}
+
+	lint, _ := tree.Subagent("t2")
+	if lint.Status != SubagentStatusFailed || lint.Result != "lint crashed" {
+		t.Fatalf("t2 = %+v", lint)
+	}
+	orphan, _ := tree.Subagent("t9")
+	if orphan.Parent != root || orphan.Status != SubagentStatusRunning {
+		t.Fatalf("t9 = %+v", orphan)
+	}
+
+	want := strings.Join([]string{
+		"main [completed] messages=3 cost=$3.8000",
+		"  t1 Explore: Find tests [completed] messages=3 cost=$0.8000",
+		"  t2 general-purpose: Lint [failed] messages=0 cost=$0.0000",
+		"  t9 [running] messages=1 cost=$0.0000",
+	}, "\n") + "\n"
+	if got := tree.String(); got != want {
+		t.Fatalf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,490 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ModelPrice is a model's price in USD per million tokens.
+type ModelPrice struct {
+	InputPerMTok      float64
+	OutputPerMTok     float64
+	CacheReadPerMTok  float64
+	CacheWritePerMTok float64
+}
+
+// PriceTable maps model names, or name prefixes such as
+// "claude-sonnet-4-5", to prices. It is only consulted when the CLI does not
+// report costUSD itself.
+type PriceTable map[string]ModelPrice
+
+// DefaultPriceTable returns the example rates from spec §12.4: cache reads
+// cost 10% of input and cache writes cost the same as input.
+func DefaultPriceTable() PriceTable {
+	return PriceTable{
+		"claude-opus-4-5":   {InputPerMTok: 15, OutputPerMTok: 75, CacheReadPerMTok: 1.5, CacheWritePerMTok: 15},
+		"claude-sonnet-4-5": {InputPerMTok: 3, OutputPerMTok: 15, CacheReadPerMTok: 0.3, CacheWritePerMTok: 3},
+		"claude-haiku-4-5":  {InputPerMTok: 0.8, OutputPerMTok: 4, CacheReadPerMTok: 0.08, CacheWritePerMTok: 0.8},
+	}
+}
+
+// Lookup returns the price for model, matching the longest table key that
+// model equals or starts with so dated ids like
+// claude-sonnet-4-5-20250929 resolve.
+func (t PriceTable) Lookup(model string) (ModelPrice, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+	best := ""
+	for key := range t {
+		if strings.HasPrefix(model, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return t[best], true
+}
+
+// Cost prices usage for model as in spec §12.4.
+func (t PriceTable) Cost(model string, usage Usage) (float64, bool) {
+	price, ok := t.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	const mtok = 1_000_000
+	return float64(usage.InputTokens)/mtok*price.InputPerMTok +
+		float64(usage.OutputTokens)/mtok*price.OutputPerMTok +
+		float64(usage.CacheReadInputTokens)/mtok*price.CacheReadPerMTok +
+		float64(usage.CacheCreationInputToken)/mtok*price.CacheWritePerMTok, true
+}
+
+// CostTotals is an aggregate of token counts and spend. Estimated is set
+// when any part of CostUSD came from the price table or from streaming
+// usage rather than a CLI-reported cost.
+type CostTotals struct {
+	InputTokens              int64
+	OutputTokens             int64
+	CacheReadInputTokens     int64
+	CacheCreationInputTokens int64
+	CostUSD                  float64
+	Estimated                bool
+}
+
+func (t *CostTotals) add(o CostTotals) {
+	t.InputTokens += o.InputTokens
+	t.OutputTokens += o.OutputTokens
+	t.CacheReadInputTokens += o.CacheReadInputTokens
+	t.CacheCreationInputTokens += o.CacheCreationInputTokens
+	t.CostUSD += o.CostUSD
+	t.Estimated = t.Estimated || o.Estimated
+}
+
+func (t CostTotals) usage() Usage {
+	return Usage{
+		InputTokens:             t.InputTokens,
+		OutputTokens:            t.OutputTokens,
+		CacheReadInputTokens:    t.CacheReadInputTokens,
+		CacheCreationInputToken: t.CacheCreationInputTokens,
+	}
+}
+
+func (t CostTotals) isZero() bool {
+	return t == CostTotals{}
+}
+
+// TurnCost is the spend of one turn, from the first message after a result
+// up to the next result. While Complete is false the figures are running
+// estimates from assistant usage.
+type TurnCost struct {
+	SessionID string
+	Tags      []string
+	Models    map[string]CostTotals
+	Complete  bool
+}
+
+func (t TurnCost) Total() CostTotals {
+	var total CostTotals
+	for _, m := range t.Models {
+		total.add(m)
+	}
+	return total
+}
+
+func (t TurnCost) hasTag(tag string) bool {
+	for _, v := range t.Tags {
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// CostTracker aggregates spend per turn, session, model and caller tag
+// across any number of clients. Attach it with ClientBuilder.WithCostTracker
+// or feed messages to Observe.
+type CostTracker struct {
+	prices PriceTable
+
+	mu      sync.Mutex
+	turns   []TurnCost
+	streams []*costStream
+	direct  *costStream
+}
+
+// NewCostTracker creates a tracker pricing estimates with prices; nil uses
+// DefaultPriceTable.
+func NewCostTracker(prices PriceTable) *CostTracker {
+	if prices == nil {
+		prices = DefaultPriceTable()
+	}
+	t := &CostTracker{prices: prices}
+	t.direct = t.newStream(nil)
+	return t
+}
+
+// newStream registers one message stream, normally one client process,
+// whose cumulative result figures are tracked independently.
+func (t *CostTracker) newStream(tags []string) *costStream {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &costStream{
+		tracker:    t,
+		tags:       append([]string(nil), tags...),
+		messages:   map[string]*costMessage{},
+		lastModels: map[string]ModelUsage{},
+	}
+	t.streams = append(t.streams, s)
+	return s
+}
+
+// Observe records msg on the tracker's own stream. Messages from several
+// CLI processes must not be mixed here since result totals are cumulative
+// per process; use WithCostTracker for each client instead.
+func (t *CostTracker) Observe(msg Message) {
+	t.direct.observe(msg)
+}
+
+func (t *CostTracker) runningTurn(s *costStream) (TurnCost, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return s.running()
+}
+
+func (t *CostTracker) Turns() []TurnCost {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]TurnCost, 0, len(t.turns)+len(t.streams))
+	for _, turn := range t.turns {
+		out = append(out, cloneTurn(turn))
+	}
+	for _, s := range t.streams {
+		if turn, ok := s.running(); ok {
+			out = append(out, turn)
+		}
+	}
+	return out
+}
+
+// Total returns spend across all turns, including running estimates for
+// turns in progress.
+func (t *CostTracker) Total() CostTotals {
+	return t.sum(func(TurnCost) bool { return true })
+}
+
+func (t *CostTracker) Session(sessionID string) CostTotals {
+	return t.sum(func(turn TurnCost) bool { return turn.SessionID == sessionID })
+}
+
+func (t *CostTracker) Tag(tag string) CostTotals {
+	return t.sum(func(turn TurnCost) bool { return turn.hasTag(tag) })
+}
+
+func (t *CostTracker) Model(model string) CostTotals {
+	var total CostTotals
+	for _, turn := range t.Turns() {
+		if m, ok := turn.Models[model]; ok {
+			total.add(m)
+		}
+	}
+	return total
+}
+
+// Models returns the names of all models that incurred spend, sorted.
+func (t *CostTracker) Models() []string {
+	seen := map[string]bool{}
+	for _, turn := range t.Turns() {
+		for model := range turn.Models {
+			seen[model] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for model := range seen {
+		out = append(out, model)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (t *CostTracker) sum(match func(TurnCost) bool) CostTotals {
+	var total CostTotals
+	for _, turn := range t.Turns() {
+		if match(turn) {
+			total.add(turn.Total())
+		}
+	}
+	return total
+}
+
+func cloneTurn(turn TurnCost) TurnCost {
+	out := turn
+	out.Tags = append([]string(nil), turn.Tags...)
+	out.Models = make(map[string]CostTotals, len(turn.Models))
+	for k, v := range turn.Models {
+		out.Models[k] = v
+	}
+	return out
+}
+
+// costMessage is the latest usage seen for one API message. The CLI repeats
+// an assistant message once per content block with the same id and usage,
+// so usage is merged per id rather than summed.
+type costMessage struct {
+	model string
+	usage Usage
+}
+
+// merge folds a repeated or streamed usage for the message into m.
+// Streaming and repeated assistant usages are cumulative, so each field
+// keeps its maximum.
+func (m *costMessage) merge(model string, usage Usage) {
+	if model != "" {
+		m.model = model
+	}
+	m.usage.InputTokens = max(m.usage.InputTokens, usage.InputTokens)
+	m.usage.OutputTokens = max(m.usage.OutputTokens, usage.OutputTokens)
+	m.usage.CacheReadInputTokens = max(m.usage.CacheReadInputTokens, usage.CacheReadInputTokens)
+	m.usage.CacheCreationInputToken = max(m.usage.CacheCreationInputToken, usage.CacheCreationInputToken)
+}
+
+// costStream tracks one message stream. Its fields are guarded by
+// tracker.mu.
+type costStream struct {
+	tracker *CostTracker
+	tags    []string
+
+	sessionID   string
+	model       string
+	inTurn      bool
+	messages    map[string]*costMessage
+	order       []string
+	streamingID string
+
+	lastTotalUSD float64
+	lastModels   map[string]ModelUsage
+}
+
+func (s *costStream) observeContext(ctx context.Context, msg Message) error {
+	s.observe(msg)
+	return nil
+}
+
+func (s *costStream) observe(msg Message) {
+	t := s.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *SystemInitMessage:
+		if m.SessionID != "" {
+			s.sessionID = m.SessionID
+		}
+		if m.Model != "" {
+			s.model = m.Model
+		}
+	case *AssistantMessage:
+		s.setSession(m.SessionID)
+		s.inTurn = true
+		if m.Message.Usage != nil {
+			id := m.Message.ID
+			if id == "" {
+				id = m.UUID
+			}
+			s.record(id, m.Message.Model, *m.Message.Usage)
+		}
+	case *StreamEventMessage:
+		s.setSession(m.SessionID)
+		s.inTurn = true
+		s.observeEvent(m.Event)
+	case *ResultMessage:
+		s.setSession(m.SessionID)
+		turn := s.finish(m)
+		t.turns = append(t.turns, turn)
+		s.reset()
+	}
+}
+
+func (s *costStream) setSession(id string) {
+	if id != "" {
+		s.sessionID = id
+	}
+}
+
+func (s *costStream) observeEvent(event StreamEvent) {
+	switch {
+	case event.MessageStart != nil:
+		var start struct {
+			ID    string `json:"id"`
+			Model string `json:"model"`
+			Usage *Usage `json:"usage"`
+		}
+		if err := json.Unmarshal(event.MessageStart.Message, &start); err != nil {
+			return
+		}
+		s.streamingID = start.ID
+		usage := Usage{}
+		if start.Usage != nil {
+			usage = *start.Usage
+		}
+		s.record(start.ID, start.Model, usage)
+	case event.MessageDelta != nil && len(event.MessageDelta.Usage) > 0:
+		var usage Usage
+		if err := json.Unmarshal(event.MessageDelta.Usage, &usage); err != nil {
+			return
+		}
+		s.record(s.streamingID, "", usage)
+	}
+}
+
+// record merges usage into the message's running usage.
+func (s *costStream) record(id, model string, usage Usage) {
+	msg, ok := s.messages[id]
+	if !ok {
+		msg = &costMessage{}
+		s.messages[id] = msg
+		s.order = append(s.order, id)
+	}
+	msg.merge(model, usage)
+}
+
+// estimate prices the usage seen so far in the current turn.
+func (s *costStream) estimate() map[string]CostTotals {
+	models := map[string]CostTotals{}
+	for _, id := range s.order {
+		msg := s.messages[id]
+		model := msg.model
+		if model == "" {
+			model = s.model
+		}
+		totals := CostTotals{
+			InputTokens:              msg.usage.InputTokens,
+			OutputTokens:             msg.usage.OutputTokens,
+			CacheReadInputTokens:     msg.usage.CacheReadInputTokens,
+			CacheCreationInputTokens: msg.usage.CacheCreationInputToken,
+			Estimated:                true,
+		}
+		if cost, ok := s.tracker.prices.Cost(model, msg.usage); ok {
+			totals.CostUSD = cost
+		}
+		agg := models[model]
+		agg.add(totals)
+		models[model] = agg
+	}
+	return models
+}
+
+func (s *costStream) running() (TurnCost, bool) {
+	if !s.inTurn || len(s.order) == 0 {
+		return TurnCost{}, false
+	}
+	return TurnCost{
+		SessionID: s.sessionID,
+		Tags:      append([]string(nil), s.tags...),
+		Models:    s.estimate(),
+	}, true
+}
+
+// finish builds the completed turn from the result. modelUsage and
+// total_cost_usd are cumulative for the CLI process, so only the change
+// since the previous result is attributed to this turn. A missing costUSD
+// falls back to the price table.
+func (s *costStream) finish(r *ResultMessage) TurnCost {
+	turn := TurnCost{
+		SessionID: s.sessionID,
+		Tags:      append([]string(nil), s.tags...),
+		Models:    map[string]CostTotals{},
+		Complete:  true,
+	}
+
+	totalDelta := r.TotalCostUSD - s.lastTotalUSD
+	if totalDelta < 0 {
+		totalDelta = r.TotalCostUSD
+	}
+	s.lastTotalUSD = r.TotalCostUSD
+
+	if len(r.ModelUsage) > 0 {
+		for model, mu := range r.ModelUsage {
+			delta := diffModelUsage(mu, s.lastModels[model])
+			s.lastModels[model] = mu
+			totals := CostTotals{
+				InputTokens:              delta.InputTokens,
+				OutputTokens:             delta.OutputTokens,
+				CacheReadInputTokens:     delta.CacheReadInputTokens,
+				CacheCreationInputTokens: delta.CacheCreationInputToken,
+				CostUSD:                  delta.CostUSD,
+			}
+			if totals.isZero() {
+				continue
+			}
+			if totals.CostUSD == 0 {
+				if cost, ok := s.tracker.prices.Cost(model, totals.usage()); ok && cost > 0 {
+					totals.CostUSD = cost
+					totals.Estimated = true
+				}
+			}
+			turn.Models[model] = totals
+		}
+		return turn
+	}
+
+	turn.Models = s.estimate()
+	if totalDelta > 0 {
+		// The CLI reported the turn's cost without a per-model split;
+		// attribute it to the only model, or to the session model.
+		if len(turn.Models) == 1 {
+			for model, totals := range turn.Models {
+				totals.CostUSD = totalDelta
+				totals.Estimated = false
+				turn.Models[model] = totals
+			}
+		} else if len(turn.Models) == 0 {
+			turn.Models[s.model] = CostTotals{CostUSD: totalDelta}
+		}
+	}
+	return turn
+}
+
+func diffModelUsage(cur, prev ModelUsage) ModelUsage {
+	d := ModelUsage{
+		InputTokens:             cur.InputTokens - prev.InputTokens,
+		OutputTokens:            cur.OutputTokens - prev.OutputTokens,
+		CacheReadInputTokens:    cur.CacheReadInputTokens - prev.CacheReadInputTokens,
+		CacheCreationInputToken: cur.CacheCreationInputToken - prev.CacheCreationInputToken,
+		CostUSD:                 cur.CostUSD - prev.CostUSD,
+	}
+	if d.InputTokens < 0 || d.OutputTokens < 0 || d.CacheReadInputTokens < 0 || d.CacheCreationInputToken < 0 || d.CostUSD < 0 {
+		return cur
+	}
+	return d
+}
+
+func (s *costStream) reset() {
+	s.inTurn = false
+	s.messages = map[string]*costMessage{}
+	s.order = nil
+	s.streamingID = ""
+}
@@ -0,0 +1,129 @@
+package claude
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseLines(t *testing.T, lines ...string) []Message {
+	t.Helper()
+	parser := NewMessageParser(strings.NewReader(""))
+	var out []Message
+	for _, line := range lines {
+		msg, err := parser.ParseLine([]byte(line))
+		if err != nil {
+			t.Fatalf("ParseLine(%s) error = %v", line, err)
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPriceTableCost(t *testing.T) {
+	prices := DefaultPriceTable()
+	price, ok := prices.Lookup("claude-sonnet-4-5-20250929")
+	if !ok || price.InputPerMTok != 3 {
+		t.Fatalf("Lookup() = %+v, %v; want sonnet price", price, ok)
+	}
+	if _, ok := prices.Lookup("gpt-4"); ok {
+		t.Fatalf("Lookup(gpt-4) ok = true")
+	}
+
+	cost, ok := prices.Cost("claude-sonnet-4-5", Usage{
+		InputTokens:             1_000_000,
+		OutputTokens:            100_000,
+		CacheReadInputTokens:    1_000_000,
+		CacheCreationInputToken: 1_000_000,
+	})
+	if !ok || !approxEqual(cost, 3+1.5+0.3+3) {
+		t.Fatalf("Cost() = %v, %v; want 7.8", cost, ok)
+	}
+}
+
+func TestCostTrackerTurns(t *testing.T) {
+	tracker := NewCostTracker(nil)
+	for _, msg := range parseLines(t,
+		`{"type":"system","subtype":"init","session_id":"s1","model":"claude-sonnet-4-5"}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"message_start","message":{"id":"m1","model":"claude-sonnet-4-5","usage":{"input_tokens":1000000,"output_tokens":1}}}}`,
+		`{"type":"stream_event","session_id":"s1","event":{"type":"message_delta","delta":{},"usage":{"output_tokens":100000}}}`,
+		`{"type":"assistant","session_id":"s1","message":{"id":"m1","model":"claude-sonnet-4-5","content":[{"type":"text","text":"a"}],"usage":{"input_tokens":1000000,"output_tokens":50}}}`,
+		`{"type":"assistant","session_id":"s1","message":{"id":"m1","model":"claude-sonnet-4-5","content":[{"type":"text","text":"b"}],"usage":{"input_tokens":1000000,"output_tokens":50}}}`,
+	) {
+		tracker.Observe(msg)
+	}
+
+	running := tracker.Total()
+	if !running.Estimated || running.InputTokens != 1_000_000 || running.OutputTokens != 100_000 || !approxEqual(running.CostUSD, 4.5) {
+		t.Fatalf("running total = %+v, want estimated 4.5 USD", running)
+	}
+	if turns := tracker.Turns(); len(turns) != 1 || turns[0].Complete {
+		t.Fatalf("turns = %+v, want one running turn", turns)
+	}
+
+	for _, msg := range parseLines(t,
+		`{"type":"result","subtype":"success","session_id":"s1","total_cost_usd":4,"modelUsage":{"claude-sonnet-4-5":{"inputTokens":1000000,"outputTokens":100000,"costUSD":4}}}`,
+		`{"type":"assistant","session_id":"s1","message":{"id":"m2","model":"claude-haiku-4-5","content":[],"usage":{"input_tokens":10}}}`,
+		`{"type":"result","subtype":"success","session_id":"s1","total_cost_usd":4,"modelUsage":{"claude-sonnet-4-5":{"inputTokens":1000000,"outputTokens":100000,"costUSD":4},"claude-haiku-4-5":{"inputTokens":1000000}}}`,
+	) {
+		tracker.Observe(msg)
+	}
+
+	turns := tracker.Turns()
+	if len(turns) != 2 || !turns[0].Complete || !turns[1].Complete {
+		t.Fatalf("turns = %+v, want two complete turns", turns)
+	}
+	first := turns[0].Total()
+	if first.Estimated || !approxEqual(first.CostUSD, 4) {
+		t.Fatalf("first turn = %+v, want reported 4 USD", first)
+	}
+	second := turns[1]
+	if _, ok := second.Models["claude-sonnet-4-5"]; ok {
+		t.Fatalf("second turn models = %v, want only the cumulative delta", second.Models)
+	}
+	haiku := second.Models["claude-haiku-4-5"]
+	if !haiku.Estimated || !approxEqual(haiku.CostUSD, 0.8) {
+		t.Fatalf("haiku = %+v, want price table fallback 0.8 USD", haiku)
+	}
+
+	if got := tracker.Session("s1"); !approxEqual(got.CostUSD, 4.8) {
+		t.Fatalf("Session() = %+v, want 4.8 USD", got)
+	}
+	if got := tracker.Model("claude-sonnet-4-5"); !approxEqual(got.CostUSD, 4) {
+		t.Fatalf("Model(sonnet) = %+v, want 4 USD", got)
+	}
+	if got, want := tracker.Models(), []string{"claude-haiku-4-5", "claude-sonnet-4-5"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Models() = %v, want %v", got, want)
+	}
+}
+
+func TestCostTrackerWithClientTags(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewCostTracker(nil)
+	for i, tag := range []string{"tenant-a", "tenant-b", "tenant-a"} {
+		cost := float64(i + 1)
+		client, err := newFakeCLI(sessionScript("s1", "/w", cost)).builder().
+			WithCostTracker(tracker, tag, "batch").
+			Build(ctx)
+		if err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+		drainClient(t, client)
+	}
+
+	if got := tracker.Tag("tenant-a"); !approxEqual(got.CostUSD, 4) {
+		t.Fatalf("Tag(tenant-a) = %+v, want 4 USD", got)
+	}
+	if got := tracker.Tag("batch"); !approxEqual(got.CostUSD, 6) {
+		t.Fatalf("Tag(batch) = %+v, want 6 USD", got)
+	}
+	if got := tracker.Model("claude-sonnet-4-5"); !approxEqual(got.CostUSD, 6) {
+		t.Fatalf("Model() = %+v, want cost attributed to the init model", got)
+	}
+}
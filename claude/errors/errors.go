@@ -5,7 +5,19 @@ import (
 	"io"
 )
 
-var ErrEOF = stderrors.New("claude: eof")
+var (
+	ErrEOF = stderrors.New("claude: eof")
+
+	ErrUnsupportedProtocolVersion = stderrors.New("claude: unsupported mcp protocol version")
+	ErrCapabilityNotSupported     = stderrors.New("claude: mcp capability not supported by server")
+	ErrMCPServerUnavailable       = stderrors.New("claude: required mcp server unavailable")
+	ErrSessionNotFound            = stderrors.New("claude: session not found")
+	ErrCheckpointNotFound         = stderrors.New("claude: checkpoint not found")
+	ErrBudgetExceeded             = stderrors.New("claude: budget exceeded")
+	ErrStructuredOutput           = stderrors.New("claude: invalid structured output")
+	ErrAuditChainBroken           = stderrors.New("claude: audit hash chain broken")
+	ErrPoolClosed                 = stderrors.New("claude: pool closed")
+)
 
 func IsEOF(err error) bool {
 	if err == nil {
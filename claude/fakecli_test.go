@@ -0,0 +1,49 @@
+package claude
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+)
+
+// fakeCLI replaces the claude binary with `sh -c script` and records the
+// arguments of every launch, so tests can script stream-json output without
+// a real CLI.
+type fakeCLI struct {
+	script string
+
+	mu       sync.Mutex
+	launches [][]string
+}
+
+func newFakeCLI(script string) *fakeCLI {
+	return &fakeCLI{script: script}
+}
+
+func (f *fakeCLI) builder() *ClientBuilder {
+	b := NewClientBuilder()
+	b.commandFactory = f.command
+	return b
+}
+
+func (f *fakeCLI) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	f.mu.Lock()
+	f.launches = append(f.launches, append([]string(nil), args...))
+	f.mu.Unlock()
+	return exec.CommandContext(ctx, "sh", append([]string{"-c", f.script, "fake-claude"}, args...)...)
+}
+
+func (f *fakeCLI) lastArgs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.launches) == 0 {
+		return nil
+	}
+	return f.launches[len(f.launches)-1]
+}
+
+func (f *fakeCLI) launchCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.launches)
+}
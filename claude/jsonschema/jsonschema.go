@@ -0,0 +1,412 @@
+// Package jsonschema validates JSON documents against the subset of JSON
+// Schema used by MCP tool schemas and Claude structured output.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError reports the first location where an instance does not
+// match its schema. Path is a JSON pointer into the instance.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("jsonschema: %s: %s", path, e.Message)
+}
+
+// Schema is the supported subset of a JSON Schema document.
+type Schema struct {
+	Type                 SchemaType         `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"-"`
+	NoAdditional         bool               `json:"-"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []json.RawMessage  `json:"enum,omitempty"`
+	Const                json.RawMessage    `json:"const,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// SchemaType holds the "type" keyword, which may be a single name or a list.
+type SchemaType []string
+
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *SchemaType) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '"' {
+		var name string
+		if err := json.Unmarshal(trimmed, &name); err != nil {
+			return err
+		}
+		*t = SchemaType{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(trimmed, &names); err != nil {
+		return fmt.Errorf("parse schema type: %w", err)
+	}
+	*t = SchemaType(names)
+	return nil
+}
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type alias Schema
+	data, err := json.Marshal((*alias)(s))
+	if err != nil {
+		return nil, err
+	}
+	var additional json.RawMessage
+	switch {
+	case s.NoAdditional:
+		additional = json.RawMessage("false")
+	case s.AdditionalProperties != nil:
+		additional, err = json.Marshal(s.AdditionalProperties)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return data, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["additionalProperties"] = additional
+	return json.Marshal(fields)
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type alias Schema
+	var decoded struct {
+		alias
+		AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*s = Schema(decoded.alias)
+	additional := bytes.TrimSpace(decoded.AdditionalProperties)
+	switch {
+	case len(additional) == 0, bytes.Equal(additional, []byte("true")):
+	case bytes.Equal(additional, []byte("false")):
+		s.NoAdditional = true
+	default:
+		var sub Schema
+		if err := json.Unmarshal(additional, &sub); err != nil {
+			return fmt.Errorf("parse additionalProperties: %w", err)
+		}
+		s.AdditionalProperties = &sub
+	}
+	return nil
+}
+
+// Parse decodes a raw schema document.
+func Parse(schema []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return nil, fmt.Errorf("parse json schema: %w", err)
+	}
+	return &s, nil
+}
+
+// Validate checks instance against schema. It returns a *ValidationError
+// when the instance does not match and a plain error when either document
+// is not valid JSON.
+func Validate(schema []byte, instance []byte) error {
+	s, err := Parse(schema)
+	if err != nil {
+		return err
+	}
+	return s.Validate(instance)
+}
+
+// Validate checks instance against the schema.
+func (s *Schema) Validate(instance []byte) error {
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(instance))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("parse json instance: %w", err)
+	}
+	return s.validate("", value)
+}
+
+func (s *Schema) validate(path string, value interface{}) error {
+	if s == nil {
+		return nil
+	}
+
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected %s, got %s", strings.Join(s.Type, " or "), typeName(value))}
+	}
+	if len(s.Const) > 0 && !equalJSON(s.Const, value) {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("must equal %s", string(s.Const))}
+	}
+	if len(s.Enum) > 0 {
+		matched := false
+		for _, candidate := range s.Enum {
+			if equalJSON(candidate, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return &ValidationError{Path: path, Message: "value is not one of the allowed enum values"}
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if err := s.validateObject(path, v); err != nil {
+			return err
+		}
+	case []interface{}:
+		if err := s.validateArray(path, v); err != nil {
+			return err
+		}
+	case string:
+		if err := s.validateString(path, v); err != nil {
+			return err
+		}
+	case json.Number:
+		if err := s.validateNumber(path, v); err != nil {
+			return err
+		}
+	}
+
+	for _, sub := range s.AllOf {
+		if err := sub.validate(path, value); err != nil {
+			return err
+		}
+	}
+	if len(s.AnyOf) > 0 {
+		var firstErr error
+		matched := false
+		for _, sub := range s.AnyOf {
+			err := sub.validate(path, value)
+			if err == nil {
+				matched = true
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if !matched {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("does not match any schema in anyOf: %v", firstErr)}
+		}
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, sub := range s.OneOf {
+			if sub.validate(path, value) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("matches %d schemas in oneOf, want exactly 1", matches)}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(path string, v map[string]interface{}) error {
+	for _, name := range s.Required {
+		if _, ok := v[name]; !ok {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("missing required property %q", name)}
+		}
+	}
+
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "/" + escapePointer(key)
+		if prop, ok := s.Properties[key]; ok {
+			if err := prop.validate(childPath, v[key]); err != nil {
+				return err
+			}
+			continue
+		}
+		if s.NoAdditional {
+			return &ValidationError{Path: childPath, Message: "additional property is not allowed"}
+		}
+		if s.AdditionalProperties != nil {
+			if err := s.AdditionalProperties.validate(childPath, v[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateArray(path string, v []interface{}) error {
+	if s.MinItems != nil && len(v) < *s.MinItems {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("has %d items, want at least %d", len(v), *s.MinItems)}
+	}
+	if s.MaxItems != nil && len(v) > *s.MaxItems {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("has %d items, want at most %d", len(v), *s.MaxItems)}
+	}
+	if s.Items != nil {
+		for i, item := range v {
+			if err := s.Items.validate(path+"/"+strconv.Itoa(i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateString(path string, v string) error {
+	length := utf8.RuneCountInString(v)
+	if s.MinLength != nil && length < *s.MinLength {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("length %d is shorter than %d", length, *s.MinLength)}
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("length %d is longer than %d", length, *s.MaxLength)}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("compile schema pattern %q: %w", s.Pattern, err)
+		}
+		if !re.MatchString(v) {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("does not match pattern %q", s.Pattern)}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateNumber(path string, v json.Number) error {
+	f, err := v.Float64()
+	if err != nil {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("invalid number %s", v)}
+	}
+	if s.Minimum != nil && f < *s.Minimum {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("%s is less than minimum %v", v, *s.Minimum)}
+	}
+	if s.Maximum != nil && f > *s.Maximum {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("%s is greater than maximum %v", v, *s.Maximum)}
+	}
+	return nil
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	actual := typeName(value)
+	for _, want := range s.Type {
+		if want == actual {
+			return true
+		}
+		if want == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && !strings.ContainsAny(v.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func equalJSON(raw json.RawMessage, value interface{}) bool {
+	var want interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&want); err != nil {
+		return false
+	}
+	return equalValue(want, value)
+}
+
+func equalValue(a, b interface{}) bool {
+	switch av := a.(type) {
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aerr := av.Float64()
+		bf, berr := bv.Float64()
+		return aerr == nil && berr == nil && af == bf
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equalValue(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, item := range av {
+			other, ok := bv[key]
+			if !ok || !equalValue(item, other) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
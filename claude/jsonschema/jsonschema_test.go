@@ -0,0 +1,102 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const calculatorSchema = `{
+	"type": "object",
+	"properties": {
+		"operation": {"type": "string", "enum": ["add", "subtract", "multiply", "divide"]},
+		"a": {"type": "number"},
+		"b": {"type": "number"},
+		"tags": {"type": "array", "items": {"type": "string", "minLength": 1}, "maxItems": 2}
+	},
+	"required": ["operation", "a", "b"],
+	"additionalProperties": false
+}`
+
+func TestValidateAcceptsMatchingInstance(t *testing.T) {
+	err := Validate([]byte(calculatorSchema), []byte(`{"operation":"multiply","a":7,"b":6.5,"tags":["x"]}`))
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+}
+
+func TestValidateReportsPath(t *testing.T) {
+	cases := []struct {
+		name     string
+		instance string
+		path     string
+		contains string
+	}{
+		{name: "missing required", instance: `{"operation":"add","a":1}`, path: "", contains: `missing required property "b"`},
+		{name: "wrong type", instance: `{"operation":"add","a":"1","b":2}`, path: "/a", contains: "expected number, got string"},
+		{name: "enum", instance: `{"operation":"pow","a":1,"b":2}`, path: "/operation", contains: "enum"},
+		{name: "additional", instance: `{"operation":"add","a":1,"b":2,"c":3}`, path: "/c", contains: "additional property"},
+		{name: "array item", instance: `{"operation":"add","a":1,"b":2,"tags":["ok",""]}`, path: "/tags/1", contains: "shorter"},
+		{name: "max items", instance: `{"operation":"add","a":1,"b":2,"tags":["a","b","c"]}`, path: "/tags", contains: "at most 2"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate([]byte(calculatorSchema), []byte(tc.instance))
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			if verr.Path != tc.path {
+				t.Fatalf("Path = %q, want %q", verr.Path, tc.path)
+			}
+			if !strings.Contains(verr.Message, tc.contains) {
+				t.Fatalf("Message = %q, want contains %q", verr.Message, tc.contains)
+			}
+		})
+	}
+}
+
+func TestValidateIntegerAndCombinators(t *testing.T) {
+	schema := `{"anyOf":[{"type":"integer","minimum":1},{"type":"null"}]}`
+	if err := Validate([]byte(schema), []byte(`3`)); err != nil {
+		t.Fatalf("Validate(3) error = %v", err)
+	}
+	if err := Validate([]byte(schema), []byte(`null`)); err != nil {
+		t.Fatalf("Validate(null) error = %v", err)
+	}
+	if err := Validate([]byte(schema), []byte(`1.5`)); err == nil {
+		t.Fatalf("Validate(1.5) error = nil, want error")
+	}
+	if err := Validate([]byte(`{"oneOf":[{"type":"number"},{"type":"integer"}]}`), []byte(`2`)); err == nil {
+		t.Fatalf("oneOf with two matches error = nil, want error")
+	}
+}
+
+func TestValidateInvalidJSON(t *testing.T) {
+	err := Validate([]byte(`{"type":"object"}`), []byte(`{`))
+	if err == nil {
+		t.Fatalf("Validate() error = nil, want error")
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		t.Fatalf("Validate() error = %v, want parse error", err)
+	}
+}
+
+func TestSchemaRoundTripAdditionalProperties(t *testing.T) {
+	s, err := Parse([]byte(calculatorSchema))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !s.NoAdditional {
+		t.Fatalf("NoAdditional = false, want true")
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"additionalProperties":false`) {
+		t.Fatalf("marshaled = %s, want additionalProperties false", data)
+	}
+}
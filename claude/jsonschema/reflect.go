@@ -0,0 +1,216 @@
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// For derives a schema for T; see Reflect.
+func For[T any]() (*Schema, error) {
+	return Reflect(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// Reflect derives a schema from a Go type the way encoding/json would
+// encode it. Struct fields are named by their json tag and required unless
+// tagged omitempty; objects reject unknown properties. Constraints come
+// from a `jsonschema:"key=value,..."` tag with the keys enum (values
+// separated by |), minimum, maximum, minLength, maxLength, pattern,
+// minItems and maxItems, and descriptions from a `description` tag.
+// Recursive types are rejected.
+func Reflect(t reflect.Type) (*Schema, error) {
+	return reflectType(t, map[reflect.Type]bool{})
+}
+
+func reflectType(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: SchemaType{"string"}}, nil
+	case t == rawMessageType:
+		return &Schema{}, nil
+	case t.Kind() != reflect.Struct && reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: SchemaType{"string"}}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: SchemaType{"boolean"}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: SchemaType{"integer"}}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: SchemaType{"integer"}, Minimum: &zero}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: SchemaType{"number"}}, nil
+	case reflect.String:
+		return &Schema{Type: SchemaType{"string"}}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes []byte as base64.
+			return &Schema{Type: SchemaType{"string"}}, nil
+		}
+		items, err := reflectType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: SchemaType{"array"}, Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("jsonschema: unsupported map key type %s", t.Key())
+		}
+		values, err := reflectType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: SchemaType{"object"}, AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("jsonschema: recursive type %s", t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &Schema{Type: SchemaType{"object"}, Properties: map[string]*Schema{}, NoAdditional: true}
+		if err := reflectFields(t, s, visiting); err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("jsonschema: unsupported type %s", t)
+	}
+}
+
+func reflectFields(t reflect.Type, s *Schema, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := reflectFields(ft, s, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop, err := reflectType(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if desc := field.Tag.Get("description"); desc != "" {
+			prop.Description = desc
+		}
+		if err := applyConstraints(prop, field.Tag.Get("jsonschema")); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		s.Properties[name] = prop
+		if !hasOption(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+func hasOption(opts, want string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == want {
+			return true
+		}
+	}
+	return false
+}
+
+func applyConstraints(s *Schema, tag string) error {
+	if tag == "" {
+		return nil
+	}
+	for _, part := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("jsonschema tag %q: missing value", part)
+		}
+		switch key {
+		case "enum":
+			for _, v := range strings.Split(value, "|") {
+				raw, err := enumValue(s, v)
+				if err != nil {
+					return err
+				}
+				s.Enum = append(s.Enum, raw)
+			}
+		case "minimum", "maximum":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("jsonschema tag %s: %w", key, err)
+			}
+			if key == "minimum" {
+				s.Minimum = &n
+			} else {
+				s.Maximum = &n
+			}
+		case "minLength", "maxLength", "minItems", "maxItems":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("jsonschema tag %s: %w", key, err)
+			}
+			switch key {
+			case "minLength":
+				s.MinLength = &n
+			case "maxLength":
+				s.MaxLength = &n
+			case "minItems":
+				s.MinItems = &n
+			case "maxItems":
+				s.MaxItems = &n
+			}
+		case "pattern":
+			s.Pattern = value
+		default:
+			return fmt.Errorf("jsonschema tag: unknown key %q", key)
+		}
+	}
+	return nil
+}
+
+// enumValue encodes an enum tag value as a JSON literal of the field's
+// type.
+func enumValue(s *Schema, v string) (json.RawMessage, error) {
+	if len(s.Type) == 1 && s.Type[0] == "string" {
+		return json.Marshal(v)
+	}
+	if !json.Valid([]byte(v)) {
+		return nil, fmt.Errorf("jsonschema tag enum: %q is not a JSON value", v)
+	}
+	return json.RawMessage(v), nil
+}
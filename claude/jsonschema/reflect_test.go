@@ -0,0 +1,104 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type reflectBase struct {
+	ID string `json:"id"`
+}
+
+type reflectItem struct {
+	reflectBase
+	Operation string          `json:"operation" jsonschema:"enum=add|subtract" description:"Operation to apply"`
+	Score     int             `json:"score" jsonschema:"minimum=1,maximum=5"`
+	Count     uint            `json:"count,omitempty"`
+	Ratio     *float64        `json:"ratio,omitempty"`
+	Tags      []string        `json:"tags" jsonschema:"maxItems=2"`
+	Labels    map[string]bool `json:"labels,omitempty"`
+	Extra     json.RawMessage `json:"extra,omitempty"`
+	When      time.Time       `json:"when"`
+	Blob      []byte          `json:"blob,omitempty"`
+	Ignored   string          `json:"-"`
+	internal  string
+	Nested    []reflectNested   `json:"nested,omitempty"`
+	ByName    map[string]string `json:"by_name,omitempty"`
+}
+
+type reflectNested struct {
+	Name string
+}
+
+func TestForDerivesSchema(t *testing.T) {
+	s, err := For[reflectItem]()
+	if err != nil {
+		t.Fatalf("For() error = %v", err)
+	}
+	if want := []string{"id", "operation", "score", "tags", "when"}; !reflect.DeepEqual(s.Required, want) {
+		t.Fatalf("Required = %v, want %v", s.Required, want)
+	}
+	if !s.NoAdditional {
+		t.Fatalf("NoAdditional = false")
+	}
+	if _, ok := s.Properties["Ignored"]; ok {
+		t.Fatalf("json:\"-\" field included")
+	}
+	op := s.Properties["operation"]
+	if op.Description != "Operation to apply" || len(op.Enum) != 2 || string(op.Enum[1]) != `"subtract"` {
+		t.Fatalf("operation = %+v", op)
+	}
+	if got := s.Properties["count"]; got.Type[0] != "integer" || got.Minimum == nil || *got.Minimum != 0 {
+		t.Fatalf("count = %+v", got)
+	}
+	if got := s.Properties["nested"].Items; got.Properties["Name"] == nil || got.Required[0] != "Name" {
+		t.Fatalf("nested items = %+v", got)
+	}
+	if got := s.Properties["labels"].AdditionalProperties; got == nil || got.Type[0] != "boolean" {
+		t.Fatalf("labels = %+v", s.Properties["labels"])
+	}
+	if got := s.Properties["blob"]; got.Type[0] != "string" {
+		t.Fatalf("blob = %+v", got)
+	}
+
+	ok := `{"id":"1","operation":"add","score":3,"tags":["a"],"when":"2025-01-01T00:00:00Z","extra":{"any":1}}`
+	if err := s.Validate([]byte(ok)); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	var vErr *ValidationError
+	bad := `{"id":"1","operation":"add","score":9,"tags":[],"when":"x"}`
+	if err := s.Validate([]byte(bad)); !errors.As(err, &vErr) || vErr.Path != "/score" {
+		t.Fatalf("Validate() error = %v, want /score violation", err)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"additionalProperties":false`) {
+		t.Fatalf("schema json = %s", data)
+	}
+}
+
+type reflectLoop struct {
+	Next *reflectLoop `json:"next"`
+}
+
+func TestReflectErrors(t *testing.T) {
+	if _, err := For[reflectLoop](); err == nil || !strings.Contains(err.Error(), "recursive") {
+		t.Fatalf("For(recursive) error = %v", err)
+	}
+	if _, err := For[map[int]string](); err == nil {
+		t.Fatalf("For(map[int]string) error = nil")
+	}
+	type badTag struct {
+		N int `json:"n" jsonschema:"minimum=x"`
+	}
+	if _, err := For[badTag](); err == nil {
+		t.Fatalf("For(bad tag) error = nil")
+	}
+}
@@ -0,0 +1,220 @@
+// Package mcp is a standalone MCP client and server speaking plain JSON-RPC
+// 2.0 over stdio, Streamable HTTP or legacy SSE, for exercising MCP servers
+// directly rather than through the claude CLI.
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+var _ claude.MCPAPI = (*Client)(nil)
+
+type Client struct {
+	conn      *conn
+	transport Transport
+	cmd       *exec.Cmd
+
+	mu         sync.Mutex
+	serverCaps *claude.ServerCapabilities
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewClient speaks MCP over an existing stream pair.
+func NewClient(r io.Reader, w io.Writer) *Client {
+	return NewClientWithTransport(NewStdioTransport(r, w))
+}
+
+func NewClientWithTransport(t Transport) *Client {
+	return &Client{conn: newConn(t), transport: t}
+}
+
+// Start launches a stdio MCP server subprocess described by cfg. The
+// process is started with exec.CommandContext(ctx), so ctx bounds the
+// server's whole lifetime: pass a long-lived context and bound the
+// handshake with a separate one, or a handshake timeout kills the server.
+func Start(ctx context.Context, cfg ServerConfig) (*Client, error) {
+	if err := validateStdio(cfg); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, cfg.Command, cfg.Args...)
+	if len(cfg.Env) > 0 {
+		env := os.Environ()
+		for key, value := range cfg.Env {
+			env = append(env, key+"="+value)
+		}
+		cmd.Env = env
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("open stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("open stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		_ = stdin.Close()
+		_ = stdout.Close()
+		return nil, fmt.Errorf("start %s: %w", cfg.Command, err)
+	}
+
+	c := NewClient(stdout, stdin)
+	c.cmd = cmd
+	return c, nil
+}
+
+// Dial opens a client for cfg using the transport its type selects: a
+// subprocess for stdio, Streamable HTTP for http, and legacy SSE for sse.
+// For stdio and sse, ctx bounds the connection's lifetime, as for Start.
+func Dial(ctx context.Context, cfg ServerConfig) (*Client, error) {
+	switch cfg.Type {
+	case "", claude.MCPServerTypeStdio:
+		return Start(ctx, cfg)
+	case claude.MCPServerTypeHTTP:
+		if strings.TrimSpace(cfg.URL) == "" {
+			return nil, fmt.Errorf("mcp server url is empty")
+		}
+		return NewClientWithTransport(NewHTTPTransport(cfg.URL, cfg.Headers, nil)), nil
+	case claude.MCPServerTypeSSE:
+		if strings.TrimSpace(cfg.URL) == "" {
+			return nil, fmt.Errorf("mcp server url is empty")
+		}
+		t, err := DialSSE(ctx, cfg.URL, cfg.Headers, nil)
+		if err != nil {
+			return nil, err
+		}
+		return NewClientWithTransport(t), nil
+	default:
+		return nil, fmt.Errorf("unsupported mcp server type: %s", cfg.Type)
+	}
+}
+
+// Connect dials the server and performs the initialize handshake.
+func Connect(ctx context.Context, cfg ServerConfig, params claude.InitializeParams) (*Client, *claude.InitializeResult, error) {
+	c, err := Dial(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := c.MCPInitialize(ctx, params)
+	if err != nil {
+		_ = c.Close()
+		return nil, nil, err
+	}
+	if err := c.MCPInitialized(ctx); err != nil {
+		_ = c.Close()
+		return nil, nil, err
+	}
+	return c, result, nil
+}
+
+func (c *Client) MCPInitialize(ctx context.Context, params claude.InitializeParams) (*claude.InitializeResult, error) {
+	if params.ProtocolVersion == "" {
+		params.ProtocolVersion = claude.LatestMCPProtocolVersion
+	}
+
+	var out claude.InitializeResult
+	if err := c.conn.request(ctx, "initialize", params, &out); err != nil {
+		return nil, err
+	}
+	if !claude.IsSupportedMCPProtocolVersion(out.ProtocolVersion) {
+		return nil, fmt.Errorf("%w: server replied %q, supported %s", clerrors.ErrUnsupportedProtocolVersion, out.ProtocolVersion, strings.Join(claude.SupportedMCPProtocolVersions, ", "))
+	}
+
+	caps := out.Capabilities
+	c.mu.Lock()
+	c.serverCaps = &caps
+	c.mu.Unlock()
+	return &out, nil
+}
+
+// MCPInitialized sends the notifications/initialized notification that
+// completes the handshake.
+func (c *Client) MCPInitialized(ctx context.Context) error {
+	return c.conn.notify(ctx, "notifications/initialized", struct{}{})
+}
+
+func (c *Client) MCPToolsList(ctx context.Context) (*claude.ToolsListResult, error) {
+	if err := c.requireCapability(claude.MCPCapabilityTools); err != nil {
+		return nil, err
+	}
+
+	var out claude.ToolsListResult
+	if err := c.conn.request(ctx, "tools/list", struct{}{}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) MCPToolsCall(ctx context.Context, params claude.ToolsCallParams) (*claude.ToolsCallResult, error) {
+	if err := c.requireCapability(claude.MCPCapabilityTools); err != nil {
+		return nil, err
+	}
+
+	var out claude.ToolsCallResult
+	if err := c.conn.request(ctx, "tools/call", params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ServerCapabilities returns what the server advertised, or nil before
+// initialize.
+func (c *Client) ServerCapabilities() *claude.ServerCapabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.serverCaps == nil {
+		return nil
+	}
+	caps := *c.serverCaps
+	return &caps
+}
+
+func (c *Client) requireCapability(capability claude.MCPCapability) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.serverCaps == nil || c.serverCaps.Supports(capability) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", clerrors.ErrCapabilityNotSupported, capability)
+}
+
+// Close closes stdin so the server can exit on its own, and kills it if it
+// is still running after a short grace period.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.close()
+	})
+	return c.closeErr
+}
+
+func (c *Client) close() error {
+	if c.cmd == nil {
+		return c.transport.Close()
+	}
+
+	_ = c.transport.Close()
+	exited := make(chan error, 1)
+	go func() { exited <- c.cmd.Wait() }()
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(2 * time.Second):
+		_ = c.cmd.Process.Kill()
+		<-exited
+		return nil
+	}
+}
@@ -0,0 +1,247 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// The MCP client tests launch this test binary as a stdio server via
+// TestHelperMCPServer, so config command/args/env are exercised end to end.
+
+func helperServerConfig() ServerConfig {
+	return ServerConfig{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^TestHelperMCPServer$"},
+		Env:     map[string]string{"AGENTKIT_MCP_HELPER": "1", "AGENTKIT_MCP_GREETING": "hello from env"},
+	}
+}
+
+func TestHelperMCPServer(t *testing.T) {
+	if os.Getenv("AGENTKIT_MCP_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	serveHelper(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+func serveHelper(r io.Reader, w io.Writer) {
+	scanner := bufio.NewScanner(r)
+	enc := json.NewEncoder(w)
+	for scanner.Scan() {
+		var req struct {
+			ID     *int64          `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || req.ID == nil {
+			continue
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": *req.ID}
+		switch req.Method {
+		case "initialize":
+			var params claude.InitializeParams
+			_ = json.Unmarshal(req.Params, &params)
+			resp["result"] = map[string]interface{}{
+				"protocolVersion": params.ProtocolVersion,
+				"serverInfo":      map[string]string{"name": "helper", "version": "1.0.0"},
+				"capabilities":    map[string]interface{}{"tools": map[string]bool{"listChanged": false}},
+			}
+		case "tools/list":
+			resp["result"] = map[string]interface{}{"tools": []map[string]interface{}{
+				{"name": "greet", "description": "greets", "inputSchema": map[string]string{"type": "object"}},
+				{"name": "add", "description": "adds", "inputSchema": map[string]string{"type": "object"}},
+			}}
+		case "tools/call":
+			var params claude.ToolsCallParams
+			_ = json.Unmarshal(req.Params, &params)
+			switch params.Name {
+			case "greet":
+				resp["result"] = map[string]interface{}{"content": []map[string]string{{"type": "text", "text": os.Getenv("AGENTKIT_MCP_GREETING")}}}
+			case "add":
+				a, _ := params.Arguments["a"].(float64)
+				b, _ := params.Arguments["b"].(float64)
+				resp["result"] = map[string]interface{}{"content": []map[string]string{{"type": "text", "text": fmt.Sprint(a + b)}}}
+			default:
+				resp["result"] = map[string]interface{}{"content": []map[string]string{{"type": "text", "text": "Unknown tool"}}, "isError": true}
+			}
+		default:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "Method not found"}
+		}
+		_ = enc.Encode(resp)
+	}
+}
+
+func TestClientConnectListAndCallTools(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, init, err := Connect(ctx, helperServerConfig(), claude.InitializeParams{ClientInfo: claude.ClientInfo{Name: "agentkit-test"}})
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	if init.ServerInfo.Name != "helper" {
+		t.Fatalf("ServerInfo.Name = %q, want helper", init.ServerInfo.Name)
+	}
+	if init.ProtocolVersion != claude.LatestMCPProtocolVersion {
+		t.Fatalf("ProtocolVersion = %q, want %q", init.ProtocolVersion, claude.LatestMCPProtocolVersion)
+	}
+	if caps := client.ServerCapabilities(); caps == nil || caps.Tools == nil {
+		t.Fatalf("ServerCapabilities() = %+v, want tools", caps)
+	}
+
+	tools, err := client.MCPToolsList(ctx)
+	if err != nil {
+		t.Fatalf("MCPToolsList() error = %v", err)
+	}
+	if len(tools.Tools) != 2 || tools.Tools[0].Name != "greet" {
+		t.Fatalf("tools = %+v, want greet and add", tools.Tools)
+	}
+
+	greet, err := client.MCPToolsCall(ctx, claude.ToolsCallParams{Name: "greet"})
+	if err != nil {
+		t.Fatalf("MCPToolsCall(greet) error = %v", err)
+	}
+	if len(greet.Content) != 1 || greet.Content[0].Text != "hello from env" {
+		t.Fatalf("greet content = %+v, want env greeting", greet.Content)
+	}
+
+	add, err := client.MCPToolsCall(ctx, claude.ToolsCallParams{Name: "add", Arguments: map[string]interface{}{"a": 7, "b": 6}})
+	if err != nil {
+		t.Fatalf("MCPToolsCall(add) error = %v", err)
+	}
+	if len(add.Content) != 1 || add.Content[0].Text != "13" {
+		t.Fatalf("add content = %+v, want 13", add.Content)
+	}
+
+	unknown, err := client.MCPToolsCall(ctx, claude.ToolsCallParams{Name: "nope"})
+	if err != nil {
+		t.Fatalf("MCPToolsCall(nope) error = %v", err)
+	}
+	if !unknown.IsError {
+		t.Fatalf("IsError = false, want true")
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestClientConcurrentRequestsOverPipe(t *testing.T) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	go serveHelper(serverR, serverW)
+
+	client := NewClient(clientR, clientW)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		i := i
+		go func() {
+			res, err := client.MCPToolsCall(ctx, claude.ToolsCallParams{Name: "add", Arguments: map[string]interface{}{"a": i, "b": 1}})
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if got, want := res.Content[0].Text, fmt.Sprint(i+1); got != want {
+				errCh <- fmt.Errorf("add(%d, 1) = %s, want %s", i, got, want)
+				return
+			}
+			errCh <- nil
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClientRequestErrors(t *testing.T) {
+	ctx := context.Background()
+
+	client := NewClient(strings.NewReader(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`+"\n"), io.Discard)
+	if _, err := client.MCPToolsList(ctx); err == nil || !strings.Contains(err.Error(), "jsonrpc error -32601") {
+		t.Fatalf("MCPToolsList() error = %v, want jsonrpc error", err)
+	}
+
+	eof := NewClient(strings.NewReader(""), io.Discard)
+	if _, err := eof.MCPToolsList(ctx); !clerrors.IsEOF(err) {
+		t.Fatalf("MCPToolsList() error = %v, want EOF", err)
+	}
+
+	old := NewClient(strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2023-01-01"}}`+"\n"), io.Discard)
+	if _, err := old.MCPInitialize(ctx, claude.InitializeParams{}); !errors.Is(err, clerrors.ErrUnsupportedProtocolVersion) {
+		t.Fatalf("MCPInitialize() error = %v, want ErrUnsupportedProtocolVersion", err)
+	}
+
+	if _, err := Start(ctx, ServerConfig{Type: "sse"}); err == nil {
+		t.Fatalf("Start(sse) error = nil, want error")
+	}
+	if _, err := Start(ctx, ServerConfig{}); err == nil {
+		t.Fatalf("Start(empty command) error = nil, want error")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	data := `{
+  "mcpServers": {
+    "my-tools": {"command": "python", "args": ["mcp_server.py"], "env": {"API_KEY": "secret"}},
+    "remote-tools": {"type": "sse", "url": "https://mcp.example.com/sse"}
+  }
+}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	server, err := cfg.Server("my-tools")
+	if err != nil {
+		t.Fatalf("Server() error = %v", err)
+	}
+	if server.Command != "python" || len(server.Args) != 1 || server.Env["API_KEY"] != "secret" {
+		t.Fatalf("server = %+v, want python mcp_server.py", server)
+	}
+	if _, err := cfg.Server("missing"); err == nil {
+		t.Fatalf("Server(missing) error = nil, want error")
+	}
+}
+
+func TestClientInitializedNotification(t *testing.T) {
+	var out strings.Builder
+	client := NewClient(strings.NewReader(""), &out)
+	if err := client.MCPInitialized(context.Background()); err != nil {
+		t.Fatalf("MCPInitialized() error = %v", err)
+	}
+	var note struct {
+		Method string          `json:"method"`
+		ID     json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(out.String()), &note); err != nil {
+		t.Fatalf("decode %q: %v", out.String(), err)
+	}
+	if note.Method != "notifications/initialized" || note.ID != nil {
+		t.Fatalf("notification = %s, want notifications/initialized without id", out.String())
+	}
+}
@@ -0,0 +1,33 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+)
+
+// Config mirrors the --mcp-config file shape (spec 7.2).
+type Config = claude.MCPConfig
+
+// ServerConfig is the same server entry the claude CLI reads from
+// --mcp-config, so a config can be exercised here before handing it over.
+type ServerConfig = claude.MCPServerConfig
+
+func LoadConfig(path string) (*Config, error) {
+	return claude.LoadMCPConfig(path)
+}
+
+func ParseConfig(data []byte) (*Config, error) {
+	return claude.ParseMCPConfig(data)
+}
+
+func validateStdio(cfg ServerConfig) error {
+	if cfg.Type != "" && cfg.Type != claude.MCPServerTypeStdio {
+		return fmt.Errorf("unsupported mcp server type for stdio launch: %s", cfg.Type)
+	}
+	if strings.TrimSpace(cfg.Command) == "" {
+		return fmt.Errorf("mcp server command is empty")
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+)
+
+// conn is a JSON-RPC 2.0 connection on top of a Transport. Responses are
+// routed to the waiting request by id, so requests may run concurrently.
+type conn struct {
+	transport Transport
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan claude.JSONRPCResponse
+	readErr error
+	done    chan struct{}
+}
+
+type incomingMessage struct {
+	JSONRPC string               `json:"jsonrpc"`
+	ID      json.RawMessage      `json:"id,omitempty"`
+	Method  string               `json:"method,omitempty"`
+	Result  json.RawMessage      `json:"result,omitempty"`
+	Error   *claude.JSONRPCError `json:"error,omitempty"`
+	Params  json.RawMessage      `json:"params,omitempty"`
+}
+
+func newConn(t Transport) *conn {
+	c := &conn{
+		transport: t,
+		nextID:    1,
+		pending:   map[int64]chan claude.JSONRPCResponse{},
+		done:      make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+func (c *conn) readLoop() {
+	var err error
+	for {
+		var data []byte
+		data, err = c.transport.Recv()
+		if err != nil {
+			break
+		}
+		var msg incomingMessage
+		if json.Unmarshal(data, &msg) != nil || msg.JSONRPC != "2.0" {
+			continue
+		}
+		c.dispatch(msg)
+	}
+
+	c.mu.Lock()
+	c.readErr = err
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *conn) dispatch(msg incomingMessage) {
+	if msg.Method != "" {
+		if len(msg.ID) > 0 {
+			c.replyToServerRequest(msg)
+		}
+		return
+	}
+
+	var id int64
+	if err := json.Unmarshal(msg.ID, &id); err != nil {
+		return
+	}
+	c.mu.Lock()
+	ch, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	ch <- claude.JSONRPCResponse{JSONRPC: msg.JSONRPC, ID: id, Result: msg.Result, Error: msg.Error}
+}
+
+// replyToServerRequest answers requests initiated by the server. Only ping
+// is supported; everything else gets "method not found".
+func (c *conn) replyToServerRequest(msg incomingMessage) {
+	reply := map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID}
+	if msg.Method == "ping" {
+		reply["result"] = struct{}{}
+	} else {
+		reply["error"] = claude.JSONRPCError{Code: -32601, Message: "Method not found"}
+	}
+	if data, err := json.Marshal(reply); err == nil {
+		_ = c.transport.Send(context.Background(), data)
+	}
+}
+
+func (c *conn) request(ctx context.Context, method string, params interface{}, out interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ch := make(chan claude.JSONRPCResponse, 1)
+	c.mu.Lock()
+	if c.readErr != nil {
+		err := c.readErr
+		c.mu.Unlock()
+		return err
+	}
+	id := c.nextID
+	c.nextID++
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	data, err := json.Marshal(claude.JSONRPCRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		c.forget(id)
+		return fmt.Errorf("marshal jsonrpc request: %w", err)
+	}
+	if err := c.transport.Send(ctx, data); err != nil {
+		c.forget(id)
+		return fmt.Errorf("write jsonrpc request: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		c.forget(id)
+		return ctx.Err()
+	case resp := <-ch:
+		return decodeResponse(method, resp, out)
+	case <-c.done:
+		c.forget(id)
+		select {
+		case resp := <-ch:
+			return decodeResponse(method, resp, out)
+		default:
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.readErr
+	}
+}
+
+func (c *conn) notify(ctx context.Context, method string, params interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(claude.JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("marshal jsonrpc notification: %w", err)
+	}
+	if err := c.transport.Send(ctx, data); err != nil {
+		return fmt.Errorf("write jsonrpc notification: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) forget(id int64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func decodeResponse(method string, resp claude.JSONRPCResponse, out interface{}) error {
+	if resp.Error != nil {
+		return fmt.Errorf("jsonrpc error %d: %s", resp.Error.Code, resp.Error.Message)
+	}
+	if out == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("decode %s result: %w", method, err)
+	}
+	return nil
+}
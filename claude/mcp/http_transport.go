@@ -0,0 +1,293 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+const sessionIDHeader = "Mcp-Session-Id"
+
+// HTTPTransport implements the Streamable HTTP transport: every message is
+// POSTed to a single endpoint and the server answers with either a JSON
+// body or an SSE stream. The session id assigned on initialize is echoed on
+// later requests.
+type HTTPTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+
+	incoming  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func NewHTTPTransport(endpoint string, headers map[string]string, client *http.Client) *HTTPTransport {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPTransport{
+		url:      endpoint,
+		headers:  headers,
+		client:   client,
+		incoming: make(chan []byte, 16),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (t *HTTPTransport) SessionID() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID
+}
+
+func (t *HTTPTransport) Send(ctx context.Context, message []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("build mcp http request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.applyHeaders(req)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post mcp message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if sessionID := resp.Header.Get(sessionIDHeader); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		return nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return httpStatusError(resp)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return readSSE(resp.Body, func(ev sseEvent) error {
+			if ev.Event != "message" {
+				return nil
+			}
+			return t.deliver([]byte(ev.Data))
+		})
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read mcp http response: %w", err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return t.deliver(body)
+}
+
+func (t *HTTPTransport) Recv() ([]byte, error) {
+	select {
+	case msg := <-t.incoming:
+		return msg, nil
+	case <-t.closed:
+		return nil, clerrors.ErrEOF
+	}
+}
+
+// Close ends the session on the server (best effort) and stops Recv.
+func (t *HTTPTransport) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		if t.SessionID() == "" {
+			return
+		}
+		req, reqErr := http.NewRequest(http.MethodDelete, t.url, nil)
+		if reqErr != nil {
+			err = reqErr
+			return
+		}
+		t.applyHeaders(req)
+		resp, doErr := t.client.Do(req)
+		if doErr != nil {
+			err = fmt.Errorf("delete mcp session: %w", doErr)
+			return
+		}
+		resp.Body.Close()
+	})
+	return err
+}
+
+func (t *HTTPTransport) applyHeaders(req *http.Request) {
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	if sessionID := t.SessionID(); sessionID != "" {
+		req.Header.Set(sessionIDHeader, sessionID)
+	}
+}
+
+func (t *HTTPTransport) deliver(message []byte) error {
+	select {
+	case t.incoming <- bytes.Clone(message):
+		return nil
+	case <-t.closed:
+		return clerrors.ErrEOF
+	}
+}
+
+// SSETransport implements the legacy HTTP+SSE transport: a long-lived GET
+// stream carries server messages, and the first "endpoint" event names the
+// URL that client messages are POSTed to.
+type SSETransport struct {
+	headers  map[string]string
+	client   *http.Client
+	endpoint string
+
+	body      io.Closer
+	incoming  chan []byte
+	readErr   chan error
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// DialSSE opens the event stream and waits for the endpoint event. The
+// stream's request is bound to ctx, so cancelling ctx also closes the
+// transport.
+func DialSSE(ctx context.Context, streamURL string, headers map[string]string, client *http.Client) (*SSETransport, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base, err := url.Parse(streamURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse sse url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build sse request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("open sse stream: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, httpStatusError(resp)
+	}
+
+	t := &SSETransport{
+		headers:  headers,
+		client:   client,
+		body:     resp.Body,
+		incoming: make(chan []byte, 16),
+		readErr:  make(chan error, 1),
+		closed:   make(chan struct{}),
+	}
+	endpointCh := make(chan string, 1)
+	go t.readLoop(resp.Body, endpointCh)
+
+	select {
+	case <-ctx.Done():
+		_ = t.Close()
+		return nil, ctx.Err()
+	case err := <-t.readErr:
+		_ = t.Close()
+		return nil, fmt.Errorf("sse stream closed before endpoint event: %w", err)
+	case endpoint := <-endpointCh:
+		ref, err := url.Parse(endpoint)
+		if err != nil {
+			_ = t.Close()
+			return nil, fmt.Errorf("parse sse endpoint: %w", err)
+		}
+		t.endpoint = base.ResolveReference(ref).String()
+		return t, nil
+	}
+}
+
+func (t *SSETransport) readLoop(body io.Reader, endpointCh chan<- string) {
+	err := readSSE(body, func(ev sseEvent) error {
+		switch ev.Event {
+		case "endpoint":
+			select {
+			case endpointCh <- ev.Data:
+			default:
+			}
+		case "message":
+			select {
+			case t.incoming <- []byte(ev.Data):
+			case <-t.closed:
+				return clerrors.ErrEOF
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = clerrors.ErrEOF
+	}
+	t.readErr <- err
+}
+
+func (t *SSETransport) Send(ctx context.Context, message []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("build mcp sse request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post mcp message: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return httpStatusError(resp)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (t *SSETransport) Recv() ([]byte, error) {
+	select {
+	case msg := <-t.incoming:
+		return msg, nil
+	case err := <-t.readErr:
+		t.readErr <- err
+		return nil, err
+	case <-t.closed:
+		return nil, clerrors.ErrEOF
+	}
+}
+
+func (t *SSETransport) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		err = t.body.Close()
+	})
+	return err
+}
+
+func httpStatusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("mcp http status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+}
@@ -0,0 +1,222 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+)
+
+func TestStreamableHTTPTransport(t *testing.T) {
+	var sawAuth bool
+	handler := newCalculatorServer().StreamableHTTPHandler()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token" {
+			sawAuth = true
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := Dial(ctx, ServerConfig{Type: "http", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	exerciseCalculator(t, ctx, client)
+
+	transport := client.transport.(*HTTPTransport)
+	if transport.SessionID() == "" {
+		t.Fatalf("SessionID() is empty after initialize")
+	}
+	if !sawAuth {
+		t.Fatalf("custom headers were not sent")
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestStreamableHTTPHandlerRequiresSession(t *testing.T) {
+	srv := httptest.NewServer(newCalculatorServer().StreamableHTTPHandler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	req.Header.Set(sessionIDHeader, "stale")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHTTPTransportJSONResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"echo"}]}}`))
+	}))
+	defer srv.Close()
+
+	client := NewClientWithTransport(NewHTTPTransport(srv.URL, nil, srv.Client()))
+	defer client.Close()
+
+	tools, err := client.MCPToolsList(context.Background())
+	if err != nil {
+		t.Fatalf("MCPToolsList() error = %v", err)
+	}
+	if len(tools.Tools) != 1 || tools.Tools[0].Name != "echo" {
+		t.Fatalf("tools = %+v, want echo", tools.Tools)
+	}
+}
+
+func TestHTTPTransportStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	client := NewClientWithTransport(NewHTTPTransport(srv.URL, nil, nil))
+	defer client.Close()
+
+	_, err := client.MCPToolsList(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "mcp http status 401") {
+		t.Fatalf("MCPToolsList() error = %v, want status 401", err)
+	}
+}
+
+func TestSSETransport(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/sse", newCalculatorServer().SSEHandler())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := Dial(ctx, ServerConfig{Type: "sse", URL: srv.URL + "/sse"})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer client.Close()
+
+	transport := client.transport.(*SSETransport)
+	if !strings.HasPrefix(transport.endpoint, srv.URL+"/sse?sessionId=") {
+		t.Fatalf("endpoint = %q, want resolved POST endpoint", transport.endpoint)
+	}
+	exerciseCalculator(t, ctx, client)
+}
+
+func TestSSEHandlerCancelsToolCallsWithSession(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	s := NewServer("slow", "1.0.0")
+	s.AddTool(claude.ToolDefinition{Name: "wait"}, func(ctx context.Context, arguments json.RawMessage) (*claude.ToolsCallResult, error) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+	srv := httptest.NewServer(s.SSEHandler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, ServerConfig{Type: "sse", URL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	if _, err := client.MCPInitialize(ctx, claude.InitializeParams{ProtocolVersion: claude.MCPProtocolVersion20241105}); err != nil {
+		t.Fatalf("MCPInitialize() error = %v", err)
+	}
+	go func() { _, _ = client.MCPToolsCall(ctx, claude.ToolsCallParams{Name: "wait"}) }()
+
+	select {
+	case <-started:
+	case <-ctx.Done():
+		t.Fatalf("tool call never started")
+	}
+	_ = client.Close()
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("tool call not cancelled after the client disconnected")
+	}
+}
+
+func TestDialValidation(t *testing.T) {
+	ctx := context.Background()
+	for _, cfg := range []ServerConfig{{Type: "http"}, {Type: "sse"}, {Type: "ws", URL: "ws://x"}} {
+		if _, err := Dial(ctx, cfg); err == nil {
+			t.Fatalf("Dial(%+v) error = nil, want error", cfg)
+		}
+	}
+	var _ claude.MCPAPI = NewClientWithTransport(NewHTTPTransport("http://127.0.0.1:1", nil, nil))
+}
+
+func TestStreamableHTTPHandlerSessionLifetime(t *testing.T) {
+	h := newCalculatorServer().StreamableHTTPHandler().(*streamableHandler)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return clock }
+	h.idleTimeout = time.Minute
+	h.maxSessions = 2
+
+	post := func(sessionID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if sessionID != "" {
+			req.Header.Set(sessionIDHeader, sessionID)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	initialize := func() string {
+		defer func() { clock = clock.Add(time.Second) }()
+		return post("", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`).Header().Get(sessionIDHeader)
+	}
+	list := `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`
+
+	if rec := post("", `{"jsonrpc":"1.0","id":1,"method":"initialize"}`); rec.Header().Get(sessionIDHeader) != "" || len(h.sessions) != 0 {
+		t.Fatalf("failed initialize registered session %q", rec.Header().Get(sessionIDHeader))
+	}
+
+	first := initialize()
+	second := initialize()
+	third := initialize()
+	if first == "" || len(h.sessions) != 2 {
+		t.Fatalf("sessions = %d, want capped at 2", len(h.sessions))
+	}
+	if rec := post(first, list); rec.Code != http.StatusNotFound {
+		t.Fatalf("evicted session status = %d, want 404", rec.Code)
+	}
+
+	clock = clock.Add(20 * time.Second)
+	if rec := post(third, list); rec.Code != http.StatusOK {
+		t.Fatalf("live session status = %d, want 200", rec.Code)
+	}
+	clock = clock.Add(45 * time.Second)
+	if rec := post(second, list); rec.Code != http.StatusNotFound {
+		t.Fatalf("idle session status = %d, want 404", rec.Code)
+	}
+	if rec := post(third, list); rec.Code != http.StatusOK {
+		t.Fatalf("recently used session status = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,178 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// ToolHandler runs one tool call. Returning an error produces an isError
+// result carrying the error text, as in spec 12.3.
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (*claude.ToolsCallResult, error)
+
+// Server is a transport-agnostic MCP server exposing Go-defined tools. Use
+// ServeStdio, StreamableHTTPHandler or SSEHandler to put it on the wire.
+type Server struct {
+	info claude.ServerInfo
+
+	mu       sync.RWMutex
+	tools    []claude.ToolDefinition
+	handlers map[string]ToolHandler
+}
+
+func NewServer(name, version string) *Server {
+	return &Server{
+		info:     claude.ServerInfo{Name: name, Version: version},
+		handlers: map[string]ToolHandler{},
+	}
+}
+
+// AddTool registers a tool, replacing any earlier tool with the same name.
+func (s *Server) AddTool(def claude.ToolDefinition, handler ToolHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.handlers[def.Name]; ok {
+		for i := range s.tools {
+			if s.tools[i].Name == def.Name {
+				s.tools[i] = def
+			}
+		}
+	} else {
+		s.tools = append(s.tools, def)
+	}
+	s.handlers[def.Name] = handler
+}
+
+// Handle processes one JSON-RPC message and returns the encoded response,
+// or nil for notifications and responses. Notifications, including both
+// notifications/initialized and the legacy initialized, need no reply.
+func (s *Server) Handle(ctx context.Context, message []byte) []byte {
+	var req struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id,omitempty"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params,omitempty"`
+	}
+	if err := json.Unmarshal(message, &req); err != nil {
+		return encodeError(json.RawMessage("null"), -32700, "Parse error")
+	}
+	if req.Method == "" {
+		return nil
+	}
+	if len(req.ID) == 0 || bytes.Equal(req.ID, []byte("null")) {
+		return nil
+	}
+	if req.JSONRPC != "2.0" {
+		return encodeError(req.ID, -32600, "Invalid Request")
+	}
+
+	var result interface{}
+	switch req.Method {
+	case "initialize":
+		result = s.initialize(req.Params)
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		s.mu.RLock()
+		result = claude.ToolsListResult{Tools: append([]claude.ToolDefinition(nil), s.tools...)}
+		s.mu.RUnlock()
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments,omitempty"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Name == "" {
+			return encodeError(req.ID, -32602, "Invalid params")
+		}
+		result = s.callTool(ctx, params.Name, params.Arguments)
+	default:
+		return encodeError(req.ID, -32601, "Method not found")
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	if err != nil {
+		return encodeError(req.ID, -32603, "Internal error")
+	}
+	return data
+}
+
+func (s *Server) initialize(raw json.RawMessage) claude.InitializeResult {
+	var params claude.InitializeParams
+	_ = json.Unmarshal(raw, &params)
+
+	version := claude.LatestMCPProtocolVersion
+	if claude.IsSupportedMCPProtocolVersion(params.ProtocolVersion) {
+		version = params.ProtocolVersion
+	}
+	return claude.InitializeResult{
+		ProtocolVersion: version,
+		ServerInfo:      s.info,
+		Capabilities:    claude.ServerCapabilities{Tools: &claude.ToolsCapability{}},
+	}
+}
+
+func (s *Server) callTool(ctx context.Context, name string, arguments json.RawMessage) *claude.ToolsCallResult {
+	s.mu.RLock()
+	handler, ok := s.handlers[name]
+	s.mu.RUnlock()
+	if !ok {
+		return errorResult("Unknown tool: " + name)
+	}
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+
+	result, err := handler(ctx, arguments)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	if result == nil {
+		return &claude.ToolsCallResult{Content: []claude.ToolResultContent{}}
+	}
+	return result
+}
+
+// ServeStdio reads newline-delimited requests from r and writes responses to
+// w until r reaches EOF or ctx is done.
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	t := NewStdioTransport(r, w)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		msg, err := t.Recv()
+		if err != nil {
+			if clerrors.IsEOF(err) {
+				return nil
+			}
+			return err
+		}
+		if resp := s.Handle(ctx, msg); resp != nil {
+			if err := t.Send(ctx, resp); err != nil {
+				return fmt.Errorf("write mcp response: %w", err)
+			}
+		}
+	}
+}
+
+func errorResult(text string) *claude.ToolsCallResult {
+	return &claude.ToolsCallResult{
+		Content: []claude.ToolResultContent{{Type: claude.ToolResultContentTypeText, Text: text}},
+		IsError: true,
+	}
+}
+
+func encodeError(id json.RawMessage, code int, message string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   claude.JSONRPCError{Code: code, Message: message},
+	})
+	return data
+}
@@ -0,0 +1,260 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits on Streamable HTTP sessions. A session unused for
+// StreamableSessionIdleTimeout expires; beyond MaxStreamableSessions the
+// least recently used session is dropped.
+const (
+	StreamableSessionIdleTimeout = 30 * time.Minute
+	MaxStreamableSessions        = 1024
+)
+
+// StreamableHTTPHandler serves the Streamable HTTP transport. A session id
+// is issued on a successful initialize and required on every later
+// request; responses are streamed as SSE when the client accepts it and
+// sent as JSON otherwise.
+func (s *Server) StreamableHTTPHandler() http.Handler {
+	return &streamableHandler{
+		server:      s,
+		sessions:    map[string]time.Time{},
+		idleTimeout: StreamableSessionIdleTimeout,
+		maxSessions: MaxStreamableSessions,
+		now:         time.Now,
+	}
+}
+
+type streamableHandler struct {
+	server      *Server
+	idleTimeout time.Duration
+	maxSessions int
+	now         func() time.Time
+
+	mu sync.Mutex
+	// sessions maps session ids to when they were last used.
+	sessions map[string]time.Time
+}
+
+// touch marks sessionID as used and reports whether it is live.
+func (h *streamableHandler) touch(sessionID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	h.expireLocked(now)
+	if _, ok := h.sessions[sessionID]; !ok {
+		return false
+	}
+	h.sessions[sessionID] = now
+	return true
+}
+
+func (h *streamableHandler) register(sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	h.expireLocked(now)
+	for len(h.sessions) >= h.maxSessions {
+		oldest, oldestAt := "", now
+		for id, at := range h.sessions {
+			if oldest == "" || at.Before(oldestAt) {
+				oldest, oldestAt = id, at
+			}
+		}
+		delete(h.sessions, oldest)
+	}
+	h.sessions[sessionID] = now
+}
+
+func (h *streamableHandler) expireLocked(now time.Time) {
+	for id, at := range h.sessions {
+		if now.Sub(at) > h.idleTimeout {
+			delete(h.sessions, id)
+		}
+	}
+}
+
+func (h *streamableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.servePost(w, r)
+	case http.MethodDelete:
+		sessionID := r.Header.Get(sessionIDHeader)
+		ok := h.touch(sessionID)
+		h.mu.Lock()
+		delete(h.sessions, sessionID)
+		h.mu.Unlock()
+		if !ok {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *streamableHandler) servePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+
+	var probe struct {
+		Method string `json:"method"`
+	}
+	_ = json.Unmarshal(body, &probe)
+	initialize := probe.Method == "initialize"
+	if !initialize {
+		sessionID := r.Header.Get(sessionIDHeader)
+		if sessionID == "" {
+			http.Error(w, "missing "+sessionIDHeader, http.StatusBadRequest)
+			return
+		}
+		if !h.touch(sessionID) {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+	}
+
+	resp := h.server.Handle(r.Context(), body)
+	if initialize && resp != nil {
+		var reply struct {
+			Error json.RawMessage `json:"error"`
+		}
+		if err := json.Unmarshal(resp, &reply); err == nil && reply.Error == nil {
+			sessionID := newSessionID()
+			h.register(sessionID)
+			w.Header().Set(sessionIDHeader, sessionID)
+		}
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		_ = writeSSE(w, "message", resp)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resp)
+}
+
+// SSEHandler serves the legacy HTTP+SSE transport. A GET opens the event
+// stream and announces a POST endpoint on the same path carrying the
+// session id as a query parameter; responses to POSTed messages are
+// delivered on the stream.
+func (s *Server) SSEHandler() http.Handler {
+	return &sseHandler{server: s, sessions: map[string]*sseSession{}}
+}
+
+type sseHandler struct {
+	server *Server
+
+	mu       sync.Mutex
+	sessions map[string]*sseSession
+}
+
+// sseSession lives as long as its event stream; ctx is cancelled when the
+// stream ends, which also cancels the session's tool calls.
+type sseSession struct {
+	out chan []byte
+	ctx context.Context
+}
+
+func (h *sseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.serveStream(w, r)
+	case http.MethodPost:
+		h.servePost(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *sseHandler) serveStream(w http.ResponseWriter, r *http.Request) {
+	sessionID := newSessionID()
+	ctx, cancel := context.WithCancel(r.Context())
+	session := &sseSession{out: make(chan []byte, 16), ctx: ctx}
+	h.mu.Lock()
+	h.sessions[sessionID] = session
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.sessions, sessionID)
+		h.mu.Unlock()
+		cancel()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := writeSSE(w, "endpoint", []byte(r.URL.Path+"?sessionId="+sessionID)); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-session.out:
+			if err := writeSSE(w, "message", msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *sseHandler) servePost(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	session, ok := h.sessions[r.URL.Query().Get("sessionId")]
+	h.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	// The POST returns immediately; the tool call runs detached from the
+	// request so a slow handler does not hold the connection open, but is
+	// cancelled with the session.
+	go func() {
+		resp := h.server.Handle(session.ctx, bytes.Clone(body))
+		if resp == nil {
+			return
+		}
+		select {
+		case session.out <- resp:
+		case <-session.ctx.Done():
+		}
+	}()
+}
+
+func newSessionID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
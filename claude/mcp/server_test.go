@@ -0,0 +1,140 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+)
+
+func newCalculatorServer() *Server {
+	s := NewServer("calc", "1.0.0")
+	s.AddTool(claude.ToolDefinition{
+		Name:        "multiply",
+		Description: "Multiplies two numbers",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"a":{"type":"number"},"b":{"type":"number"}},"required":["a","b"]}`),
+	}, func(ctx context.Context, arguments json.RawMessage) (*claude.ToolsCallResult, error) {
+		var args struct{ A, B float64 }
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return nil, err
+		}
+		text, _ := json.Marshal(args.A * args.B)
+		return &claude.ToolsCallResult{Content: []claude.ToolResultContent{{Type: claude.ToolResultContentTypeText, Text: string(text)}}}, nil
+	})
+	s.AddTool(claude.ToolDefinition{Name: "fail"}, func(ctx context.Context, arguments json.RawMessage) (*claude.ToolsCallResult, error) {
+		return nil, errors.New("boom")
+	})
+	return s
+}
+
+func exerciseCalculator(t *testing.T, ctx context.Context, client *Client) {
+	t.Helper()
+
+	init, err := client.MCPInitialize(ctx, claude.InitializeParams{ProtocolVersion: claude.MCPProtocolVersion20241105})
+	if err != nil {
+		t.Fatalf("MCPInitialize() error = %v", err)
+	}
+	if init.ProtocolVersion != claude.MCPProtocolVersion20241105 {
+		t.Fatalf("ProtocolVersion = %q, want %q", init.ProtocolVersion, claude.MCPProtocolVersion20241105)
+	}
+	if init.ServerInfo.Name != "calc" || init.Capabilities.Tools == nil {
+		t.Fatalf("init = %+v, want calc with tools", init)
+	}
+	if err := client.MCPInitialized(ctx); err != nil {
+		t.Fatalf("MCPInitialized() error = %v", err)
+	}
+
+	tools, err := client.MCPToolsList(ctx)
+	if err != nil {
+		t.Fatalf("MCPToolsList() error = %v", err)
+	}
+	if len(tools.Tools) != 2 || tools.Tools[0].Name != "multiply" {
+		t.Fatalf("tools = %+v, want multiply and fail", tools.Tools)
+	}
+
+	res, err := client.MCPToolsCall(ctx, claude.ToolsCallParams{Name: "multiply", Arguments: map[string]interface{}{"a": 7, "b": 6}})
+	if err != nil {
+		t.Fatalf("MCPToolsCall(multiply) error = %v", err)
+	}
+	if len(res.Content) != 1 || res.Content[0].Text != "42" {
+		t.Fatalf("multiply content = %+v, want 42", res.Content)
+	}
+
+	failed, err := client.MCPToolsCall(ctx, claude.ToolsCallParams{Name: "fail"})
+	if err != nil {
+		t.Fatalf("MCPToolsCall(fail) error = %v", err)
+	}
+	if !failed.IsError || failed.Content[0].Text != "boom" {
+		t.Fatalf("fail result = %+v, want isError boom", failed)
+	}
+}
+
+func TestServerServeStdio(t *testing.T) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- newCalculatorServer().ServeStdio(ctx, serverR, serverW)
+	}()
+
+	client := NewClient(clientR, clientW)
+	exerciseCalculator(t, ctx, client)
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("ServeStdio() error = %v", err)
+	}
+}
+
+func TestServerHandleErrors(t *testing.T) {
+	s := newCalculatorServer()
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		request string
+		code    int
+	}{
+		{name: "parse error", request: `{`, code: -32700},
+		{name: "method not found", request: `{"jsonrpc":"2.0","id":1,"method":"resources/list"}`, code: -32601},
+		{name: "invalid params", request: `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{}}`, code: -32602},
+		{name: "invalid request", request: `{"jsonrpc":"1.0","id":3,"method":"ping"}`, code: -32600},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp claude.JSONRPCResponse
+			if err := json.Unmarshal(s.Handle(ctx, []byte(tc.request)), &resp); err != nil {
+				t.Fatalf("unmarshal response: %v", err)
+			}
+			if resp.Error == nil || resp.Error.Code != tc.code {
+				t.Fatalf("error = %+v, want code %d", resp.Error, tc.code)
+			}
+		})
+	}
+
+	for _, method := range []string{"notifications/initialized", "initialized"} {
+		if resp := s.Handle(ctx, []byte(`{"jsonrpc":"2.0","method":"`+method+`"}`)); resp != nil {
+			t.Fatalf("%s response = %s, want nil", method, resp)
+		}
+	}
+
+	var unknown struct {
+		Result claude.ToolsCallResult `json:"result"`
+	}
+	if err := json.Unmarshal(s.Handle(ctx, []byte(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"nope"}}`)), &unknown); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if !unknown.Result.IsError || !strings.Contains(unknown.Result.Content[0].Text, "Unknown tool") {
+		t.Fatalf("result = %+v, want unknown tool error", unknown.Result)
+	}
+}
@@ -0,0 +1,72 @@
+package mcp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// readSSE decodes a text/event-stream body, calling fn for each event.
+// Events without an explicit name are reported as "message".
+func readSSE(r io.Reader, fn func(sseEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	var event string
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		if event == "" {
+			event = "message"
+		}
+		err := fn(sseEvent{Event: event, Data: strings.Join(data, "\n")})
+		event = ""
+		data = data[:0]
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return dispatch()
+}
+
+func writeSSE(w http.ResponseWriter, event string, data []byte) error {
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
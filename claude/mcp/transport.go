@@ -0,0 +1,67 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// Transport carries JSON-RPC messages between an MCP client and a server.
+// Send may be called concurrently; Recv is called from a single goroutine
+// and returns an error once the transport is closed or the peer goes away.
+type Transport interface {
+	Send(ctx context.Context, message []byte) error
+	Recv() ([]byte, error)
+	Close() error
+}
+
+// StdioTransport speaks newline-delimited JSON over a stream pair.
+type StdioTransport struct {
+	scanner *bufio.Scanner
+	writer  io.Writer
+
+	writeMu sync.Mutex
+}
+
+func NewStdioTransport(r io.Reader, w io.Writer) *StdioTransport {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	return &StdioTransport{scanner: scanner, writer: w}
+}
+
+func (t *StdioTransport) Send(ctx context.Context, message []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	line := append(bytes.TrimSpace(bytes.Clone(message)), '\n')
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err := t.writer.Write(line)
+	return err
+}
+
+func (t *StdioTransport) Recv() ([]byte, error) {
+	for t.scanner.Scan() {
+		line := bytes.TrimSpace(t.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		return bytes.Clone(line), nil
+	}
+	if err := t.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, clerrors.ErrEOF
+}
+
+func (t *StdioTransport) Close() error {
+	if closer, ok := t.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+type MCPServerType string
+
+const (
+	MCPServerTypeStdio MCPServerType = "stdio"
+	MCPServerTypeSSE   MCPServerType = "sse"
+	MCPServerTypeHTTP  MCPServerType = "http"
+	MCPServerTypeSDK   MCPServerType = "sdk"
+)
+
+// MCPConfig is the --mcp-config file shape (spec 7.2).
+type MCPConfig struct {
+	MCPServers map[string]MCPServerConfig `json:"mcpServers"`
+}
+
+// MCPServerConfig describes one server. Stdio servers use Command, Args and
+// Env; sse and http servers use URL and Headers; sdk servers are hosted by
+// the SDK process and only carry Name.
+type MCPServerConfig struct {
+	Type    MCPServerType     `json:"type,omitempty"`
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Name    string            `json:"name,omitempty"`
+}
+
+func LoadMCPConfig(path string) (*MCPConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mcp config: %w", err)
+	}
+	return ParseMCPConfig(data)
+}
+
+func ParseMCPConfig(data []byte) (*MCPConfig, error) {
+	var cfg MCPConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse mcp config: %w", err)
+	}
+	return &cfg, nil
+}
+
+func (c MCPConfig) Server(name string) (MCPServerConfig, error) {
+	server, ok := c.MCPServers[name]
+	if !ok {
+		return MCPServerConfig{}, fmt.Errorf("mcp server %q not found in config", name)
+	}
+	return server, nil
+}
+
+// Validate reports every invalid server, in name order. It is stricter than
+// the CLI: fields that do not apply to a server's type are rejected too.
+func (c MCPConfig) Validate() error {
+	return c.validate(true)
+}
+
+// validate checks what the CLI itself requires of each server; strict also
+// rejects fields the CLI would silently ignore.
+func (c MCPConfig) validate(strict bool) error {
+	names := make([]string, 0, len(c.MCPServers))
+	for name := range c.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t\n") {
+			errs = append(errs, fmt.Errorf("mcp server name %q is invalid", name))
+			continue
+		}
+		if err := c.MCPServers[name].validate(strict); err != nil {
+			errs = append(errs, fmt.Errorf("mcp server %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks c like MCPConfig.Validate, rejecting fields that do not
+// apply to its type.
+func (c MCPServerConfig) Validate() error {
+	return c.validate(true)
+}
+
+func (c MCPServerConfig) validate(strict bool) error {
+	switch c.Type {
+	case "", MCPServerTypeStdio:
+		if strings.TrimSpace(c.Command) == "" {
+			return fmt.Errorf("command is empty")
+		}
+		if strict && (c.URL != "" || len(c.Headers) > 0) {
+			return fmt.Errorf("url and headers are not supported for stdio servers")
+		}
+	case MCPServerTypeSSE, MCPServerTypeHTTP:
+		if strings.TrimSpace(c.URL) == "" {
+			return fmt.Errorf("url is empty")
+		}
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return fmt.Errorf("parse url: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("url scheme must be http or https: %s", c.URL)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("url has no host: %s", c.URL)
+		}
+		if strict && (c.Command != "" || len(c.Args) > 0 || len(c.Env) > 0) {
+			return fmt.Errorf("command, args and env are not supported for %s servers", c.Type)
+		}
+	case MCPServerTypeSDK:
+		if strings.TrimSpace(c.Name) == "" {
+			return fmt.Errorf("name is empty")
+		}
+		if strict && (c.Command != "" || c.URL != "") {
+			return fmt.Errorf("command and url are not supported for sdk servers")
+		}
+	default:
+		return fmt.Errorf("unsupported server type: %q", c.Type)
+	}
+	return nil
+}
+
+func writeMCPConfigFile(cfg MCPConfig) (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("marshal mcp config: %w", err)
+	}
+	f, err := os.CreateTemp("", "agentkit-mcp-*.json")
+	if err != nil {
+		return "", fmt.Errorf("create mcp config file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("write mcp config file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("close mcp config file: %w", err)
+	}
+	return f.Name(), nil
+}
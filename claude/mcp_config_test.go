@@ -0,0 +1,205 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadMCPConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	data := `{
+  "mcpServers": {
+    "my-tools": {"command": "python", "args": ["mcp_server.py"], "env": {"API_KEY": "secret"}},
+    "remote-tools": {"type": "sse", "url": "https://mcp.example.com/sse", "headers": {"Authorization": "Bearer token"}}
+  }
+}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cfg, err := LoadMCPConfig(path)
+	if err != nil {
+		t.Fatalf("LoadMCPConfig() error = %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	local, err := cfg.Server("my-tools")
+	if err != nil {
+		t.Fatalf("Server() error = %v", err)
+	}
+	if local.Command != "python" || len(local.Args) != 1 || local.Env["API_KEY"] != "secret" {
+		t.Fatalf("my-tools = %+v, want python mcp_server.py", local)
+	}
+	remote := cfg.MCPServers["remote-tools"]
+	if remote.Type != MCPServerTypeSSE || remote.Headers["Authorization"] != "Bearer token" {
+		t.Fatalf("remote-tools = %+v, want sse with headers", remote)
+	}
+	if _, err := cfg.Server("missing"); err == nil {
+		t.Fatalf("Server(missing) error = nil, want error")
+	}
+}
+
+func TestMCPConfigValidate(t *testing.T) {
+	cfg := MCPConfig{MCPServers: map[string]MCPServerConfig{
+		"ok-stdio": {Command: "node", Args: []string{"server.js"}},
+		"ok-http":  {Type: MCPServerTypeHTTP, URL: "https://mcp.example.com/mcp"},
+		"ok-sdk":   {Type: MCPServerTypeSDK, Name: "in-process"},
+		"no-cmd":   {Type: MCPServerTypeStdio},
+		"bad-url":  {Type: MCPServerTypeSSE, URL: "ftp://example.com"},
+		"no-host":  {Type: MCPServerTypeHTTP, URL: "http://"},
+		"mixed":    {Type: MCPServerTypeHTTP, URL: "https://x", Command: "node"},
+		"no-name":  {Type: MCPServerTypeSDK},
+		"odd-type": {Type: "ws", URL: "wss://x"},
+		"bad name": {Command: "node"},
+	}}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("Validate() error = nil, want error")
+	}
+	for _, name := range []string{"no-cmd", "bad-url", "no-host", "mixed", "no-name", "odd-type", "bad name"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("Validate() error = %v, want mention of %q", err, name)
+		}
+	}
+	for _, name := range []string{"ok-stdio", "ok-http", "ok-sdk"} {
+		if strings.Contains(err.Error(), `"`+name+`"`) {
+			t.Fatalf("Validate() error = %v, should not mention %q", err, name)
+		}
+	}
+}
+
+func TestClientBuilderWithMCPServersWritesTempConfig(t *testing.T) {
+	var gotArgs []string
+	builder := NewClientBuilder().
+		WithMCPServers(map[string]MCPServerConfig{
+			"calc": {Command: "python", Args: []string{"calc.py"}},
+		}).
+		WithStrictMCPConfig(true)
+	builder.commandFactory = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		gotArgs = args
+		return exec.CommandContext(ctx, "sh", "-c", "cat >/dev/null")
+	}
+
+	client, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if !containsArg(gotArgs, "--strict-mcp-config") {
+		t.Fatalf("args = %v, want --strict-mcp-config", gotArgs)
+	}
+	path := argValue(gotArgs, "--mcp-config")
+	if path == "" {
+		t.Fatalf("args = %v, want --mcp-config", gotArgs)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	var written MCPConfig
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("unmarshal config: %v", err)
+	}
+	if written.MCPServers["calc"].Command != "python" {
+		t.Fatalf("written = %s, want calc server", data)
+	}
+
+	_ = client.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("config file still exists after Close: %v", err)
+	}
+}
+
+func TestClientBuilderMCPConfigValidationBeforeLaunch(t *testing.T) {
+	launched := false
+	factory := func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		launched = true
+		return exec.CommandContext(ctx, "true")
+	}
+
+	invalidPath := filepath.Join(t.TempDir(), "mcp.json")
+	if err := os.WriteFile(invalidPath, []byte(`{"mcpServers":{"x":{"type":"http"}}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	builders := []*ClientBuilder{
+		NewClientBuilder().WithMCPServers(map[string]MCPServerConfig{"x": {Type: MCPServerTypeHTTP}}),
+		NewClientBuilder().WithMCPConfig(invalidPath),
+		NewClientBuilder().WithMCPConfig(filepath.Join(t.TempDir(), "missing.json")),
+		NewClientBuilder().WithMCPConfig(invalidPath).WithMCPServers(map[string]MCPServerConfig{"y": {Command: "node"}}),
+		NewClientBuilder().WithStrictMCPConfig(true),
+	}
+	for i, builder := range builders {
+		builder.commandFactory = factory
+		if _, err := builder.Build(context.Background()); err == nil {
+			t.Fatalf("builders[%d].Build() error = nil, want validation error", i)
+		}
+	}
+	if launched {
+		t.Fatalf("process launched despite invalid mcp config")
+	}
+}
+
+func TestClientBuilderMCPConfigAcceptsWhatCLIAccepts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	data := `{"mcpServers":{"remote":{"type":"sse","url":"https://mcp.example.com/sse","env":{"TOKEN":"x"}},"local":{"command":"node","headers":{"X-Trace":"1"}}}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	cfg, err := LoadMCPConfig(path)
+	if err != nil {
+		t.Fatalf("LoadMCPConfig() error = %v", err)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("Validate() error = nil, want strict rejection of env on sse and headers on stdio")
+	}
+
+	builder := NewClientBuilder().WithMCPConfig(path)
+	builder.commandFactory = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", "cat >/dev/null")
+	}
+	client, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	_ = client.Close()
+}
+
+func TestClientBuilderMCPConfigValidatedWithReadWriter(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	defer stdinR.Close()
+	_, err := NewClientBuilder().
+		WithMCPConfig(filepath.Join(t.TempDir(), "missing.json")).
+		WithReader(strings.NewReader("")).
+		WithWriter(stdinW).
+		Build(context.Background())
+	if err == nil {
+		t.Fatalf("Build() error = nil, want missing mcp config error")
+	}
+}
+
+func containsArg(args []string, want string) bool {
+	for _, arg := range args {
+		if arg == want {
+			return true
+		}
+	}
+	return false
+}
+
+func argValue(args []string, flag string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/flaneur2020/agentkit-go/claude/jsonschema"
+)
+
+func (c ToolResultContent) Validate() error {
+	switch c.Type {
+	case ToolResultContentTypeText:
+		return nil
+	case ToolResultContentTypeImage, ToolResultContentTypeAudio:
+		if strings.TrimSpace(c.MIMEType) == "" {
+			return fmt.Errorf("%s content: mimeType is empty", c.Type)
+		}
+		if c.Data == "" {
+			return fmt.Errorf("%s content: data is empty", c.Type)
+		}
+		if _, err := base64.StdEncoding.DecodeString(c.Data); err != nil {
+			return fmt.Errorf("%s content: decode data: %w", c.Type, err)
+		}
+		return nil
+	case ToolResultContentTypeResourceLink:
+		if strings.TrimSpace(c.URI) == "" {
+			return fmt.Errorf("resource_link content: uri is empty")
+		}
+		if strings.TrimSpace(c.Name) == "" {
+			return fmt.Errorf("resource_link content: name is empty")
+		}
+		return nil
+	case ToolResultContentTypeResource:
+		if c.Resource == nil {
+			return fmt.Errorf("resource content: resource is nil")
+		}
+		if strings.TrimSpace(c.Resource.URI) == "" {
+			return fmt.Errorf("resource content: uri is empty")
+		}
+		if c.Resource.Blob != "" {
+			if _, err := base64.StdEncoding.DecodeString(c.Resource.Blob); err != nil {
+				return fmt.Errorf("resource content: decode blob: %w", err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported tool result content type: %q", c.Type)
+	}
+}
+
+// DecodeData returns the binary payload of image and audio content, or the
+// blob of an embedded resource.
+func (c ToolResultContent) DecodeData() ([]byte, error) {
+	switch {
+	case c.Type == ToolResultContentTypeImage || c.Type == ToolResultContentTypeAudio:
+		return base64.StdEncoding.DecodeString(c.Data)
+	case c.Type == ToolResultContentTypeResource && c.Resource != nil && c.Resource.Blob != "":
+		return base64.StdEncoding.DecodeString(c.Resource.Blob)
+	default:
+		return nil, fmt.Errorf("%s content has no binary data", c.Type)
+	}
+}
+
+// ValidateResult checks every content item and, when the tool declares an
+// outputSchema, that structuredContent is present and matches it. Error
+// results are not required to carry structured content.
+func (d ToolDefinition) ValidateResult(result *ToolsCallResult) error {
+	if result == nil {
+		return fmt.Errorf("tool %s: result is nil", d.Name)
+	}
+	for i, content := range result.Content {
+		if err := content.Validate(); err != nil {
+			return fmt.Errorf("tool %s: content[%d]: %w", d.Name, i, err)
+		}
+	}
+
+	schema := bytes.TrimSpace(d.OutputSchema)
+	if len(schema) == 0 || bytes.Equal(schema, []byte("null")) {
+		return nil
+	}
+	structured := bytes.TrimSpace(result.StructuredContent)
+	if len(structured) == 0 || bytes.Equal(structured, []byte("null")) {
+		if result.IsError {
+			return nil
+		}
+		return fmt.Errorf("tool %s: structuredContent is required by outputSchema", d.Name)
+	}
+	if err := jsonschema.Validate(schema, structured); err != nil {
+		return fmt.Errorf("tool %s: structuredContent: %w", d.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/flaneur2020/agentkit-go/claude/jsonschema"
+)
+
+func TestProtocolMCPToolsCallRichContent(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{"content":[` +
+		`{"type":"text","text":"chart"},` +
+		`{"type":"image","data":"iVBORw0K","mimeType":"image/png"},` +
+		`{"type":"audio","data":"UklGRg==","mimeType":"audio/wav"},` +
+		`{"type":"resource_link","uri":"file:///tmp/report.csv","name":"report.csv","mimeType":"text/csv"},` +
+		`{"type":"resource","resource":{"uri":"file:///tmp/notes.md","mimeType":"text/markdown","text":"# notes"}}` +
+		`],"structuredContent":{"total":42}}}` + "\n")
+	p := NewProtocol(in, &bytes.Buffer{})
+
+	resp, err := p.MCPToolsCall(context.Background(), ToolsCallParams{Name: "report"})
+	if err != nil {
+		t.Fatalf("MCPToolsCall() error = %v", err)
+	}
+	if len(resp.Content) != 5 {
+		t.Fatalf("content len = %d, want 5", len(resp.Content))
+	}
+	image := resp.Content[1]
+	if image.Type != ToolResultContentTypeImage || image.MIMEType != "image/png" {
+		t.Fatalf("image = %+v, want image/png", image)
+	}
+	data, err := image.DecodeData()
+	if err != nil {
+		t.Fatalf("DecodeData() error = %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("\x89PNG")) {
+		t.Fatalf("image data = %q, want png header", data)
+	}
+	if link := resp.Content[3]; link.Type != ToolResultContentTypeResourceLink || link.URI != "file:///tmp/report.csv" || link.Name != "report.csv" {
+		t.Fatalf("resource_link = %+v, want report.csv", link)
+	}
+	if res := resp.Content[4].Resource; res == nil || res.URI != "file:///tmp/notes.md" || res.Text != "# notes" {
+		t.Fatalf("resource = %+v, want notes.md", res)
+	}
+	if string(resp.StructuredContent) != `{"total":42}` {
+		t.Fatalf("structuredContent = %s, want {\"total\":42}", resp.StructuredContent)
+	}
+	for i, content := range resp.Content {
+		if err := content.Validate(); err != nil {
+			t.Fatalf("content[%d].Validate() error = %v", i, err)
+		}
+	}
+}
+
+func TestToolResultContentValidate(t *testing.T) {
+	invalid := []ToolResultContent{
+		{Type: ToolResultContentTypeImage, Data: "iVBORw0K"},
+		{Type: ToolResultContentTypeAudio, MIMEType: "audio/wav"},
+		{Type: ToolResultContentTypeImage, MIMEType: "image/png", Data: "not base64!"},
+		{Type: ToolResultContentTypeResourceLink, Name: "x"},
+		{Type: ToolResultContentTypeResource},
+		{Type: ToolResultContentTypeResource, Resource: &EmbeddedResource{URI: "file:///x", Blob: "%%"}},
+		{Type: "video"},
+	}
+	for i, content := range invalid {
+		if err := content.Validate(); err == nil {
+			t.Fatalf("invalid[%d].Validate() error = nil, want error", i)
+		}
+	}
+	if _, err := (ToolResultContent{Type: ToolResultContentTypeText, Text: "x"}).DecodeData(); err == nil {
+		t.Fatalf("text DecodeData() error = nil, want error")
+	}
+}
+
+func TestToolDefinitionValidateResult(t *testing.T) {
+	def := ToolDefinition{
+		Name:         "weather",
+		OutputSchema: json.RawMessage(`{"type":"object","properties":{"temperature":{"type":"number"}},"required":["temperature"]}`),
+	}
+
+	if err := def.ValidateResult(&ToolsCallResult{StructuredContent: json.RawMessage(`{"temperature":21.5}`)}); err != nil {
+		t.Fatalf("ValidateResult() error = %v", err)
+	}
+	if err := def.ValidateResult(&ToolsCallResult{}); err == nil || !strings.Contains(err.Error(), "structuredContent is required") {
+		t.Fatalf("ValidateResult() error = %v, want missing structuredContent", err)
+	}
+	if err := def.ValidateResult(&ToolsCallResult{IsError: true, Content: []ToolResultContent{{Type: ToolResultContentTypeText, Text: "boom"}}}); err != nil {
+		t.Fatalf("ValidateResult(isError) error = %v", err)
+	}
+
+	err := def.ValidateResult(&ToolsCallResult{StructuredContent: json.RawMessage(`{"temperature":"warm"}`)})
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ValidateResult() error = %v, want *jsonschema.ValidationError", err)
+	}
+	if verr.Path != "/temperature" {
+		t.Fatalf("Path = %q, want /temperature", verr.Path)
+	}
+
+	if err := (ToolDefinition{Name: "plain"}).ValidateResult(&ToolsCallResult{}); err != nil {
+		t.Fatalf("ValidateResult() without schema error = %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+type MCPServerStatus string
+
+const (
+	MCPServerStatusConnected MCPServerStatus = "connected"
+	MCPServerStatusFailed    MCPServerStatus = "failed"
+	MCPServerStatusPending   MCPServerStatus = "pending"
+	MCPServerStatusNeedsAuth MCPServerStatus = "needs-auth"
+)
+
+// MCPToolName returns the namespaced tool name claude exposes for an MCP
+// tool (spec 7.3).
+func MCPToolName(server, tool string) string {
+	return "mcp__" + server + "__" + tool
+}
+
+// ParseMCPToolName splits an mcp__<server>__<tool> name.
+func ParseMCPToolName(name string) (server, tool string, ok bool) {
+	rest, found := strings.CutPrefix(name, "mcp__")
+	if !found {
+		return "", "", false
+	}
+	server, tool, found = strings.Cut(rest, "__")
+	if !found || server == "" || tool == "" {
+		return "", "", false
+	}
+	return server, tool, true
+}
+
+// MCPStatusReport summarizes MCP server health from a SystemInitMessage.
+type MCPStatusReport struct {
+	SessionID string
+	Servers   []MCPServerState
+	// Unavailable lists servers that are not connected, including expected
+	// servers that do not appear in the init message at all.
+	Unavailable []MCPServerState
+	// MissingTools maps a server name to expected tool names (without the
+	// mcp__ prefix) that are absent from SystemInitMessage.Tools.
+	MissingTools map[string][]string
+}
+
+func (r *MCPStatusReport) OK() bool {
+	return len(r.Unavailable) == 0 && len(r.MissingTools) == 0
+}
+
+// CheckMCPStatus cross-references the init message against the expected
+// tools per server. Servers in expectedTools with no tools listed are only
+// checked for presence.
+func CheckMCPStatus(msg *SystemInitMessage, expectedTools map[string][]string) *MCPStatusReport {
+	report := &MCPStatusReport{
+		SessionID:    msg.SessionID,
+		Servers:      append([]MCPServerState(nil), msg.MCPServers...),
+		MissingTools: map[string][]string{},
+	}
+
+	seen := map[string]bool{}
+	for _, server := range msg.MCPServers {
+		seen[server.Name] = true
+		if server.Status != MCPServerStatusConnected {
+			report.Unavailable = append(report.Unavailable, server)
+		}
+	}
+
+	available := map[string]bool{}
+	for _, tool := range msg.Tools {
+		available[tool] = true
+	}
+
+	servers := make([]string, 0, len(expectedTools))
+	for server := range expectedTools {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	for _, server := range servers {
+		if !seen[server] {
+			report.Unavailable = append(report.Unavailable, MCPServerState{Name: server})
+		}
+		for _, tool := range expectedTools[server] {
+			if !available[MCPToolName(server, tool)] {
+				report.MissingTools[server] = append(report.MissingTools[server], tool)
+			}
+		}
+	}
+	if len(report.MissingTools) == 0 {
+		report.MissingTools = nil
+	}
+	return report
+}
+
+// MCPStatusError is returned by Client.NextMessage in place of the init
+// message (or by Build, with WithMCPPreflight) when a required MCP server is
+// not connected. It matches clerrors.ErrMCPServerUnavailable.
+type MCPStatusError struct {
+	System *SystemInitMessage
+	Report *MCPStatusReport
+	Failed []MCPServerState
+}
+
+func (e *MCPStatusError) Error() string {
+	parts := make([]string, 0, len(e.Failed))
+	for _, server := range e.Failed {
+		status := string(server.Status)
+		if status == "" {
+			status = "missing"
+		}
+		parts = append(parts, server.Name+"="+status)
+	}
+	return fmt.Sprintf("required mcp servers unavailable: %s", strings.Join(parts, ", "))
+}
+
+func (e *MCPStatusError) Unwrap() error {
+	return clerrors.ErrMCPServerUnavailable
+}
+
+type mcpMonitor struct {
+	required []string
+	// expectedTools also holds every required server, so absent ones are
+	// reported as unavailable.
+	expectedTools map[string][]string
+	handler       func(*MCPStatusReport)
+
+	mu     sync.Mutex
+	report *MCPStatusReport
+}
+
+func (m *mcpMonitor) observe(msg *SystemInitMessage) error {
+	report := CheckMCPStatus(msg, m.expectedTools)
+	m.mu.Lock()
+	m.report = report
+	m.mu.Unlock()
+	if m.handler != nil {
+		m.handler(report)
+	}
+
+	var failed []MCPServerState
+	for _, server := range report.Unavailable {
+		for _, name := range m.required {
+			if server.Name == name {
+				failed = append(failed, server)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return &MCPStatusError{System: msg, Report: report, Failed: failed}
+	}
+	return nil
+}
+
+func (m *mcpMonitor) latest() *MCPStatusReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.report
+}
+
+// awaitInit sends input and reads until the init message, queueing what it
+// reads for NextMessage. A failed MCP check surfaces as *MCPStatusError.
+// Without streaming input the CLI waits for EOF, so input is the whole turn.
+func (c *Client) awaitInit(ctx context.Context, input UserInput) error {
+	if err := c.SendUserInput(ctx, input); err != nil {
+		return err
+	}
+	if !c.streamingInput {
+		if err := c.CloseInput(); err != nil {
+			return err
+		}
+	}
+	for {
+		msg, err := c.readMessage(ctx)
+		if err != nil {
+			return err
+		}
+		c.enqueue(msg)
+		if _, ok := msg.(*SystemInitMessage); ok {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+const mcpInitLine = `{"type":"system","subtype":"init","session_id":"s1","tools":["Read","mcp__calc__add","mcp__calc__multiply","mcp__search__query"],"mcp_servers":[{"name":"calc","status":"connected"},{"name":"search","status":"connected"},{"name":"db","status":"failed"}]}`
+
+func TestParseMCPToolName(t *testing.T) {
+	server, tool, ok := ParseMCPToolName("mcp__ruby-tools__current_time")
+	if !ok || server != "ruby-tools" || tool != "current_time" {
+		t.Fatalf("ParseMCPToolName() = %q, %q, %v", server, tool, ok)
+	}
+	for _, name := range []string{"Read", "mcp__only", "mcp____x", "mcp__x__"} {
+		if _, _, ok := ParseMCPToolName(name); ok {
+			t.Fatalf("ParseMCPToolName(%q) ok = true, want false", name)
+		}
+	}
+	if got := MCPToolName("calc", "add"); got != "mcp__calc__add" {
+		t.Fatalf("MCPToolName() = %q, want mcp__calc__add", got)
+	}
+}
+
+func TestCheckMCPStatus(t *testing.T) {
+	msg, err := NewMessageParser(strings.NewReader("")).ParseLine([]byte(mcpInitLine))
+	if err != nil {
+		t.Fatalf("ParseLine() error = %v", err)
+	}
+
+	report := CheckMCPStatus(msg.(*SystemInitMessage), map[string][]string{
+		"calc":   {"add", "multiply", "divide"},
+		"search": {"query"},
+		"vector": nil,
+	})
+	if report.OK() {
+		t.Fatalf("OK() = true, want false")
+	}
+	wantUnavailable := []MCPServerState{{Name: "db", Status: MCPServerStatusFailed}, {Name: "vector"}}
+	if !reflect.DeepEqual(report.Unavailable, wantUnavailable) {
+		t.Fatalf("Unavailable = %+v, want %+v", report.Unavailable, wantUnavailable)
+	}
+	wantMissing := map[string][]string{"calc": {"divide"}}
+	if !reflect.DeepEqual(report.MissingTools, wantMissing) {
+		t.Fatalf("MissingTools = %+v, want %+v", report.MissingTools, wantMissing)
+	}
+}
+
+func TestClientRequiredMCPServerFailure(t *testing.T) {
+	var reports []*MCPStatusReport
+	client, err := NewClientBuilder().
+		WithReader(strings.NewReader(mcpInitLine+"\n")).
+		WithWriter(&bytes.Buffer{}).
+		WithRequiredMCPServers("calc", "db").
+		WithExpectedMCPTools("calc", "add", "divide").
+		WithMCPStatusHandler(func(r *MCPStatusReport) { reports = append(reports, r) }).
+		Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	_, err = client.NextMessage(context.Background())
+	if !errors.Is(err, clerrors.ErrMCPServerUnavailable) {
+		t.Fatalf("NextMessage() error = %v, want ErrMCPServerUnavailable", err)
+	}
+	var statusErr *MCPStatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("NextMessage() error = %T, want *MCPStatusError", err)
+	}
+	if len(statusErr.Failed) != 1 || statusErr.Failed[0].Name != "db" {
+		t.Fatalf("Failed = %+v, want db", statusErr.Failed)
+	}
+	if statusErr.System == nil || statusErr.System.SessionID != "s1" {
+		t.Fatalf("System = %+v, want init message", statusErr.System)
+	}
+	if !strings.Contains(err.Error(), "db=failed") {
+		t.Fatalf("Error() = %q, want db=failed", err.Error())
+	}
+
+	if len(reports) != 1 || !reflect.DeepEqual(reports[0].MissingTools, map[string][]string{"calc": {"divide"}}) {
+		t.Fatalf("reports = %+v, want one report missing calc/divide", reports)
+	}
+	if client.MCPStatus() != reports[0] {
+		t.Fatalf("MCPStatus() did not return latest report")
+	}
+}
+
+func TestClientOptionalMCPServerPassesThrough(t *testing.T) {
+	client, err := NewClientBuilder().
+		WithReader(strings.NewReader(mcpInitLine + "\n")).
+		WithWriter(&bytes.Buffer{}).
+		WithRequiredMCPServers("calc").
+		Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	msg, err := client.NextMessage(context.Background())
+	if err != nil {
+		t.Fatalf("NextMessage() error = %v", err)
+	}
+	if _, ok := msg.(*SystemInitMessage); !ok {
+		t.Fatalf("type = %T, want *SystemInitMessage", msg)
+	}
+	report := client.MCPStatus()
+	if report == nil || len(report.Unavailable) != 1 || report.Unavailable[0].Name != "db" {
+		t.Fatalf("MCPStatus() = %+v, want db unavailable", report)
+	}
+}
+
+func TestClientBuilderMCPPreflight(t *testing.T) {
+	script := `cat >/dev/null; echo '` + mcpInitLine + `'; echo '{"type":"result","subtype":"success","session_id":"s1"}'`
+
+	_, err := newFakeCLI(script).builder().
+		WithRequiredMCPServers("db").
+		WithMCPPreflight(UserInput{Prompt: "hi"}).
+		Build(context.Background())
+	var statusErr *MCPStatusError
+	if !errors.As(err, &statusErr) || statusErr.Failed[0].Name != "db" {
+		t.Fatalf("Build() error = %v, want *MCPStatusError for db", err)
+	}
+
+	client, err := newFakeCLI(script).builder().
+		WithRequiredMCPServers("calc").
+		WithMCPPreflight(UserInput{Prompt: "hi"}).
+		Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer client.Close()
+	if client.MCPStatus() == nil {
+		t.Fatalf("MCPStatus() = nil after preflight")
+	}
+	msg, err := client.NextMessage(context.Background())
+	if err != nil {
+		t.Fatalf("NextMessage() error = %v", err)
+	}
+	if _, ok := msg.(*SystemInitMessage); !ok {
+		t.Fatalf("first message = %T, want queued init", msg)
+	}
+	msg, err = client.NextMessage(context.Background())
+	if err != nil {
+		t.Fatalf("NextMessage() error = %v", err)
+	}
+	if _, ok := msg.(*ResultMessage); !ok {
+		t.Fatalf("second message = %T, want *ResultMessage", msg)
+	}
+}
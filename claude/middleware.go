@@ -0,0 +1,68 @@
+package claude
+
+import (
+	"context"
+)
+
+type (
+	SendUserInputFunc func(ctx context.Context, input UserInput) error
+	NextMessageFunc   func(ctx context.Context) (Message, error)
+	JSONRPCFunc       func(ctx context.Context, req *JSONRPCRequest) error
+)
+
+// Middleware intercepts a client's traffic with the CLI. Each hook is
+// optional and calls next to continue the chain; it may change what it
+// passes on or returns, or fail without calling next. Hooks run while the
+// client's writes or reads are in progress, so they must not call back
+// into the client.
+type Middleware struct {
+	// SendUserInput sees every outbound input, including control requests
+	// and responses, after prompts are converted for streaming input.
+	SendUserInput func(ctx context.Context, input UserInput, next SendUserInputFunc) error
+	// JSONRPC sees outbound JSON-RPC requests and notifications. It must
+	// not change a request's ID, which the response is matched on.
+	JSONRPC func(ctx context.Context, req *JSONRPCRequest, next JSONRPCFunc) error
+	// NextMessage sees each inbound message before the client's observers.
+	// JSON-RPC responses consumed by MCP calls do not pass through it.
+	NextMessage func(ctx context.Context, next NextMessageFunc) (Message, error)
+}
+
+type middlewareProtocol struct {
+	Protocol
+	send SendUserInputFunc
+	next NextMessageFunc
+}
+
+func (p *middlewareProtocol) SendUserInput(ctx context.Context, input UserInput) error {
+	return p.send(ctx, input)
+}
+
+func (p *middlewareProtocol) NextMessage(ctx context.Context) (Message, error) {
+	return p.next(ctx)
+}
+
+// withMiddleware wraps inner so that middlewares[0] is outermost: it sees
+// outbound traffic first and inbound messages last.
+func withMiddleware(inner Protocol, middlewares []Middleware) Protocol {
+	if len(middlewares) == 0 {
+		return inner
+	}
+	wrapped := &middlewareProtocol{Protocol: inner, send: inner.SendUserInput, next: inner.NextMessage}
+	raw, _ := inner.(*protocol)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		m := middlewares[i]
+		if h := m.SendUserInput; h != nil {
+			next := wrapped.send
+			wrapped.send = func(ctx context.Context, input UserInput) error { return h(ctx, input, next) }
+		}
+		if h := m.NextMessage; h != nil {
+			next := wrapped.next
+			wrapped.next = func(ctx context.Context) (Message, error) { return h(ctx, next) }
+		}
+		if h := m.JSONRPC; h != nil && raw != nil {
+			next := raw.sendRPC
+			raw.sendRPC = func(ctx context.Context, req *JSONRPCRequest) error { return h(ctx, req, next) }
+		}
+	}
+	return wrapped
+}
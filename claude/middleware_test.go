@@ -0,0 +1,121 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientMiddlewareOrderAndTransform(t *testing.T) {
+	ctx := context.Background()
+	in := strings.NewReader(`{"type":"result","subtype":"success","result":"token=abc"}` + "\n")
+	var out bytes.Buffer
+	var trace []string
+
+	logging := Middleware{
+		SendUserInput: func(ctx context.Context, input UserInput, next SendUserInputFunc) error {
+			trace = append(trace, "log send "+input.Prompt)
+			return next(ctx, input)
+		},
+		NextMessage: func(ctx context.Context, next NextMessageFunc) (Message, error) {
+			msg, err := next(ctx)
+			if result, ok := msg.(*ResultMessage); ok {
+				trace = append(trace, "log recv "+result.Result)
+			}
+			return msg, err
+		},
+		JSONRPC: func(ctx context.Context, req *JSONRPCRequest, next JSONRPCFunc) error {
+			trace = append(trace, "log rpc "+req.Method)
+			return next(ctx, req)
+		},
+	}
+	redact := Middleware{
+		SendUserInput: func(ctx context.Context, input UserInput, next SendUserInputFunc) error {
+			input.Prompt = strings.ReplaceAll(input.Prompt, "hunter2", "***")
+			trace = append(trace, "redact send")
+			return next(ctx, input)
+		},
+		NextMessage: func(ctx context.Context, next NextMessageFunc) (Message, error) {
+			msg, err := next(ctx)
+			if result, ok := msg.(*ResultMessage); ok {
+				trace = append(trace, "redact recv")
+				result.Result = strings.ReplaceAll(result.Result, "abc", "***")
+			}
+			return msg, err
+		},
+	}
+
+	var observed string
+	client, err := NewClientBuilder().
+		WithReader(in).
+		WithWriter(&out).
+		WithMiddleware(logging).
+		WithMiddleware(redact).
+		WithObserver(func(ctx context.Context, msg Message) error {
+			if result, ok := msg.(*ResultMessage); ok {
+				observed = result.Result
+			}
+			return nil
+		}).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if err := client.SendUserInput(ctx, UserInput{Prompt: "password hunter2"}); err != nil {
+		t.Fatalf("SendUserInput() error = %v", err)
+	}
+	if err := client.MCPInitialized(ctx); err != nil {
+		t.Fatalf("MCPInitialized() error = %v", err)
+	}
+	if _, err := client.NextMessage(ctx); err != nil {
+		t.Fatalf("NextMessage() error = %v", err)
+	}
+
+	want := []string{"log send password hunter2", "redact send", "log rpc initialized", "redact recv", "log recv token=***"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Fatalf("trace = %q\nwant %q", trace, want)
+	}
+	if !strings.HasPrefix(out.String(), "password ***") || !strings.Contains(out.String(), `"method":"initialized"`) {
+		t.Fatalf("written = %q, want redacted prompt and notification", out.String())
+	}
+	if observed != "token=***" {
+		t.Fatalf("observer saw %q, want redacted result", observed)
+	}
+}
+
+func TestClientMiddlewareWithProcess(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var sent []UserInput
+	var received []MessageType
+	fake := newFakeCLI(sessionScript("s1", "/work", 0.1))
+	client, err := fake.builder().WithMiddleware(Middleware{
+		SendUserInput: func(ctx context.Context, input UserInput, next SendUserInputFunc) error {
+			sent = append(sent, input)
+			return next(ctx, input)
+		},
+		NextMessage: func(ctx context.Context, next NextMessageFunc) (Message, error) {
+			msg, err := next(ctx)
+			if err == nil {
+				received = append(received, msg.GetType())
+			}
+			return msg, err
+		},
+	}).Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	drainClient(t, client)
+
+	if len(sent) != 1 || sent[0].Prompt != "hi" {
+		t.Fatalf("sent = %+v, want the prompt", sent)
+	}
+	if !reflect.DeepEqual(received, []MessageType{MessageTypeSystem, MessageTypeResult}) {
+		t.Fatalf("received = %v, want system and result", received)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sync"
 
 	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
 )
@@ -38,11 +39,11 @@ func (p *messageParser) ParseLine(line []byte) (Message, error) {
 
 	switch env.Type {
 	case MessageTypeSystem:
-		var msg SystemMessage
-		if err := json.Unmarshal(trimmed, &msg); err != nil {
-			return unknownFromParseFailure(env.Type, trimmed, fmt.Errorf("parse system message: %w", err)), nil
+		msg, err := parseSystemMessage(trimmed)
+		if err != nil {
+			return unknownFromParseFailure(env.Type, trimmed, err), nil
 		}
-		return &msg, nil
+		return msg, nil
 	case MessageTypeAssistant:
 		var msg AssistantMessage
 		if err := json.Unmarshal(trimmed, &msg); err != nil {
@@ -68,12 +69,108 @@ func (p *messageParser) ParseLine(line []byte) (Message, error) {
 		}
 		return &msg, nil
 	default:
+		if newMsg, ok := builtinMessageTypes[env.Type]; ok {
+			msg := newMsg()
+			if err := json.Unmarshal(trimmed, msg); err != nil {
+				return unknownFromParseFailure(env.Type, trimmed, fmt.Errorf("parse %s message: %w", env.Type, err)), nil
+			}
+			return msg, nil
+		}
+		if decode, ok := lookupMessageType(env.Type); ok {
+			msg, err := decode(bytes.Clone(trimmed))
+			if err != nil {
+				return unknownFromParseFailure(env.Type, trimmed, fmt.Errorf("parse %s message: %w", env.Type, err)), nil
+			}
+			return msg, nil
+		}
 		msg := &UnknownMessage{Type: env.Type}
 		msg.setRaw(trimmed)
 		return msg, nil
 	}
 }
 
+// builtinMessageTypes are the control-plane and notice lines the CLI emits
+// besides the five core message types.
+var builtinMessageTypes = map[MessageType]func() Message{
+	MessageTypeControlRequest:       func() Message { return &ControlRequestMessage{} },
+	MessageTypeControlResponse:      func() Message { return &ControlResponseMessage{} },
+	MessageTypeControlCancelRequest: func() Message { return &ControlCancelRequestMessage{} },
+	MessageTypeKeepAlive:            func() Message { return &KeepAliveMessage{} },
+	MessageTypeToolProgress:         func() Message { return &ToolProgressMessage{} },
+	MessageTypeAuthStatus:           func() Message { return &AuthStatusMessage{} },
+}
+
+// MessageDecoder decodes one stream-json line of a registered type. The
+// line is a private copy the decoder may retain, e.g. to return from Raw.
+type MessageDecoder func(line []byte) (Message, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[MessageType]MessageDecoder{}
+)
+
+// RegisterMessageType makes every MessageParser decode lines of type t with
+// decode instead of returning UnknownMessage. A decode error yields an
+// UnknownMessage carrying the error, as for built-in types. It panics if t
+// is built in or already registered.
+func RegisterMessageType(t MessageType, decode MessageDecoder) {
+	if decode == nil {
+		panic("claude: RegisterMessageType decoder is nil")
+	}
+	switch t {
+	case MessageTypeSystem, MessageTypeAssistant, MessageTypeUser, MessageTypeResult, MessageTypeStreamEvent:
+		panic(fmt.Sprintf("claude: message type %q is built in", t))
+	}
+	if _, ok := builtinMessageTypes[t]; ok {
+		panic(fmt.Sprintf("claude: message type %q is built in", t))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[t]; dup {
+		panic(fmt.Sprintf("claude: message type %q registered twice", t))
+	}
+	registry[t] = decode
+}
+
+func lookupMessageType(t MessageType) (MessageDecoder, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	decode, ok := registry[t]
+	return decode, ok
+}
+
+// parseSystemMessage dispatches on subtype. Subtypes without a dedicated
+// type are parsed into SystemMessage.
+func parseSystemMessage(line []byte) (Message, error) {
+	var probe struct {
+		Subtype SystemSubtype `json:"subtype"`
+	}
+	if err := json.Unmarshal(line, &probe); err != nil {
+		return nil, fmt.Errorf("parse system message: %w", err)
+	}
+
+	var msg Message
+	switch probe.Subtype {
+	case SystemSubtypeInit:
+		msg = &SystemInitMessage{}
+	case SystemSubtypeCompactBoundary:
+		msg = &CompactBoundaryMessage{}
+	case SystemSubtypeHookResponse:
+		msg = &HookResponseMessage{}
+	case SystemSubtypeStatus:
+		msg = &StatusMessage{}
+	case SystemSubtypeAPIError, SystemSubtypeAPIRetry:
+		msg = &APIErrorMessage{}
+	default:
+		msg = &SystemMessage{}
+	}
+	if err := json.Unmarshal(line, msg); err != nil {
+		return nil, fmt.Errorf("parse system %s message: %w", probe.Subtype, err)
+	}
+	return msg, nil
+}
+
 func unknownFromParseFailure(messageType MessageType, raw []byte, err error) *UnknownMessage {
 	msg := &UnknownMessage{
 		Type:       messageType,
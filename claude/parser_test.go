@@ -2,7 +2,9 @@ package claude
 
 import (
 	"bytes"
+	"encoding/json"
 	"strings"
+	"sync"
 	"testing"
 
 	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
@@ -36,9 +38,9 @@ func TestParserParseLineSystemMessage(t *testing.T) {
 		t.Fatalf("ParseLine() error = %v", err)
 	}
 
-	systemMsg, ok := msg.(*SystemMessage)
+	systemMsg, ok := msg.(*SystemInitMessage)
 	if !ok {
-		t.Fatalf("ParseLine() type = %T, want *SystemMessage", msg)
+		t.Fatalf("ParseLine() type = %T, want *SystemInitMessage", msg)
 	}
 	if systemMsg.Subtype != "init" {
 		t.Fatalf("Subtype = %q, want init", systemMsg.Subtype)
@@ -49,6 +51,88 @@ func TestParserParseLineSystemMessage(t *testing.T) {
 	assertRawMessage(t, systemMsg, line)
 }
 
+func TestParserParseLineSystemSubtypes(t *testing.T) {
+	parser := NewMessageParser(strings.NewReader(""))
+
+	tests := []struct {
+		line  string
+		check func(t *testing.T, msg Message)
+	}{
+		{
+			line: `{"type":"system","subtype":"compact_boundary","session_id":"s1","compact_metadata":{"trigger":"auto","pre_tokens":150000}}`,
+			check: func(t *testing.T, msg Message) {
+				m, ok := msg.(*CompactBoundaryMessage)
+				if !ok {
+					t.Fatalf("type = %T, want *CompactBoundaryMessage", msg)
+				}
+				if m.CompactMetadata.Trigger != "auto" || m.CompactMetadata.PreTokens != 150000 {
+					t.Fatalf("CompactMetadata = %+v", m.CompactMetadata)
+				}
+			},
+		},
+		{
+			line: `{"type":"system","subtype":"hook_response","session_id":"s1","hook_name":"SessionStart:startup","hook_event":"SessionStart","stdout":"ok","stderr":"","exit_code":0}`,
+			check: func(t *testing.T, msg Message) {
+				m, ok := msg.(*HookResponseMessage)
+				if !ok {
+					t.Fatalf("type = %T, want *HookResponseMessage", msg)
+				}
+				if m.HookEvent != "SessionStart" || m.Stdout != "ok" || m.ExitCode == nil || *m.ExitCode != 0 {
+					t.Fatalf("hook response = %+v", m)
+				}
+			},
+		},
+		{
+			line: `{"type":"system","subtype":"status","session_id":"s1","status":"compacting"}`,
+			check: func(t *testing.T, msg Message) {
+				m, ok := msg.(*StatusMessage)
+				if !ok {
+					t.Fatalf("type = %T, want *StatusMessage", msg)
+				}
+				if m.Status == nil || *m.Status != "compacting" {
+					t.Fatalf("Status = %v, want compacting", m.Status)
+				}
+			},
+		},
+		{
+			line: `{"type":"system","subtype":"api_error","level":"error","error":{"status":529},"retryInMs":1000,"retryAttempt":2,"maxRetries":10}`,
+			check: func(t *testing.T, msg Message) {
+				m, ok := msg.(*APIErrorMessage)
+				if !ok {
+					t.Fatalf("type = %T, want *APIErrorMessage", msg)
+				}
+				if m.RetryAttempt != 2 || m.MaxRetries != 10 || m.RetryInMS != 1000 || string(m.Error) != `{"status":529}` {
+					t.Fatalf("api error = %+v", m)
+				}
+			},
+		},
+		{
+			line: `{"type":"system","subtype":"future_notice","session_id":"s1","detail":"x"}`,
+			check: func(t *testing.T, msg Message) {
+				m, ok := msg.(*SystemMessage)
+				if !ok {
+					t.Fatalf("type = %T, want *SystemMessage", msg)
+				}
+				if m.Subtype != "future_notice" {
+					t.Fatalf("Subtype = %q, want future_notice", m.Subtype)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		msg, err := parser.ParseLine([]byte(tt.line))
+		if err != nil {
+			t.Fatalf("ParseLine(%s) error = %v", tt.line, err)
+		}
+		if msg.GetType() != MessageTypeSystem {
+			t.Fatalf("GetType() = %q, want system", msg.GetType())
+		}
+		tt.check(t, msg)
+		assertRawMessage(t, msg, []byte(tt.line))
+	}
+}
+
 func TestParserParseLineUnknownType(t *testing.T) {
 	parser := NewMessageParser(strings.NewReader(""))
 	line := []byte(`{"type":"other","foo":"bar"}`)
@@ -149,6 +233,31 @@ func TestParserParseLineUserMessageTextBlock(t *testing.T) {
 	assertRawMessage(t, userMsg, line)
 }
 
+func TestParserParseLineUserMessageStringContentWithTranscriptFields(t *testing.T) {
+	parser := NewMessageParser(strings.NewReader(""))
+	line := []byte(`{"type":"user","uuid":"u2","parentUuid":"a1","isSidechain":true,"timestamp":"2025-06-01T10:00:00.000Z","message":{"role":"user","content":"hello"}}`)
+
+	msg, err := parser.ParseLine(line)
+	if err != nil {
+		t.Fatalf("ParseLine() error = %v", err)
+	}
+
+	userMsg, ok := msg.(*UserMessage)
+	if !ok {
+		t.Fatalf("ParseLine() type = %T, want *UserMessage", msg)
+	}
+	if len(userMsg.Message.Content) != 1 || userMsg.Message.Content[0].Text == nil || userMsg.Message.Content[0].Text.Text != "hello" {
+		t.Fatalf("content = %+v, want single hello text block", userMsg.Message.Content)
+	}
+	if userMsg.ParentUUID == nil || *userMsg.ParentUUID != "a1" {
+		t.Fatalf("ParentUUID = %v, want a1", userMsg.ParentUUID)
+	}
+	if !userMsg.IsSidechain || userMsg.Timestamp != "2025-06-01T10:00:00.000Z" {
+		t.Fatalf("transcript fields = %+v", userMsg.TranscriptFields)
+	}
+	assertRawMessage(t, userMsg, line)
+}
+
 func TestParserParseLineUserMessageToolUseResultString(t *testing.T) {
 	parser := NewMessageParser(strings.NewReader(""))
 	line := []byte(`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"ok"}]},"tool_use_result":"command output text"}`)
@@ -401,9 +510,9 @@ func TestAppendixAGoldenSystemMessage(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ParseLine() error = %v", err)
 	}
-	systemMsg, ok := msg.(*SystemMessage)
+	systemMsg, ok := msg.(*SystemInitMessage)
 	if !ok {
-		t.Fatalf("type = %T, want *SystemMessage", msg)
+		t.Fatalf("type = %T, want *SystemInitMessage", msg)
 	}
 	if systemMsg.OutputStyle != "default" {
 		t.Fatalf("output_style = %q, want default", systemMsg.OutputStyle)
@@ -503,3 +612,137 @@ func TestAppendixAGoldenStreamEventMessage(t *testing.T) {
 	}
 	assertRawMessage(t, eventMsg, line)
 }
+
+func TestParserParseLineControlAndNoticeTypes(t *testing.T) {
+	parser := NewMessageParser(strings.NewReader(""))
+
+	tests := []struct {
+		line  string
+		check func(t *testing.T, msg Message)
+	}{
+		{
+			line: `{"type":"control_request","request_id":"req_1","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"ls"},"tool_use_id":"t1"}}`,
+			check: func(t *testing.T, msg Message) {
+				m, ok := msg.(*ControlRequestMessage)
+				if !ok {
+					t.Fatalf("type = %T, want *ControlRequestMessage", msg)
+				}
+				if m.RequestID != "req_1" || m.Request.Subtype != ControlSubtypeCanUseTool || m.Request.ToolName != "Bash" || string(m.Request.Input) != `{"command":"ls"}` {
+					t.Fatalf("control request = %+v", m)
+				}
+				if !strings.Contains(string(m.Request.Payload), `"tool_use_id":"t1"`) {
+					t.Fatalf("Payload = %s", m.Request.Payload)
+				}
+			},
+		},
+		{
+			line: `{"type":"control_response","response":{"subtype":"error","request_id":"req_2","error":"boom"}}`,
+			check: func(t *testing.T, msg Message) {
+				m, ok := msg.(*ControlResponseMessage)
+				if !ok {
+					t.Fatalf("type = %T, want *ControlResponseMessage", msg)
+				}
+				if m.Response.Subtype != ControlResponseError || m.Response.RequestID != "req_2" || m.Response.Error != "boom" {
+					t.Fatalf("control response = %+v", m.Response)
+				}
+			},
+		},
+		{
+			line: `{"type":"control_cancel_request","request_id":"req_1"}`,
+			check: func(t *testing.T, msg Message) {
+				if m, ok := msg.(*ControlCancelRequestMessage); !ok || m.RequestID != "req_1" {
+					t.Fatalf("msg = %#v, want cancel of req_1", msg)
+				}
+			},
+		},
+		{
+			line: `{"type":"keep_alive"}`,
+			check: func(t *testing.T, msg Message) {
+				if _, ok := msg.(*KeepAliveMessage); !ok {
+					t.Fatalf("type = %T, want *KeepAliveMessage", msg)
+				}
+			},
+		},
+		{
+			line: `{"type":"tool_progress","tool_use_id":"t1","tool_name":"Bash","parent_tool_use_id":null,"elapsed_time_seconds":12.5,"session_id":"s1"}`,
+			check: func(t *testing.T, msg Message) {
+				m, ok := msg.(*ToolProgressMessage)
+				if !ok || m.ToolUseID != "t1" || m.ElapsedTimeSeconds != 12.5 || m.ParentToolUseID != nil {
+					t.Fatalf("msg = %#v", msg)
+				}
+			},
+		},
+		{
+			line: `{"type":"auth_status","isAuthenticating":true,"output":["Opening browser"]}`,
+			check: func(t *testing.T, msg Message) {
+				m, ok := msg.(*AuthStatusMessage)
+				if !ok || !m.IsAuthenticating || len(m.Output) != 1 {
+					t.Fatalf("msg = %#v", msg)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		msg, err := parser.ParseLine([]byte(tt.line))
+		if err != nil {
+			t.Fatalf("ParseLine(%s) error = %v", tt.line, err)
+		}
+		tt.check(t, msg)
+		assertRawMessage(t, msg, []byte(tt.line))
+	}
+}
+
+type registeredTestMessage struct {
+	raw   []byte
+	Value int `json:"value"`
+}
+
+func (m *registeredTestMessage) GetType() MessageType { return "test_registered" }
+func (m *registeredTestMessage) Raw() []byte          { return bytes.Clone(m.raw) }
+
+// registerTestMessage guards the global registration against -count=N.
+var registerTestMessage sync.Once
+
+func TestRegisterMessageType(t *testing.T) {
+	registerTestMessage.Do(func() {
+		RegisterMessageType("test_registered", func(line []byte) (Message, error) {
+			msg := &registeredTestMessage{raw: line}
+			if err := json.Unmarshal(line, msg); err != nil {
+				return nil, err
+			}
+			return msg, nil
+		})
+	})
+
+	parser := NewMessageParser(strings.NewReader(""))
+	line := []byte(`{"type":"test_registered","value":7}`)
+	msg, err := parser.ParseLine(line)
+	if err != nil {
+		t.Fatalf("ParseLine() error = %v", err)
+	}
+	custom, ok := msg.(*registeredTestMessage)
+	if !ok || custom.Value != 7 {
+		t.Fatalf("msg = %#v, want registered message with value 7", msg)
+	}
+	assertRawMessage(t, msg, line)
+
+	msg, err = parser.ParseLine([]byte(`{"type":"test_registered","value":"x"}`))
+	if err != nil {
+		t.Fatalf("ParseLine() error = %v", err)
+	}
+	if unknown, ok := msg.(*UnknownMessage); !ok || unknown.ParseError == "" {
+		t.Fatalf("msg = %#v, want UnknownMessage with parse error", msg)
+	}
+
+	for _, name := range []MessageType{"test_registered", MessageTypeResult, MessageTypeKeepAlive} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("RegisterMessageType(%q) did not panic", name)
+				}
+			}()
+			RegisterMessageType(name, func([]byte) (Message, error) { return nil, nil })
+		}()
+	}
+}
@@ -0,0 +1,66 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+)
+
+// PermissionMode controls how the CLI asks for tool permissions (spec
+// §9.1).
+type PermissionMode string
+
+const (
+	PermissionModeDefault           PermissionMode = "default"
+	PermissionModeAcceptEdits       PermissionMode = "acceptEdits"
+	PermissionModeBypassPermissions PermissionMode = "bypassPermissions"
+	PermissionModePlan              PermissionMode = "plan"
+)
+
+func (m PermissionMode) Validate() error {
+	switch m {
+	case PermissionModeDefault, PermissionModeAcceptEdits, PermissionModeBypassPermissions, PermissionModePlan:
+		return nil
+	default:
+		return fmt.Errorf("unknown permission mode %q", m)
+	}
+}
+
+// PermissionMode returns the mode most recently reported by the CLI in an
+// init or status message, or "" if none has been seen.
+func (c *Client) PermissionMode() PermissionMode {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.permissionMode
+}
+
+// SetPermissionMode switches the permission mode mid-session over the
+// control channel, which requires WithStreamingInput. It returns once the
+// CLI acknowledges the request and records mode locally; the CLI does not
+// always follow up with a status message, so none is waited for, and a
+// later init or status message overrides the recorded mode. Messages read
+// while waiting for the acknowledgement are returned by later NextMessage
+// calls, so it must not run concurrently with NextMessage.
+func (c *Client) SetPermissionMode(ctx context.Context, mode PermissionMode) error {
+	if err := mode.Validate(); err != nil {
+		return err
+	}
+	requestID, err := c.sendControlRequest(ctx, ControlRequest{Subtype: ControlSubtypeSetPermissionMode, Mode: string(mode)})
+	if err != nil {
+		return err
+	}
+
+	for {
+		msg, err := c.readMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("set permission mode: %w", err)
+		}
+		if m, ok := msg.(*ControlResponseMessage); ok && m.Response.RequestID == requestID {
+			if m.Response.Subtype == ControlResponseError {
+				return &ControlError{RequestID: requestID, Subtype: ControlSubtypeSetPermissionMode, Message: m.Response.Error}
+			}
+			c.setPermissionMode(mode)
+			return nil
+		}
+		c.enqueue(msg)
+	}
+}
@@ -0,0 +1,148 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const permissionModeScript = `echo '{"type":"system","subtype":"init","session_id":"s1","permissionMode":"default"}'
+read line
+id=$(printf '%s' "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+mode=$(printf '%s' "$line" | sed 's/.*"mode":"\([^"]*\)".*/\1/')
+echo '{"type":"keep_alive"}'
+if [ "$mode" = "plan" ]; then
+  echo "{\"type\":\"control_response\",\"response\":{\"subtype\":\"success\",\"request_id\":\"$id\"}}"
+  echo '{"type":"system","subtype":"status","status":null,"permissionMode":"plan"}'
+else
+  echo "{\"type\":\"control_response\",\"response\":{\"subtype\":\"error\",\"request_id\":\"$id\",\"error\":\"mode not allowed\"}}"
+fi
+cat >/dev/null
+`
+
+func TestClientSetPermissionMode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fake := newFakeCLI(permissionModeScript)
+	client, err := fake.builder().WithStreamingInput(true).Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if got := argValue(fake.lastArgs(), "--input-format"); got != "stream-json" {
+		t.Fatalf("--input-format = %q, want stream-json (args %v)", got, fake.lastArgs())
+	}
+
+	if _, err := client.NextMessage(ctx); err != nil {
+		t.Fatalf("NextMessage() error = %v", err)
+	}
+	if got := client.PermissionMode(); got != PermissionModeDefault {
+		t.Fatalf("PermissionMode() = %q, want default", got)
+	}
+
+	if err := client.SetPermissionMode(ctx, PermissionModePlan); err != nil {
+		t.Fatalf("SetPermissionMode() error = %v", err)
+	}
+	if got := client.PermissionMode(); got != PermissionModePlan {
+		t.Fatalf("PermissionMode() = %q, want plan", got)
+	}
+
+	// Messages read before the acknowledgement are handed back in order,
+	// without the control response itself; the status follows it.
+	msg, err := client.NextMessage(ctx)
+	if err != nil {
+		t.Fatalf("NextMessage() error = %v", err)
+	}
+	if _, ok := msg.(*KeepAliveMessage); !ok {
+		t.Fatalf("NextMessage() = %T, want *KeepAliveMessage", msg)
+	}
+	msg, err = client.NextMessage(ctx)
+	if err != nil {
+		t.Fatalf("NextMessage() error = %v", err)
+	}
+	if status, ok := msg.(*StatusMessage); !ok || status.PermissionMode != PermissionModePlan {
+		t.Fatalf("NextMessage() = %#v, want plan status", msg)
+	}
+}
+
+func TestClientSetPermissionModeRejected(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := newFakeCLI(permissionModeScript).builder().WithStreamingInput(true).Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	err = client.SetPermissionMode(ctx, PermissionModeAcceptEdits)
+	var controlErr *ControlError
+	if !errors.As(err, &controlErr) || controlErr.Message != "mode not allowed" {
+		t.Fatalf("SetPermissionMode() error = %v, want ControlError", err)
+	}
+	if got := client.PermissionMode(); got != PermissionModeDefault {
+		t.Fatalf("PermissionMode() = %q, want default", got)
+	}
+
+	if err := client.SetPermissionMode(ctx, "yolo"); err == nil {
+		t.Fatalf("SetPermissionMode(yolo) error = nil, want error")
+	}
+}
+
+func TestClientSetPermissionModeWithoutStatus(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The CLI acknowledges the request but never reports the new mode.
+	script := `read line
+id=$(printf '%s' "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo "{\"type\":\"control_response\",\"response\":{\"subtype\":\"success\",\"request_id\":\"$id\"}}"
+cat >/dev/null
+`
+	client, err := newFakeCLI(script).builder().WithStreamingInput(true).Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.SetPermissionMode(ctx, PermissionModeAcceptEdits); err != nil {
+		t.Fatalf("SetPermissionMode() error = %v", err)
+	}
+	if got := client.PermissionMode(); got != PermissionModeAcceptEdits {
+		t.Fatalf("PermissionMode() = %q, want acceptEdits", got)
+	}
+}
+
+func TestClientSetPermissionModeRequiresStreamingInput(t *testing.T) {
+	client, err := newFakeCLI("cat >/dev/null").builder().Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if err := client.SetPermissionMode(context.Background(), PermissionModePlan); err == nil {
+		t.Fatalf("SetPermissionMode() error = nil, want streaming input error")
+	}
+}
+
+func TestClientBuilderPermissionModeValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *ClientBuilder
+		wantErr bool
+	}{
+		{"valid", NewClientBuilder().WithPermissionMode(PermissionModePlan), false},
+		{"unknown", NewClientBuilder().WithPermissionMode("yolo"), true},
+		{"bypass with skip", NewClientBuilder().WithPermissionMode(PermissionModeBypassPermissions).WithDangerouslySkipPermissions(true), false},
+		{"conflict", NewClientBuilder().WithPermissionMode(PermissionModeDefault).WithDangerouslySkipPermissions(true), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.builder.validatePermissionMode()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validatePermissionMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Matcher matches one attribute of a tool call, such as a Bash command or
+// a file path.
+type Matcher interface {
+	Match(value string) bool
+	String() string
+}
+
+type globMatcher struct {
+	patterns []string
+	exprs    []*regexp.Regexp
+	// textExprs let "*" and "?" match "/" too, for attributes that are not
+	// paths.
+	textExprs []*regexp.Regexp
+}
+
+// Glob matches values against shell-style patterns: "*" matches any run of
+// characters other than "/", "**" matches any run including "/", and "?"
+// matches one character other than "/". It matches if any pattern does.
+// Patterns starting with "./" are cleaned so they compare against paths
+// relative to the working directory. On a Rule's Command and URL, which are
+// not paths, "*" and "?" match "/" as well, so "curl *" matches
+// "curl https://example.com/x". Patterns must match the whole value: see
+// Rule.Command for what that means for compound shell commands.
+func Glob(patterns ...string) Matcher {
+	m := &globMatcher{}
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "./") {
+			pattern = path.Clean(pattern)
+		}
+		m.patterns = append(m.patterns, pattern)
+		m.exprs = append(m.exprs, regexp.MustCompile(globToRegexp(pattern, "[^/]")))
+		m.textExprs = append(m.textExprs, regexp.MustCompile(globToRegexp(pattern, ".")))
+	}
+	return m
+}
+
+// globToRegexp translates pattern, with char the expression a "*" or "?"
+// may match one character of.
+func globToRegexp(pattern, char string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				// "dir/**" also matches "dir" itself.
+				if i+1 == len(pattern) && strings.HasSuffix(sb.String(), "/") {
+					s := sb.String()
+					sb.Reset()
+					sb.WriteString(s[:len(s)-1])
+					sb.WriteString("(/.*)?")
+					continue
+				}
+				sb.WriteString(".*")
+				continue
+			}
+			sb.WriteString(char + "*")
+		case '?':
+			sb.WriteString(char)
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func (m *globMatcher) Match(value string) bool {
+	for _, expr := range m.exprs {
+		if expr.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *globMatcher) matchText(value string) bool {
+	for _, expr := range m.textExprs {
+		if expr.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *globMatcher) String() string {
+	return "glob(" + strings.Join(m.patterns, ", ") + ")"
+}
+
+type regexpMatcher struct {
+	expr *regexp.Regexp
+}
+
+// Regexp matches values containing a match of expr.
+func Regexp(expr string) (Matcher, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("compile policy regexp: %w", err)
+	}
+	return &regexpMatcher{expr: re}, nil
+}
+
+// MustRegexp is like Regexp but panics if expr does not compile.
+func MustRegexp(expr string) Matcher {
+	m, err := Regexp(expr)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (m *regexpMatcher) Match(value string) bool {
+	return m.expr.MatchString(value)
+}
+
+func (m *regexpMatcher) String() string {
+	return "regexp(" + m.expr.String() + ")"
+}
+
+type notMatcher struct {
+	inner Matcher
+}
+
+// Not matches values that m does not match.
+func Not(m Matcher) Matcher {
+	return notMatcher{inner: m}
+}
+
+func (m notMatcher) Match(value string) bool {
+	return !m.inner.Match(value)
+}
+
+func (m notMatcher) matchText(value string) bool {
+	return !matchText(m.inner, value)
+}
+
+func (m notMatcher) String() string {
+	return "not(" + m.inner.String() + ")"
+}
+
+// textMatcher is implemented by matchers that treat "/" differently in
+// values that are not paths.
+type textMatcher interface {
+	matchText(value string) bool
+}
+
+// matchText matches a Command or URL value.
+func matchText(m Matcher, value string) bool {
+	if tm, ok := m.(textMatcher); ok {
+		return tm.matchText(value)
+	}
+	return m.Match(value)
+}
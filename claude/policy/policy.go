@@ -0,0 +1,297 @@
+// Package policy decides tool permission prompts from ordered allow, deny
+// and ask rules matched against typed tool inputs.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+)
+
+// Decision is the outcome of a rule.
+type Decision string
+
+const (
+	Allow Decision = "allow"
+	Deny  Decision = "deny"
+	// Ask defers the call to the fallback permission callback.
+	Ask Decision = "ask"
+)
+
+// ToolCall is a tool call with the inputs rules match on extracted by
+// tool.
+type ToolCall struct {
+	Tool      string
+	ToolUseID string
+	Input     json.RawMessage
+	// Command is the Bash command.
+	Command string
+	// Path is the file or directory the call touches, made absolute
+	// against the engine's working directory.
+	Path string
+	// URL and Host are set for WebFetch.
+	URL  string
+	Host string
+}
+
+// ParseToolCall extracts the typed inputs of a tool call. Relative paths
+// are resolved against cwd. WebFetch URLs that do not parse or have no host
+// are an error, which CanUseTool turns into a deny.
+func ParseToolCall(tool string, input json.RawMessage, cwd string) (ToolCall, error) {
+	call := ToolCall{Tool: tool, Input: input}
+	var fields struct {
+		Command      string `json:"command"`
+		FilePath     string `json:"file_path"`
+		NotebookPath string `json:"notebook_path"`
+		Path         string `json:"path"`
+		URL          string `json:"url"`
+	}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &fields); err != nil {
+			return call, fmt.Errorf("decode %s input: %w", tool, err)
+		}
+	}
+	switch tool {
+	case "Bash":
+		call.Command = fields.Command
+	case "Read", "Write", "Edit", "MultiEdit":
+		call.Path = resolvePath(fields.FilePath, cwd)
+	case "NotebookEdit":
+		call.Path = resolvePath(fields.NotebookPath, cwd)
+	case "Glob", "Grep", "LS":
+		// These default to the working directory.
+		if fields.Path == "" {
+			fields.Path = "."
+		}
+		call.Path = resolvePath(fields.Path, cwd)
+	case "WebFetch":
+		call.URL = fields.URL
+		u, err := url.Parse(fields.URL)
+		if err != nil {
+			return call, fmt.Errorf("parse WebFetch url: %w", err)
+		}
+		// Host rules cannot judge a URL without a host, such as a file: URL,
+		// so such calls are rejected rather than slipping past them.
+		if u.Hostname() == "" {
+			return call, fmt.Errorf("WebFetch url %q has no host", fields.URL)
+		}
+		call.Host = strings.ToLower(u.Hostname())
+	}
+	return call, nil
+}
+
+func resolvePath(p, cwd string) string {
+	if p == "" {
+		return ""
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(cwd, p)
+	}
+	return filepath.Clean(p)
+}
+
+// Rule matches tool calls on every matcher it sets; a call lacking the
+// attribute a matcher needs (a Command on Read, say) does not match.
+type Rule struct {
+	// Name identifies the rule in decisions and logs.
+	Name     string
+	Decision Decision
+	// Tool is a glob on the tool name, such as "Bash" or "mcp__github__*".
+	// Empty matches every tool.
+	Tool string
+	// Command matches the whole command line. Globs are anchored at both
+	// ends, so "curl *" misses "echo x && curl ..." while "ls *" also
+	// matches "ls && rm -rf x"; use a Regexp such as
+	// `(^|[\s;&|(])curl(\s|$)` to catch a command anywhere in the line.
+	Command Matcher
+	// Path is tried against the absolute path and, inside the working
+	// directory, the path relative to it.
+	Path Matcher
+	URL  Matcher
+	Host Matcher
+	// Reason is passed to the model when the rule denies a call.
+	Reason string
+}
+
+func (r Rule) Validate() error {
+	switch r.Decision {
+	case Allow, Deny, Ask:
+	default:
+		return fmt.Errorf("rule %q: unknown decision %q", r.Name, r.Decision)
+	}
+	if r.Tool == "" && r.Command == nil && r.Path == nil && r.URL == nil && r.Host == nil {
+		return fmt.Errorf("rule %q matches nothing specific; use the engine default instead", r.Name)
+	}
+	return nil
+}
+
+// Result is the decision for one tool call.
+type Result struct {
+	Call     ToolCall
+	Decision Decision
+	// Rule is the first rule that matched, or nil when the default applied.
+	Rule *Rule
+}
+
+func (r Result) RuleName() string {
+	if r.Rule == nil {
+		return "default"
+	}
+	return r.Rule.Name
+}
+
+// Engine evaluates tool calls against its rules in order; the first
+// matching rule decides.
+type Engine struct {
+	cwd          string
+	rules        []Rule
+	toolMatchers []Matcher
+	def          Decision
+	logger       *slog.Logger
+}
+
+// New builds an engine for a session running in cwd. Calls no rule
+// matches are decided by Ask unless WithDefault says otherwise. Rules
+// without a name are named after their position.
+func New(cwd string, rules ...Rule) (*Engine, error) {
+	abs, err := filepath.Abs(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("resolve policy cwd: %w", err)
+	}
+	e := &Engine{cwd: abs, def: Ask}
+	var errs []error
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule[%d]", i)
+		}
+		if err := rule.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var tool Matcher
+		if rule.Tool != "" {
+			tool = Glob(rule.Tool)
+		}
+		e.rules = append(e.rules, rule)
+		e.toolMatchers = append(e.toolMatchers, tool)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// WithDefault sets the decision for calls no rule matches.
+func (e *Engine) WithDefault(d Decision) *Engine {
+	e.def = d
+	return e
+}
+
+// WithLogger logs every decision to logger. Engines log nothing by
+// default; nil turns logging back off.
+func (e *Engine) WithLogger(logger *slog.Logger) *Engine {
+	e.logger = logger
+	return e
+}
+
+// Evaluate decides call and, with a logger set, logs the decision with the
+// matched rule.
+func (e *Engine) Evaluate(ctx context.Context, call ToolCall) Result {
+	result := Result{Call: call, Decision: e.def}
+	for i := range e.rules {
+		if e.matches(i, call) {
+			result.Rule = &e.rules[i]
+			result.Decision = e.rules[i].Decision
+			break
+		}
+	}
+	if e.logger != nil {
+		e.logger.InfoContext(ctx, "tool policy decision",
+			"tool", call.Tool,
+			"tool_use_id", call.ToolUseID,
+			"decision", result.Decision,
+			"rule", result.RuleName(),
+		)
+	}
+	return result
+}
+
+func (e *Engine) matches(i int, call ToolCall) bool {
+	rule := e.rules[i]
+	if tool := e.toolMatchers[i]; tool != nil && !tool.Match(call.Tool) {
+		return false
+	}
+	if !matchValue(rule.Command, call.Command, matchText) || !matchValue(rule.URL, call.URL, matchText) || !matchValue(rule.Host, call.Host, Matcher.Match) {
+		return false
+	}
+	if rule.Path != nil {
+		if call.Path == "" {
+			return false
+		}
+		matched := rule.Path.Match(call.Path)
+		if rel, ok := e.relative(call.Path); ok {
+			matched = matched || rule.Path.Match(rel)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func matchValue(m Matcher, value string, match func(Matcher, string) bool) bool {
+	if m == nil {
+		return true
+	}
+	return value != "" && match(m, value)
+}
+
+func (e *Engine) relative(p string) (string, bool) {
+	rel, err := filepath.Rel(e.cwd, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// CanUseTool returns a permission callback for
+// claude.ClientBuilder.WithCanUseTool. Ask decisions go to fallback; with
+// no fallback they are denied.
+func (e *Engine) CanUseTool(fallback claude.CanUseToolFunc) claude.CanUseToolFunc {
+	return func(ctx context.Context, req claude.ToolPermissionRequest) (claude.PermissionResult, error) {
+		call, err := ParseToolCall(req.ToolName, req.Input, e.cwd)
+		if err != nil {
+			return claude.PermissionResult{Decision: claude.PermissionDecisionDeny, Message: err.Error()}, nil
+		}
+		call.ToolUseID = req.ToolUseID
+		result := e.Evaluate(ctx, call)
+		switch result.Decision {
+		case Allow:
+			return claude.PermissionResult{Decision: claude.PermissionDecisionAllow}, nil
+		case Deny:
+			return claude.PermissionResult{Decision: claude.PermissionDecisionDeny, Message: denyMessage(result)}, nil
+		}
+		if fallback != nil {
+			return fallback(ctx, req)
+		}
+		return claude.PermissionResult{
+			Decision: claude.PermissionDecisionDeny,
+			Message:  fmt.Sprintf("%s requires approval (policy %s)", req.ToolName, result.RuleName()),
+		}, nil
+	}
+}
+
+func denyMessage(result Result) string {
+	msg := fmt.Sprintf("%s denied by policy %s", result.Call.Tool, result.RuleName())
+	if result.Rule != nil && result.Rule.Reason != "" {
+		msg += ": " + result.Rule.Reason
+	}
+	return msg
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+)
+
+func testEngine(t *testing.T) *Engine {
+	t.Helper()
+	e, err := New("/work",
+		Rule{Name: "no-wget", Decision: Deny, Tool: "Bash", Command: Glob("wget *")},
+		Rule{Name: "no-curl", Decision: Deny, Tool: "Bash", Command: MustRegexp(`(^|[\s;&|(])curl(\s|$)`), Reason: "network access goes through WebFetch"},
+		Rule{Name: "bash", Decision: Allow, Tool: "Bash"},
+		Rule{Name: "edit-src", Decision: Allow, Tool: "Edit", Path: Glob("./src/**")},
+		Rule{Name: "edit-other", Decision: Deny, Tool: "Edit"},
+		Rule{Name: "fetch-allowlist", Decision: Deny, Tool: "WebFetch", Host: Not(Glob("example.com", "*.example.com"))},
+		Rule{Name: "github", Decision: Ask, Tool: "mcp__github__*"},
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return e.WithDefault(Allow)
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	e := testEngine(t)
+	tests := []struct {
+		tool     string
+		input    string
+		decision Decision
+		rule     string
+	}{
+		{"Bash", `{"command":"ls && curl https://x.test"}`, Deny, "no-curl"},
+		{"Bash", `{"command":"go test ./..."}`, Allow, "bash"},
+		{"Bash", `{"command":"wget https://evil.test/x"}`, Deny, "no-wget"},
+		// Command globs match the whole line.
+		{"Bash", `{"command":"echo x && wget https://evil.test/x"}`, Allow, "bash"},
+		{"Edit", `{"file_path":"src/main.go"}`, Allow, "edit-src"},
+		{"Edit", `{"file_path":"/work/src/pkg/a.go"}`, Allow, "edit-src"},
+		{"Edit", `{"file_path":"src/../../etc/passwd"}`, Deny, "edit-other"},
+		{"Edit", `{"file_path":"README.md"}`, Deny, "edit-other"},
+		{"WebFetch", `{"url":"https://docs.example.com/a"}`, Allow, "default"},
+		{"WebFetch", `{"url":"https://evil.test/a"}`, Deny, "fetch-allowlist"},
+		{"mcp__github__create_issue", `{}`, Ask, "github"},
+		{"Read", `{"file_path":"/etc/hosts"}`, Allow, "default"},
+	}
+	for _, tt := range tests {
+		call, err := ParseToolCall(tt.tool, json.RawMessage(tt.input), "/work")
+		if err != nil {
+			t.Fatalf("ParseToolCall(%s) error = %v", tt.input, err)
+		}
+		got := e.Evaluate(context.Background(), call)
+		if got.Decision != tt.decision || got.RuleName() != tt.rule {
+			t.Errorf("Evaluate(%s %s) = %s by %s, want %s by %s", tt.tool, tt.input, got.Decision, got.RuleName(), tt.decision, tt.rule)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"src/**", "src", true},
+		{"src/**", "src/a/b.go", true},
+		{"src/**", "srcs/a.go", false},
+		{"*.go", "a.go", true},
+		{"*.go", "pkg/a.go", false},
+		{"**/*.go", "pkg/a.go", true},
+		{"a?c", "abc", true},
+		{"a.c", "abc", false},
+	}
+	for _, tt := range tests {
+		if got := Glob(tt.pattern).Match(tt.value); got != tt.want {
+			t.Errorf("Glob(%q).Match(%q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseToolCallRejectsHostlessURL(t *testing.T) {
+	for _, u := range []string{"file:///etc/passwd", "%zz", "/local/path"} {
+		if _, err := ParseToolCall("WebFetch", json.RawMessage(`{"url":"`+u+`"}`), "/work"); err == nil {
+			t.Errorf("ParseToolCall(%q) error = nil, want error", u)
+		}
+	}
+
+	result, err := testEngine(t).CanUseTool(nil)(context.Background(), claude.ToolPermissionRequest{ToolName: "WebFetch", Input: json.RawMessage(`{"url":"file:///etc/passwd"}`)})
+	if err != nil {
+		t.Fatalf("CanUseTool() error = %v", err)
+	}
+	if result.Decision != claude.PermissionDecisionDeny {
+		t.Fatalf("result = %+v, want deny", result)
+	}
+}
+
+func TestNewValidatesRules(t *testing.T) {
+	if _, err := New("/work", Rule{Decision: "maybe", Tool: "Bash"}, Rule{Decision: Deny}); err == nil {
+		t.Fatalf("New() error = nil, want invalid rules")
+	}
+	if _, err := Regexp("("); err == nil {
+		t.Fatalf("Regexp(\"(\") error = nil, want error")
+	}
+}
+
+func TestEngineCanUseTool(t *testing.T) {
+	var logs bytes.Buffer
+	e := testEngine(t).WithLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	ctx := context.Background()
+
+	result, err := e.CanUseTool(nil)(ctx, claude.ToolPermissionRequest{ToolName: "Bash", ToolUseID: "tu1", Input: json.RawMessage(`{"command":"curl x"}`)})
+	if err != nil {
+		t.Fatalf("CanUseTool() error = %v", err)
+	}
+	if result.Decision != claude.PermissionDecisionDeny || !strings.Contains(result.Message, "no-curl: network access") {
+		t.Fatalf("result = %+v, want deny by no-curl", result)
+	}
+	if !strings.Contains(logs.String(), "rule=no-curl") || !strings.Contains(logs.String(), "tool_use_id=tu1") {
+		t.Fatalf("logs = %q, want decision logged with rule", logs.String())
+	}
+
+	ask := claude.ToolPermissionRequest{ToolName: "mcp__github__create_issue", Input: json.RawMessage(`{}`)}
+	if result, _ := e.CanUseTool(nil)(ctx, ask); result.Decision != claude.PermissionDecisionDeny {
+		t.Fatalf("ask without fallback = %+v, want deny", result)
+	}
+	var asked bool
+	fallback := func(ctx context.Context, req claude.ToolPermissionRequest) (claude.PermissionResult, error) {
+		asked = true
+		return claude.PermissionResult{Decision: claude.PermissionDecisionAllow}, nil
+	}
+	if result, _ := e.CanUseTool(fallback)(ctx, ask); !asked || result.Decision != claude.PermissionDecisionAllow {
+		t.Fatalf("ask with fallback = %+v (asked %v), want fallback allow", result, asked)
+	}
+}
@@ -0,0 +1,427 @@
+package claude
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// Job is one prompt run by a Pool on its own session.
+type Job struct {
+	ID     string
+	Prompt string
+	// Cwd overrides the working directory of the job's CLI process.
+	Cwd string
+	// Configure applies per-job options to a fresh builder. Jobs with a
+	// Cwd or Configure never use warm clients.
+	Configure func(*ClientBuilder)
+	// Timeout overrides the pool's job timeout; the time spent queued
+	// does not count.
+	Timeout time.Duration
+	// Group is the fairness key: queued jobs are taken round-robin across
+	// groups, in submission order within a group.
+	Group string
+}
+
+// JobResult is the outcome of a Job.
+type JobResult struct {
+	Job  Job
+	Turn *TurnResult
+	Err  error
+	// Warm is set when the job ran on a pre-spawned client.
+	Warm     bool
+	Duration time.Duration
+}
+
+func (r JobResult) CostUSD() float64 {
+	if r.Turn == nil {
+		return 0
+	}
+	return r.Turn.CostUSD
+}
+
+// PoolStats aggregates the jobs a pool has finished.
+type PoolStats struct {
+	Queued    int
+	Running   int
+	Succeeded int
+	Failed    int
+	// CostUSD includes retried attempts, like TurnResult.CostUSD.
+	CostUSD float64
+	// Usage sums TurnResult.Usage, which covers only each job's final
+	// attempt, so it undercounts jobs that were retried.
+	Usage Usage
+}
+
+// JobHandle tracks a submitted job.
+type JobHandle struct {
+	done   chan struct{}
+	result JobResult
+}
+
+// Done is closed once the job has finished.
+func (h *JobHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Wait blocks until the job has finished and returns its result.
+func (h *JobHandle) Wait() JobResult {
+	<-h.done
+	return h.result
+}
+
+type poolTask struct {
+	ctx    context.Context
+	job    Job
+	handle *JobHandle
+	// stop unregisters the callback that drops the task from the queue
+	// when ctx is cancelled.
+	stop func() bool
+}
+
+// Pool runs jobs on up to maxConcurrency CLI processes at once. Each job
+// gets its own process and session; warm clients are processes started
+// ahead of time that wait for their first stream-json prompt.
+type Pool struct {
+	newBuilder func() *ClientBuilder
+	ctx        context.Context
+	cancel     context.CancelFunc
+	workers    sync.WaitGroup
+
+	mu         sync.Mutex
+	cond       *sync.Cond
+	queue      fairQueue
+	closed     bool
+	jobTimeout time.Duration
+	retry      *RetryPolicy
+	warmSize   int
+	warm       []*Client
+	warming    int
+	stats      PoolStats
+}
+
+// NewPool starts a pool whose jobs build clients from newBuilder, which
+// must return a fresh builder on each call.
+func NewPool(newBuilder func() *ClientBuilder, maxConcurrency int) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{newBuilder: newBuilder, ctx: ctx, cancel: cancel, queue: newFairQueue()}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < max(maxConcurrency, 1); i++ {
+		p.workers.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// WithJobTimeout bounds each job's run time unless the job sets its own.
+func (p *Pool) WithJobTimeout(d time.Duration) *Pool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jobTimeout = d
+	return p
+}
+
+// WithRetry retries jobs that fail on transient API errors, as
+// Chat.WithRetry does.
+func (p *Pool) WithRetry(policy RetryPolicy) *Pool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retry = &policy
+	return p
+}
+
+// WithWarmClients keeps n idle processes ready for jobs without a Cwd or
+// Configure, replacing each one as it is taken. It needs a CLI that
+// accepts --input-format stream-json.
+func (p *Pool) WithWarmClients(n int) *Pool {
+	p.mu.Lock()
+	p.warmSize = n
+	p.mu.Unlock()
+	p.replenish()
+	return p
+}
+
+func (p *Pool) replenish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for !p.closed && len(p.warm)+p.warming < p.warmSize {
+		p.warming++
+		go p.spawnWarm()
+	}
+}
+
+func (p *Pool) spawnWarm() {
+	client, err := p.newBuilder().WithStreamingInput(true).Build(p.ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.warming--
+	if err != nil {
+		// Jobs fall back to cold clients; the next take retries.
+		return
+	}
+	if p.closed {
+		_ = client.Close()
+		return
+	}
+	p.warm = append(p.warm, client)
+}
+
+func (p *Pool) takeWarm() *Client {
+	p.mu.Lock()
+	if len(p.warm) == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	client := p.warm[0]
+	p.warm = p.warm[1:]
+	p.mu.Unlock()
+	p.replenish()
+	return client
+}
+
+// Submit queues job. Cancelling ctx cancels the job, queued or running; a
+// queued job finishes with ctx's error straight away.
+func (p *Pool) Submit(ctx context.Context, job Job) *JobHandle {
+	h := &JobHandle{done: make(chan struct{})}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		h.result = JobResult{Job: job, Err: clerrors.ErrPoolClosed}
+		close(h.done)
+		return h
+	}
+	task := &poolTask{ctx: ctx, job: job, handle: h}
+	p.queue.push(task)
+	p.stats.Queued++
+	task.stop = context.AfterFunc(ctx, func() { p.cancelQueued(task) })
+	p.cond.Signal()
+	return h
+}
+
+// cancelQueued finishes task with its context's error if it is still
+// waiting for a worker.
+func (p *Pool) cancelQueued(task *poolTask) {
+	p.mu.Lock()
+	if !p.queue.remove(task) {
+		p.mu.Unlock()
+		return
+	}
+	p.stats.Queued--
+	result := JobResult{Job: task.job, Err: task.ctx.Err()}
+	p.record(result)
+	p.mu.Unlock()
+	task.handle.result = result
+	close(task.handle.done)
+}
+
+// Run submits jobs and waits for all of them, returning results in job
+// order.
+func (p *Pool) Run(ctx context.Context, jobs ...Job) []JobResult {
+	handles := make([]*JobHandle, len(jobs))
+	for i, job := range jobs {
+		handles[i] = p.Submit(ctx, job)
+	}
+	results := make([]JobResult, len(jobs))
+	for i, h := range handles {
+		results[i] = h.Wait()
+	}
+	return results
+}
+
+// Stats returns the pool's counters and the cost and usage of finished
+// jobs.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+	for {
+		p.mu.Lock()
+		for p.queue.len() == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		task := p.queue.pop()
+		task.stop()
+		p.stats.Queued--
+		p.stats.Running++
+		timeout, retry := p.jobTimeout, p.retry
+		p.mu.Unlock()
+
+		result := p.run(task, timeout, retry)
+
+		p.mu.Lock()
+		p.stats.Running--
+		p.record(result)
+		p.mu.Unlock()
+		task.handle.result = result
+		close(task.handle.done)
+	}
+}
+
+func (p *Pool) run(task *poolTask, timeout time.Duration, retry *RetryPolicy) JobResult {
+	job := task.job
+	result := JobResult{Job: job}
+	if err := task.ctx.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+	if job.Timeout > 0 {
+		timeout = job.Timeout
+	}
+	ctx, cancel := context.WithCancel(task.ctx)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(task.ctx, timeout)
+	}
+	defer cancel()
+	stop := context.AfterFunc(p.ctx, cancel)
+	defer stop()
+
+	b := p.newBuilder()
+	if job.Cwd != "" {
+		b.WithCwd(job.Cwd)
+	}
+	if job.Configure != nil {
+		job.Configure(b)
+	}
+	chat := NewChat(b)
+	if retry != nil {
+		chat.WithRetry(*retry)
+	}
+	if job.Cwd == "" && job.Configure == nil {
+		if client := p.takeWarm(); client != nil {
+			// A warm client that died while idle is restarted by Send.
+			chat.client = client
+			result.Warm = true
+		}
+	}
+
+	start := time.Now()
+	result.Turn, result.Err = chat.Send(ctx, job.Prompt)
+	result.Duration = time.Since(start)
+	_ = chat.Close()
+	if result.Err != nil && ctx.Err() != nil {
+		result.Err = ctx.Err()
+	}
+	return result
+}
+
+func (p *Pool) record(result JobResult) {
+	if result.Err != nil || result.Turn == nil || result.Turn.Result == nil || result.Turn.Result.IsError {
+		p.stats.Failed++
+	} else {
+		p.stats.Succeeded++
+	}
+	if result.Turn == nil {
+		return
+	}
+	p.stats.CostUSD += result.Turn.CostUSD
+	if u := result.Turn.Usage; u != nil {
+		p.stats.Usage.InputTokens += u.InputTokens
+		p.stats.Usage.OutputTokens += u.OutputTokens
+		p.stats.Usage.CacheCreationInputToken += u.CacheCreationInputToken
+		p.stats.Usage.CacheReadInputTokens += u.CacheReadInputTokens
+	}
+}
+
+// Close cancels running jobs, fails queued ones with ErrPoolClosed and
+// stops the warm clients.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	var queued []*poolTask
+	for p.queue.len() > 0 {
+		task := p.queue.pop()
+		task.stop()
+		task.handle.result = JobResult{Job: task.job, Err: clerrors.ErrPoolClosed}
+		p.record(task.handle.result)
+		queued = append(queued, task)
+	}
+	p.stats.Queued = 0
+	warm := p.warm
+	p.warm = nil
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	p.cancel()
+	for _, task := range queued {
+		close(task.handle.done)
+	}
+	p.workers.Wait()
+	for _, client := range warm {
+		_ = client.CloseInput()
+		_ = client.Close()
+	}
+	return nil
+}
+
+// fairQueue is FIFO within a group and round-robin across groups.
+type fairQueue struct {
+	groups map[string][]*poolTask
+	ring   []string
+}
+
+func newFairQueue() fairQueue {
+	return fairQueue{groups: map[string][]*poolTask{}}
+}
+
+func (q *fairQueue) push(t *poolTask) {
+	g := t.job.Group
+	if len(q.groups[g]) == 0 {
+		q.ring = append(q.ring, g)
+	}
+	q.groups[g] = append(q.groups[g], t)
+}
+
+func (q *fairQueue) pop() *poolTask {
+	g := q.ring[0]
+	q.ring = q.ring[1:]
+	t := q.groups[g][0]
+	if rest := q.groups[g][1:]; len(rest) > 0 {
+		q.groups[g] = rest
+		q.ring = append(q.ring, g)
+	} else {
+		delete(q.groups, g)
+	}
+	return t
+}
+
+// remove drops t if it is still queued.
+func (q *fairQueue) remove(t *poolTask) bool {
+	g := t.job.Group
+	tasks := q.groups[g]
+	for i, queued := range tasks {
+		if queued != t {
+			continue
+		}
+		if len(tasks) > 1 {
+			q.groups[g] = append(tasks[:i:i], tasks[i+1:]...)
+			return true
+		}
+		delete(q.groups, g)
+		for j, name := range q.ring {
+			if name == g {
+				q.ring = append(q.ring[:j:j], q.ring[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (q *fairQueue) len() int {
+	return len(q.ring)
+}
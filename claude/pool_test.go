@@ -0,0 +1,235 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// poolScript answers one stream-json prompt with the process's cwd as the
+// result, after $DELAY seconds.
+const poolScript = `read -r line || exit 0
+echo "{\"type\":\"system\",\"subtype\":\"init\",\"session_id\":\"s-$$\"}"
+sleep ${DELAY:-0}
+echo "{\"type\":\"result\",\"subtype\":\"success\",\"session_id\":\"s-$$\",\"result\":\"$(pwd)\",\"total_cost_usd\":0.25,\"usage\":{\"input_tokens\":10,\"output_tokens\":2}}"
+cat >/dev/null
+`
+
+type concurrencyGauge struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (g *concurrencyGauge) observe(ctx context.Context, msg Message) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch msg.(type) {
+	case *SystemInitMessage:
+		g.running++
+		g.peak = max(g.peak, g.running)
+	case *ResultMessage:
+		g.running--
+	}
+	return nil
+}
+
+func TestPoolRunsJobsConcurrently(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	fake := newFakeCLI(poolScript)
+	gauge := &concurrencyGauge{}
+	pool := NewPool(func() *ClientBuilder {
+		return fake.builder().WithEnv("DELAY", "0.2").WithObserver(gauge.observe)
+	}, 2)
+	defer pool.Close()
+
+	cwd := t.TempDir()
+	jobs := []Job{{ID: "a", Prompt: "p"}, {ID: "b", Prompt: "p", Cwd: cwd}, {ID: "c", Prompt: "p"}, {ID: "d", Prompt: "p"}}
+	results := pool.Run(ctx, jobs...)
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatalf("job %s error = %v", result.Job.ID, result.Err)
+		}
+		if result.Job.ID != jobs[i].ID {
+			t.Fatalf("result %d is job %s, want %s", i, result.Job.ID, jobs[i].ID)
+		}
+	}
+	if got := results[1].Turn.Text(); got != cwd {
+		t.Fatalf("job b ran in %q, want %q", got, cwd)
+	}
+	if gauge.peak > 2 {
+		t.Fatalf("peak concurrency = %d, want at most 2", gauge.peak)
+	}
+
+	stats := pool.Stats()
+	if stats.Succeeded != 4 || stats.Failed != 0 || stats.Running != 0 || stats.Queued != 0 {
+		t.Fatalf("stats = %+v, want 4 succeeded", stats)
+	}
+	if math.Abs(stats.CostUSD-1.0) > 1e-9 || stats.Usage.InputTokens != 40 {
+		t.Fatalf("stats cost/usage = %v/%+v, want 1.0 and 40 input tokens", stats.CostUSD, stats.Usage)
+	}
+}
+
+func TestPoolWarmClients(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fake := newFakeCLI(poolScript)
+	pool := NewPool(fake.builder, 1).WithWarmClients(1)
+	defer pool.Close()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		pool.mu.Lock()
+		ready := len(pool.warm)
+		pool.mu.Unlock()
+		if ready == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("warm client was not started")
+		}
+	}
+
+	result := pool.Submit(ctx, Job{Prompt: "p"}).Wait()
+	if result.Err != nil || !result.Warm {
+		t.Fatalf("result = %+v, want success on a warm client", result)
+	}
+	if !containsArg(fake.lastArgs(), "--input-format") {
+		t.Fatalf("args = %v, want stream-json input", fake.lastArgs())
+	}
+	configured := pool.Submit(ctx, Job{Prompt: "p", Configure: func(b *ClientBuilder) { b.WithModel("haiku") }}).Wait()
+	if configured.Err != nil || configured.Warm {
+		t.Fatalf("result = %+v, want success on a cold client", configured)
+	}
+	if argValue(fake.lastArgs(), "--model") != "haiku" {
+		t.Fatalf("args = %v, want per-job model", fake.lastArgs())
+	}
+}
+
+func TestPoolTimeoutAndCancel(t *testing.T) {
+	fake := newFakeCLI(poolScript)
+	pool := NewPool(func() *ClientBuilder { return fake.builder().WithEnv("DELAY", "5") }, 1)
+	defer pool.Close()
+
+	result := pool.Submit(context.Background(), Job{Prompt: "p", Timeout: 100 * time.Millisecond}).Wait()
+	if !errors.Is(result.Err, context.DeadlineExceeded) {
+		t.Fatalf("timeout error = %v, want DeadlineExceeded", result.Err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	running := pool.Submit(ctx, Job{Prompt: "p"})
+	queued := pool.Submit(ctx, Job{Prompt: "p"})
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if err := running.Wait().Err; !errors.Is(err, context.Canceled) {
+		t.Fatalf("running job error = %v, want Canceled", err)
+	}
+	if err := queued.Wait().Err; !errors.Is(err, context.Canceled) {
+		t.Fatalf("queued job error = %v, want Canceled", err)
+	}
+	if stats := pool.Stats(); stats.Failed != 3 {
+		t.Fatalf("stats = %+v, want 3 failed", stats)
+	}
+
+	_ = pool.Close()
+	if err := pool.Submit(context.Background(), Job{Prompt: "p"}).Wait().Err; !errors.Is(err, clerrors.ErrPoolClosed) {
+		t.Fatalf("Submit after Close error = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestPoolCancelQueuedJob(t *testing.T) {
+	fake := newFakeCLI(poolScript)
+	pool := NewPool(func() *ClientBuilder { return fake.builder().WithEnv("DELAY", "5") }, 1)
+	defer pool.Close()
+
+	busy := pool.Submit(context.Background(), Job{Prompt: "p"})
+	for deadline := time.Now().Add(2 * time.Second); pool.Stats().Running == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("first job never started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := pool.Submit(ctx, Job{ID: "q", Prompt: "p"})
+	cancel()
+
+	select {
+	case <-queued.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("cancelled queued job still waiting for a worker")
+	}
+	if result := queued.Wait(); result.Job.ID != "q" || !errors.Is(result.Err, context.Canceled) {
+		t.Fatalf("queued result = %+v, want Canceled", result)
+	}
+	if stats := pool.Stats(); stats.Queued != 0 || stats.Failed != 1 {
+		t.Fatalf("stats = %+v, want nothing queued and 1 failed", stats)
+	}
+	select {
+	case <-busy.Done():
+		t.Fatalf("running job finished early: %+v", busy.Wait())
+	default:
+	}
+}
+
+func TestPoolCloseFailsQueuedJobs(t *testing.T) {
+	fake := newFakeCLI(poolScript)
+	pool := NewPool(func() *ClientBuilder { return fake.builder().WithEnv("DELAY", "5") }, 1)
+
+	busy := pool.Submit(context.Background(), Job{Prompt: "p"})
+	for deadline := time.Now().Add(2 * time.Second); pool.Stats().Running == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("first job never started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	queued := pool.Submit(context.Background(), Job{Prompt: "p"})
+	_ = pool.Close()
+
+	if err := queued.Wait().Err; !errors.Is(err, clerrors.ErrPoolClosed) {
+		t.Fatalf("queued job error = %v, want ErrPoolClosed", err)
+	}
+	if err := busy.Wait().Err; err == nil {
+		t.Fatalf("running job error = nil, want cancellation")
+	}
+	if stats := pool.Stats(); stats.Queued != 0 || stats.Running != 0 || stats.Failed != 2 {
+		t.Fatalf("stats = %+v, want 2 failed", stats)
+	}
+}
+
+func TestFairQueue(t *testing.T) {
+	q := newFairQueue()
+	for _, id := range []string{"a1", "a2", "a3", "b1", "b2", "c1"} {
+		q.push(&poolTask{job: Job{ID: id, Group: id[:1]}})
+	}
+	var order []string
+	for q.len() > 0 {
+		order = append(order, q.pop().job.ID)
+	}
+	want := []string{"a1", "b1", "c1", "a2", "b2", "a3"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+
+	tasks := map[string]*poolTask{}
+	for _, id := range []string{"a1", "a2", "b1", "c1"} {
+		tasks[id] = &poolTask{job: Job{ID: id, Group: id[:1]}}
+		q.push(tasks[id])
+	}
+	if !q.remove(tasks["a1"]) || !q.remove(tasks["b1"]) || q.remove(tasks["b1"]) {
+		t.Fatalf("remove() results wrong")
+	}
+	order = nil
+	for q.len() > 0 {
+		order = append(order, q.pop().job.ID)
+	}
+	if want := []string{"a2", "c1"}; len(order) != 2 || order[0] != want[0] || order[1] != want[1] {
+		t.Fatalf("order after remove = %v, want %v", order, want)
+	}
+}
@@ -42,6 +42,11 @@ type protocol struct {
 
 	writeMu sync.Mutex
 	nextID  int64
+	// sendRPC writes a JSON-RPC message; middleware wraps it.
+	sendRPC JSONRPCFunc
+
+	serverMu   sync.Mutex
+	serverCaps *ServerCapabilities
 
 	readCh chan parsedItem
 }
@@ -53,6 +58,7 @@ func NewProtocol(r io.Reader, w io.Writer) Protocol {
 		nextID: 1,
 		readCh: make(chan parsedItem, 128),
 	}
+	p.sendRPC = p.encodeRPC
 	if closer, ok := w.(io.Closer); ok {
 		p.writerCloser = closer
 	}
@@ -115,10 +121,22 @@ func (p *protocol) NextMessage(ctx context.Context) (Message, error) {
 }
 
 func (p *protocol) MCPInitialize(ctx context.Context, params InitializeParams) (*InitializeResult, error) {
+	if params.ProtocolVersion == "" {
+		params.ProtocolVersion = LatestMCPProtocolVersion
+	}
+
 	var out InitializeResult
 	if err := p.request(ctx, "initialize", params, &out); err != nil {
 		return nil, err
 	}
+	if !IsSupportedMCPProtocolVersion(out.ProtocolVersion) {
+		return nil, fmt.Errorf("%w: server replied %q, supported %s", clerrors.ErrUnsupportedProtocolVersion, out.ProtocolVersion, strings.Join(SupportedMCPProtocolVersions, ", "))
+	}
+
+	caps := out.Capabilities
+	p.serverMu.Lock()
+	p.serverCaps = &caps
+	p.serverMu.Unlock()
 	return &out, nil
 }
 
@@ -127,6 +145,10 @@ func (p *protocol) MCPInitialized(ctx context.Context) error {
 }
 
 func (p *protocol) MCPToolsList(ctx context.Context) (*ToolsListResult, error) {
+	if err := p.requireCapability(MCPCapabilityTools); err != nil {
+		return nil, err
+	}
+
 	var out ToolsListResult
 	if err := p.request(ctx, "tools/list", struct{}{}, &out); err != nil {
 		return nil, err
@@ -135,6 +157,10 @@ func (p *protocol) MCPToolsList(ctx context.Context) (*ToolsListResult, error) {
 }
 
 func (p *protocol) MCPToolsCall(ctx context.Context, params ToolsCallParams) (*ToolsCallResult, error) {
+	if err := p.requireCapability(MCPCapabilityTools); err != nil {
+		return nil, err
+	}
+
 	var out ToolsCallResult
 	if err := p.request(ctx, "tools/call", params, &out); err != nil {
 		return nil, err
@@ -142,6 +168,18 @@ func (p *protocol) MCPToolsCall(ctx context.Context, params ToolsCallParams) (*T
 	return &out, nil
 }
 
+// requireCapability rejects a call when initialize has completed and the
+// server did not advertise the capability. Before initialize nothing is
+// known, so calls are let through.
+func (p *protocol) requireCapability(capability MCPCapability) error {
+	p.serverMu.Lock()
+	defer p.serverMu.Unlock()
+	if p.serverCaps == nil || p.serverCaps.Supports(capability) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", clerrors.ErrCapabilityNotSupported, capability)
+}
+
 func (p *protocol) Close() error {
 	var firstErr error
 	if p.writerCloser != nil {
@@ -216,7 +254,7 @@ func (p *protocol) writeJSONRPCRequest(ctx context.Context, method string, param
 		Method:  method,
 		Params:  params,
 	}
-	if err := json.NewEncoder(p.writer).Encode(req); err != nil {
+	if err := p.sendRPC(ctx, &req); err != nil {
 		return 0, fmt.Errorf("write jsonrpc request: %w", err)
 	}
 	return id, nil
@@ -235,12 +273,16 @@ func (p *protocol) writeJSONRPCNotification(ctx context.Context, method string,
 		Method:  method,
 		Params:  params,
 	}
-	if err := json.NewEncoder(p.writer).Encode(req); err != nil {
+	if err := p.sendRPC(ctx, &req); err != nil {
 		return 0, fmt.Errorf("write jsonrpc notification: %w", err)
 	}
 	return 0, nil
 }
 
+func (p *protocol) encodeRPC(ctx context.Context, req *JSONRPCRequest) error {
+	return json.NewEncoder(p.writer).Encode(req)
+}
+
 func inputPayload(input UserInput) (string, error) {
 	inputType := input.Type
 	populated := populatedUserInputTypes(input)
@@ -399,3 +399,70 @@ func TestProtocolMCPToolsListAndCall(t *testing.T) {
 		t.Fatalf("req2 id = %v, want 2", req2.ID)
 	}
 }
+
+func TestProtocolMCPInitializeSendsProtocolVersionAndCapabilities(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","serverInfo":{"name":"s"},"capabilities":{"tools":{"listChanged":true},"resources":{"subscribe":true},"logging":{}}}}` + "\n")
+	var out bytes.Buffer
+	p := NewProtocol(in, &out)
+
+	resp, err := p.MCPInitialize(context.Background(), InitializeParams{
+		ClientInfo:   ClientInfo{Name: "agentkit"},
+		Capabilities: ClientCapabilities{Roots: &RootsCapability{ListChanged: true}, Sampling: &SamplingCapability{}},
+	})
+	if err != nil {
+		t.Fatalf("MCPInitialize() error = %v", err)
+	}
+	if resp.Capabilities.Tools == nil || !resp.Capabilities.Tools.ListChanged {
+		t.Fatalf("tools capability = %+v, want listChanged", resp.Capabilities.Tools)
+	}
+	if resp.Capabilities.Resources == nil || !resp.Capabilities.Resources.Subscribe {
+		t.Fatalf("resources capability = %+v, want subscribe", resp.Capabilities.Resources)
+	}
+	if !resp.Capabilities.Supports(MCPCapabilityLogging) || resp.Capabilities.Supports(MCPCapabilityPrompts) {
+		t.Fatalf("capabilities = %+v, want logging without prompts", resp.Capabilities)
+	}
+
+	var req struct {
+		Params map[string]json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(out.Bytes()), &req); err != nil {
+		t.Fatalf("unmarshal request: %v", err)
+	}
+	if string(req.Params["protocolVersion"]) != `"`+LatestMCPProtocolVersion+`"` {
+		t.Fatalf("protocolVersion = %s, want %s", req.Params["protocolVersion"], LatestMCPProtocolVersion)
+	}
+	if string(req.Params["capabilities"]) != `{"roots":{"listChanged":true},"sampling":{}}` {
+		t.Fatalf("capabilities = %s, want roots and sampling", req.Params["capabilities"])
+	}
+}
+
+func TestProtocolMCPInitializeRejectsUnsupportedVersion(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"1999-01-01","capabilities":{"tools":{}}}}` + "\n")
+	p := NewProtocol(in, &bytes.Buffer{})
+
+	_, err := p.MCPInitialize(context.Background(), InitializeParams{})
+	if !errors.Is(err, clerrors.ErrUnsupportedProtocolVersion) {
+		t.Fatalf("MCPInitialize() err = %v, want ErrUnsupportedProtocolVersion", err)
+	}
+}
+
+func TestProtocolMCPRefusesUnadvertisedCapability(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2024-11-05","capabilities":{"prompts":{}}}}` + "\n")
+	var out bytes.Buffer
+	p := NewProtocol(in, &out)
+
+	if _, err := p.MCPInitialize(context.Background(), InitializeParams{}); err != nil {
+		t.Fatalf("MCPInitialize() error = %v", err)
+	}
+	out.Reset()
+
+	if _, err := p.MCPToolsList(context.Background()); !errors.Is(err, clerrors.ErrCapabilityNotSupported) {
+		t.Fatalf("MCPToolsList() err = %v, want ErrCapabilityNotSupported", err)
+	}
+	if _, err := p.MCPToolsCall(context.Background(), ToolsCallParams{Name: "x"}); !errors.Is(err, clerrors.ErrCapabilityNotSupported) {
+		t.Fatalf("MCPToolsCall() err = %v, want ErrCapabilityNotSupported", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("written = %q, want nothing", out.String())
+	}
+}
@@ -0,0 +1,166 @@
+package claude
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Result subtypes that retrying cannot fix.
+const (
+	ResultSubtypeErrorMaxTurns     = "error_max_turns"
+	ResultSubtypeErrorMaxBudgetUSD = "error_max_budget_usd"
+)
+
+// transientAPIError matches the CLI's "API Error: ..." texts for statuses
+// and failures worth retrying, and the API's overloaded and rate-limit
+// error types, but not arbitrary numbers or tool timeouts.
+var transientAPIError = regexp.MustCompile(`(?i)\bAPI Error: (?:(?:429|5\d\d)\b|request timed out|connection error)|\b(?:overloaded|rate_limit)_error\b`)
+
+// RetryPolicy makes Chat.Send retry turns that failed on transient API
+// errors. Zero fields take the defaults noted on each.
+type RetryPolicy struct {
+	// MaxAttempts caps attempts per turn, the first included; default 3.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; default 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay; default 30s.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after each retry; default 2.
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction either way;
+	// default 0.2, negative disables it.
+	Jitter float64
+	// MaxCostUSD stops retrying once the turn's attempts together cost
+	// this much; 0 means no limit.
+	MaxCostUSD float64
+	// Classify decides whether a failed turn is retryable and why;
+	// default ClassifyTurn.
+	Classify func(turn *TurnResult) (retryable bool, reason string)
+	// OnRetry is called before waiting for each retry.
+	OnRetry func(RetryAttempt)
+}
+
+// RetryAttempt reports a failed attempt that is about to be retried.
+type RetryAttempt struct {
+	// Attempt is the 1-based number of the failed attempt.
+	Attempt   int
+	SessionID string
+	Reason    string
+	Delay     time.Duration
+	Turn      *TurnResult
+	// SpentUSD is the cost of the turn's attempts so far.
+	SpentUSD float64
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = time.Second
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 30 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Jitter == 0 {
+		p.Jitter = 0.2
+	}
+	if p.Classify == nil {
+		p.Classify = ClassifyTurn
+	}
+	return p
+}
+
+// backoff returns the delay after the given failed attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	d = math.Min(d, float64(p.MaxBackoff))
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+// ClassifyTurn reports a failed turn as retryable when its result errors
+// are overloaded, rate-limited or unavailable API errors, or when the
+// result gives no error text and the CLI reported API errors during the
+// turn. Turn and budget limits are fatal.
+func ClassifyTurn(turn *TurnResult) (bool, string) {
+	if turn == nil || turn.Result == nil || !turn.Result.IsError {
+		return false, ""
+	}
+	switch turn.Result.Subtype {
+	case ResultSubtypeErrorMaxTurns, ResultSubtypeErrorMaxBudgetUSD:
+		return false, turn.Result.Subtype
+	}
+	explained := false
+	for _, text := range append(append([]string(nil), turn.Result.Errors...), turn.Result.Result) {
+		if transientAPIError.MatchString(text) {
+			return true, text
+		}
+		explained = explained || strings.TrimSpace(text) != ""
+	}
+	if explained {
+		return false, strings.Join(turn.Result.Errors, "; ")
+	}
+	for _, msg := range turn.Messages {
+		if apiErr, ok := msg.(*APIErrorMessage); ok {
+			return true, strings.TrimSpace(string(apiErr.Subtype) + " " + string(apiErr.Error))
+		}
+	}
+	return false, strings.Join(turn.Result.Errors, "; ")
+}
+
+// WithRetry retries turns that fail on transient API errors, re-sending
+// the prompt on the same session. Without it a failed turn is returned
+// as is.
+func (c *Chat) WithRetry(policy RetryPolicy) *Chat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	policy = policy.withDefaults()
+	c.retry = &policy
+	return c
+}
+
+// sendWithRetry runs the turn until it succeeds, fails fatally or the
+// policy gives up. The returned turn carries the cost of every attempt.
+func (c *Chat) sendWithRetry(ctx context.Context, text string) (*TurnResult, error) {
+	var spent float64
+	for attempt := 1; ; attempt++ {
+		turn, err := c.send(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		spent += turn.CostUSD
+		turn.CostUSD = spent
+		turn.Attempts = attempt
+		if c.retry == nil || attempt >= c.retry.MaxAttempts {
+			return turn, nil
+		}
+		if c.retry.MaxCostUSD > 0 && spent >= c.retry.MaxCostUSD {
+			return turn, nil
+		}
+		retryable, reason := c.retry.Classify(turn)
+		if !retryable {
+			return turn, nil
+		}
+		delay := c.retry.backoff(attempt)
+		if c.retry.OnRetry != nil {
+			c.retry.OnRetry(RetryAttempt{Attempt: attempt, SessionID: turn.SessionID, Reason: reason, Delay: delay, Turn: turn, SpentUSD: spent})
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
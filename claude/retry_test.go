@@ -0,0 +1,148 @@
+package claude
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// retryScript fails the first $FAILS turns with an overloaded API error,
+// or with $SUBTYPE when set, and succeeds afterwards. Each turn costs 0.1.
+const retryScript = `n=0
+while read -r line; do
+  n=$((n+1))
+  if [ $n = 1 ]; then echo '{"type":"system","subtype":"init","session_id":"s1"}'; fi
+  if [ $n -le "${FAILS:-0}" ]; then
+    echo '{"type":"system","subtype":"api_retry","session_id":"s1","error":{"type":"overloaded_error"},"retryAttempt":10,"maxRetries":10}'
+    echo "{\"type\":\"result\",\"subtype\":\"${SUBTYPE:-error_during_execution}\",\"is_error\":true,\"session_id\":\"s1\",\"errors\":[\"API Error: 529 Overloaded\"],\"total_cost_usd\":0.$n}"
+  else
+    echo "{\"type\":\"result\",\"subtype\":\"success\",\"session_id\":\"s1\",\"result\":\"done\",\"total_cost_usd\":0.$n}"
+  fi
+done
+`
+
+func newRetryChat(t *testing.T, policy RetryPolicy, env ...string) (*Chat, *fakeCLI) {
+	t.Helper()
+	fake := newFakeCLI(retryScript)
+	b := fake.builder()
+	for i := 0; i+1 < len(env); i += 2 {
+		b.WithEnv(env[i], env[i+1])
+	}
+	chat := NewChat(b).WithRetry(policy)
+	t.Cleanup(func() { _ = chat.Close() })
+	return chat, fake
+}
+
+func TestChatRetriesTransientErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var attempts []RetryAttempt
+	chat, fake := newRetryChat(t, RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: time.Millisecond,
+		Jitter:         -1,
+		OnRetry:        func(a RetryAttempt) { attempts = append(attempts, a) },
+	}, "FAILS", "2")
+
+	turn, err := chat.Send(ctx, "hello")
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if turn.Result.IsError || turn.Text() != "done" || turn.Attempts != 3 {
+		t.Fatalf("turn = %+v, want success on attempt 3", turn)
+	}
+	if math.Abs(turn.CostUSD-0.3) > 1e-9 {
+		t.Fatalf("CostUSD = %v, want all three attempts (0.3)", turn.CostUSD)
+	}
+	if len(attempts) != 2 || attempts[0].Attempt != 1 || attempts[1].Attempt != 2 {
+		t.Fatalf("OnRetry attempts = %+v, want 1 and 2", attempts)
+	}
+	if attempts[0].Delay != time.Millisecond || attempts[1].Delay != 2*time.Millisecond {
+		t.Fatalf("delays = %v, %v, want 1ms, 2ms", attempts[0].Delay, attempts[1].Delay)
+	}
+	if !strings.Contains(attempts[0].Reason, "529") || attempts[0].SessionID != "s1" {
+		t.Fatalf("attempt = %+v, want 529 reason on s1", attempts[0])
+	}
+	if fake.launchCount() != 1 || len(chat.History()) != 1 {
+		t.Fatalf("launches = %d, history = %d, want 1 and 1", fake.launchCount(), len(chat.History()))
+	}
+}
+
+func TestChatRetryLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		env          []string
+		wantAttempts int
+	}{
+		{"max attempts", RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}, []string{"FAILS", "5"}, 2},
+		{"max cost", RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxCostUSD: 0.15}, []string{"FAILS", "5"}, 2},
+		{"fatal subtype", RetryPolicy{InitialBackoff: time.Millisecond}, []string{"FAILS", "5", "SUBTYPE", "error_max_turns"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat, _ := newRetryChat(t, tt.policy, tt.env...)
+			turn, err := chat.Send(ctx, "hello")
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if !turn.Result.IsError || turn.Attempts != tt.wantAttempts {
+				t.Fatalf("turn attempts = %d (error %v), want %d failed", turn.Attempts, turn.Result.IsError, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestClassifyTurn(t *testing.T) {
+	result := func(subtype string, errs ...string) *TurnResult {
+		return &TurnResult{Result: &ResultMessage{Subtype: subtype, IsError: subtype != "success", Errors: errs}}
+	}
+	tests := []struct {
+		name string
+		turn *TurnResult
+		want bool
+	}{
+		{"success", result("success"), false},
+		{"overloaded", result("error_during_execution", "API Error: 529 {\"type\":\"overloaded_error\"}"), true},
+		{"rate limit", result("error_during_execution", `API Error: 429 {"type":"error","error":{"type":"rate_limit_error"}}`), true},
+		{"request timeout", result("error_during_execution", "API Error: Request timed out."), true},
+		{"mcp failure", result("error_during_execution", "Failed to connect to MCP server: connection refused"), false},
+		{"number in text", result("error_during_execution", "exit status 1: wrote 512 bytes, expected 429"), false},
+		{"tool timeout", result("error_during_execution", "Bash command timed out after 120000ms"), false},
+		{"max turns", result("error_max_turns", "API Error: 429"), false},
+		{"api retry seen", &TurnResult{Result: &ResultMessage{Subtype: "error_during_execution", IsError: true}, Messages: []Message{&APIErrorMessage{Subtype: SystemSubtypeAPIRetry}}}, true},
+		{"api retry seen, unrelated failure", &TurnResult{Result: &ResultMessage{Subtype: "error_during_execution", IsError: true, Errors: []string{"permission denied"}}, Messages: []Message{&APIErrorMessage{Subtype: SystemSubtypeAPIRetry}}}, false},
+	}
+	for _, tt := range tests {
+		if got, reason := ClassifyTurn(tt.turn); got != tt.want {
+			t.Errorf("%s: ClassifyTurn() = %v (%q), want %v", tt.name, got, reason, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: -1}.withDefaults()
+	var got []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		got = append(got, p.backoff(attempt))
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("backoff = %v, want %v", got, want)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(1); d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("jittered backoff = %v, want within 50%% of 100ms", d)
+		}
+	}
+}
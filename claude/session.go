@@ -0,0 +1,312 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+type SessionMetadata struct {
+	ID           string    `json:"id"`
+	ParentID     string    `json:"parent_id,omitempty"`
+	CWD          string    `json:"cwd,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	TotalCostUSD float64   `json:"total_cost_usd,omitempty"`
+	NumTurns     int       `json:"num_turns,omitempty"`
+}
+
+// SessionStore persists session metadata. Get returns an error matching
+// clerrors.ErrSessionNotFound for unknown ids; List with an empty cwd
+// returns every session.
+type SessionStore interface {
+	Get(ctx context.Context, id string) (*SessionMetadata, error)
+	Put(ctx context.Context, meta SessionMetadata) error
+	List(ctx context.Context, cwd string) ([]SessionMetadata, error)
+}
+
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]SessionMetadata
+}
+
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{sessions: map[string]SessionMetadata{}}
+}
+
+func (s *memorySessionStore) Get(ctx context.Context, id string) (*SessionMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta, ok := s.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", clerrors.ErrSessionNotFound, id)
+	}
+	return &meta, nil
+}
+
+func (s *memorySessionStore) Put(ctx context.Context, meta SessionMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[meta.ID] = meta
+	return nil
+}
+
+func (s *memorySessionStore) List(ctx context.Context, cwd string) ([]SessionMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]SessionMetadata, 0, len(s.sessions))
+	for _, meta := range s.sessions {
+		out = append(out, meta)
+	}
+	return filterSessions(out, cwd), nil
+}
+
+type fileSessionStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileSessionStore keeps one JSON file per session under dir.
+func NewFileSessionStore(dir string) SessionStore {
+	return &fileSessionStore{dir: dir}
+}
+
+func (s *fileSessionStore) Get(ctx context.Context, id string) (*SessionMetadata, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", clerrors.ErrSessionNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read session %s: %w", id, err)
+	}
+	var meta SessionMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("parse session %s: %w", id, err)
+	}
+	return &meta, nil
+}
+
+func (s *fileSessionStore) Put(ctx context.Context, meta SessionMetadata) error {
+	path, err := s.path(meta.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal session %s: %w", meta.ID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("create session dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write session %s: %w", meta.ID, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write session %s: %w", meta.ID, err)
+	}
+	return nil
+}
+
+func (s *fileSessionStore) List(ctx context.Context, cwd string) ([]SessionMetadata, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+
+	var out []SessionMetadata
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		meta, err := s.Get(ctx, strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *meta)
+	}
+	return filterSessions(out, cwd), nil
+}
+
+func (s *fileSessionStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid session id: %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// filterSessions keeps sessions in cwd and orders them most recent first.
+func filterSessions(sessions []SessionMetadata, cwd string) []SessionMetadata {
+	out := sessions[:0]
+	for _, meta := range sessions {
+		if cwd == "" || filepath.Clean(meta.CWD) == filepath.Clean(cwd) {
+			out = append(out, meta)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].UpdatedAt.After(out[j].UpdatedAt)
+	})
+	return out
+}
+
+// SessionManager records sessions seen on clients it starts and launches
+// new clients that resume or fork them.
+type SessionManager struct {
+	store SessionStore
+	now   func() time.Time
+}
+
+func NewSessionManager(store SessionStore) *SessionManager {
+	return &SessionManager{store: store, now: time.Now}
+}
+
+// Start builds a client for a new session and records it once the init
+// message arrives. The builders passed to Start, Resume and Fork are copied,
+// not modified.
+func (m *SessionManager) Start(ctx context.Context, b *ClientBuilder) (*Client, error) {
+	return m.build(ctx, b.clone(), "")
+}
+
+// Resume builds a client continuing sessionID. The stored cwd is used when
+// the builder has none, since the CLI keys sessions by project directory.
+func (m *SessionManager) Resume(ctx context.Context, sessionID string, b *ClientBuilder) (*Client, error) {
+	b = b.clone()
+	if err := m.prepareResume(ctx, sessionID, b); err != nil {
+		return nil, err
+	}
+	return m.build(ctx, b.WithForkSession(false), "")
+}
+
+// Fork builds a client that branches sessionID into a new session; the new
+// session records sessionID as its parent.
+func (m *SessionManager) Fork(ctx context.Context, sessionID string, b *ClientBuilder) (*Client, error) {
+	b = b.clone()
+	if err := m.prepareResume(ctx, sessionID, b); err != nil {
+		return nil, err
+	}
+	return m.build(ctx, b.WithForkSession(true), sessionID)
+}
+
+func (m *SessionManager) Get(ctx context.Context, sessionID string) (*SessionMetadata, error) {
+	return m.store.Get(ctx, sessionID)
+}
+
+func (m *SessionManager) List(ctx context.Context, cwd string) ([]SessionMetadata, error) {
+	return m.store.List(ctx, cwd)
+}
+
+func (m *SessionManager) prepareResume(ctx context.Context, sessionID string, b *ClientBuilder) error {
+	meta, err := m.store.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	b.WithResume(meta.ID).WithContinue(false)
+	if b.cwd == "" && meta.CWD != "" {
+		b.WithCwd(meta.CWD)
+	}
+	return nil
+}
+
+// build registers the recorder before Build, so it sees an init message
+// read by WithMCPPreflight and runs ahead of the budget enforcer.
+func (m *SessionManager) build(ctx context.Context, b *ClientBuilder, parentID string) (*Client, error) {
+	recorder := &sessionRecorder{manager: m, parentID: parentID}
+	return b.WithObserver(recorder.observe).Build(ctx)
+}
+
+// sessionRecorder tracks one client. The CLI reports total_cost_usd and
+// num_turns cumulatively per process, so only the delta since the previous
+// result is added to the stored totals.
+type sessionRecorder struct {
+	manager  *SessionManager
+	parentID string
+
+	mu        sync.Mutex
+	sessionID string
+	lastCost  float64
+	lastTurns int
+}
+
+func (r *sessionRecorder) observe(ctx context.Context, msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *SystemInitMessage:
+		if m.SessionID == "" {
+			return nil
+		}
+		r.sessionID = m.SessionID
+		return r.update(ctx, func(meta *SessionMetadata) {
+			if m.CWD != "" {
+				meta.CWD = m.CWD
+			}
+			if m.Model != "" {
+				meta.Model = m.Model
+			}
+			if meta.ParentID == "" && r.parentID != m.SessionID {
+				meta.ParentID = r.parentID
+			}
+		})
+	case *ResultMessage:
+		if m.SessionID != "" {
+			r.sessionID = m.SessionID
+		}
+		if r.sessionID == "" {
+			return nil
+		}
+		delta := m.TotalCostUSD - r.lastCost
+		if delta < 0 {
+			delta = m.TotalCostUSD
+		}
+		turns := m.NumTurns - r.lastTurns
+		if turns < 0 {
+			turns = m.NumTurns
+		}
+		r.lastCost = m.TotalCostUSD
+		r.lastTurns = m.NumTurns
+		return r.update(ctx, func(meta *SessionMetadata) {
+			meta.TotalCostUSD += delta
+			meta.NumTurns += turns
+		})
+	}
+	return nil
+}
+
+func (r *sessionRecorder) update(ctx context.Context, apply func(*SessionMetadata)) error {
+	store := r.manager.store
+	now := r.manager.now()
+
+	meta, err := store.Get(ctx, r.sessionID)
+	if errors.Is(err, clerrors.ErrSessionNotFound) {
+		meta = &SessionMetadata{ID: r.sessionID, CreatedAt: now}
+	} else if err != nil {
+		return fmt.Errorf("record session: %w", err)
+	}
+	apply(meta)
+	meta.UpdatedAt = now
+	if err := store.Put(ctx, *meta); err != nil {
+		return fmt.Errorf("record session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+func sessionScript(sessionID, cwd string, cost float64) string {
+	return fmt.Sprintf(`cat >/dev/null
+echo '{"type":"system","subtype":"init","session_id":"%s","cwd":"%s","model":"claude-sonnet-4-5"}'
+echo '{"type":"result","subtype":"success","session_id":"%s","total_cost_usd":%g,"num_turns":1}'
+`, sessionID, cwd, sessionID, cost)
+}
+
+func drainClient(t *testing.T, client *Client) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := client.SendUserInput(ctx, UserInput{Prompt: "hi"}); err != nil {
+		t.Fatalf("SendUserInput() error = %v", err)
+	}
+	_ = client.stdin.Close()
+	for {
+		_, err := client.NextMessage(ctx)
+		if clerrors.IsEOF(err) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextMessage() error = %v", err)
+		}
+	}
+	_ = client.Close()
+}
+
+func TestSessionManagerStartResumeFork(t *testing.T) {
+	ctx := context.Background()
+	cwd := t.TempDir()
+	manager := NewSessionManager(NewMemorySessionStore())
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	manager.now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+
+	first := newFakeCLI(sessionScript("s1", cwd, 0.5))
+	client, err := manager.Start(ctx, first.builder().WithCwd(cwd))
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	drainClient(t, client)
+
+	meta, err := manager.Get(ctx, "s1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if meta.CWD != cwd || meta.Model != "claude-sonnet-4-5" || meta.TotalCostUSD != 0.5 || meta.NumTurns != 1 {
+		t.Fatalf("meta = %+v, want cwd/model/cost recorded", meta)
+	}
+	created := meta.CreatedAt
+
+	resumed := newFakeCLI(sessionScript("s1", cwd, 0.25))
+	client, err = manager.Resume(ctx, "s1", resumed.builder())
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	drainClient(t, client)
+	if got := argValue(resumed.lastArgs(), "--resume"); got != "s1" {
+		t.Fatalf("--resume = %q, want s1 (args %v)", got, resumed.lastArgs())
+	}
+	if containsArg(resumed.lastArgs(), "--fork-session") {
+		t.Fatalf("args = %v, resume should not fork", resumed.lastArgs())
+	}
+	meta, _ = manager.Get(ctx, "s1")
+	if math.Abs(meta.TotalCostUSD-0.75) > 1e-9 || meta.NumTurns != 2 {
+		t.Fatalf("meta = %+v, want cost 0.75 and 2 turns", meta)
+	}
+	if !meta.CreatedAt.Equal(created) || !meta.UpdatedAt.After(created) {
+		t.Fatalf("meta times = %v/%v, want created kept and updated advanced", meta.CreatedAt, meta.UpdatedAt)
+	}
+
+	forked := newFakeCLI(sessionScript("s2", cwd, 0.1))
+	client, err = manager.Fork(ctx, "s1", forked.builder())
+	if err != nil {
+		t.Fatalf("Fork() error = %v", err)
+	}
+	drainClient(t, client)
+	if !containsArg(forked.lastArgs(), "--fork-session") || argValue(forked.lastArgs(), "--resume") != "s1" {
+		t.Fatalf("args = %v, want --resume s1 --fork-session", forked.lastArgs())
+	}
+	child, err := manager.Get(ctx, "s2")
+	if err != nil {
+		t.Fatalf("Get(s2) error = %v", err)
+	}
+	if child.ParentID != "s1" {
+		t.Fatalf("ParentID = %q, want s1", child.ParentID)
+	}
+
+	sessions, err := manager.List(ctx, cwd)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	var ids []string
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"s2", "s1"}) {
+		t.Fatalf("List() ids = %v, want [s2 s1]", ids)
+	}
+	if other, _ := manager.List(ctx, t.TempDir()); len(other) != 0 {
+		t.Fatalf("List(other cwd) = %+v, want empty", other)
+	}
+
+	if _, err := manager.Resume(ctx, "missing", NewClientBuilder()); !errors.Is(err, clerrors.ErrSessionNotFound) {
+		t.Fatalf("Resume(missing) error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestFileSessionStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileSessionStore(t.TempDir())
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if sessions, err := store.List(ctx, ""); err != nil || len(sessions) != 0 {
+		t.Fatalf("List() = %v, %v, want empty", sessions, err)
+	}
+	for i, id := range []string{"a", "b"} {
+		meta := SessionMetadata{ID: id, CWD: "/work", CreatedAt: now, UpdatedAt: now.Add(time.Duration(i) * time.Hour), TotalCostUSD: 0.1}
+		if err := store.Put(ctx, meta); err != nil {
+			t.Fatalf("Put(%s) error = %v", id, err)
+		}
+	}
+	if err := store.Put(ctx, SessionMetadata{ID: "c", CWD: "/elsewhere", UpdatedAt: now}); err != nil {
+		t.Fatalf("Put(c) error = %v", err)
+	}
+
+	got, err := store.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.CWD != "/work" || !got.CreatedAt.Equal(now) || got.TotalCostUSD != 0.1 {
+		t.Fatalf("Get() = %+v, want stored metadata", got)
+	}
+
+	sessions, err := store.List(ctx, "/work/")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "b" || sessions[1].ID != "a" {
+		t.Fatalf("List() = %+v, want b then a", sessions)
+	}
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, clerrors.ErrSessionNotFound) {
+		t.Fatalf("Get(missing) error = %v, want ErrSessionNotFound", err)
+	}
+	if err := store.Put(ctx, SessionMetadata{ID: "../escape"}); err == nil {
+		t.Fatalf("Put(../escape) error = nil, want error")
+	}
+}
+
+func TestSessionManagerRecordsPreflightInit(t *testing.T) {
+	ctx := context.Background()
+	cwd := t.TempDir()
+	manager := NewSessionManager(NewMemorySessionStore())
+
+	client, err := manager.Start(ctx, newFakeCLI(sessionScript("s1", cwd, 0.5)).builder().WithMCPPreflight(UserInput{Prompt: "hi"}))
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer client.Close()
+	meta, err := manager.Get(ctx, "s1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if meta.CWD != cwd || meta.Model != "claude-sonnet-4-5" {
+		t.Fatalf("meta = %+v, want cwd and model from the preflight init", meta)
+	}
+
+	b := newFakeCLI(sessionScript("s1", cwd, 0.25)).builder()
+	resumed, err := manager.Resume(ctx, "s1", b)
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	_ = resumed.Close()
+	if b.resumeSessionID != "" || b.cwd != "" || len(b.observers) != 0 {
+		t.Fatalf("Resume() modified the caller's builder: resume=%q cwd=%q observers=%d", b.resumeSessionID, b.cwd, len(b.observers))
+	}
+}
@@ -0,0 +1,214 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SettingSource selects a settings file the CLI loads: user
+// (~/.claude/settings.json), project (.claude/settings.json) or local
+// (.claude/settings.local.json).
+type SettingSource string
+
+const (
+	SettingSourceUser    SettingSource = "user"
+	SettingSourceProject SettingSource = "project"
+	SettingSourceLocal   SettingSource = "local"
+)
+
+func (s SettingSource) Validate() error {
+	switch s {
+	case SettingSourceUser, SettingSourceProject, SettingSourceLocal:
+		return nil
+	default:
+		return fmt.Errorf("unknown setting source %q", s)
+	}
+}
+
+// PermissionRule is a tool permission rule such as "Read", "Bash(git:*)"
+// or "Edit(src/**)". Specifier is the text between the parentheses; empty
+// matches every use of the tool.
+type PermissionRule struct {
+	Tool      string
+	Specifier string
+}
+
+// ParsePermissionRule parses and validates a rule in the CLI's syntax.
+func ParsePermissionRule(s string) (PermissionRule, error) {
+	s = strings.TrimSpace(s)
+	tool, rest, hasSpec := strings.Cut(s, "(")
+	rule := PermissionRule{Tool: tool}
+	if hasSpec {
+		spec, ok := strings.CutSuffix(rest, ")")
+		if !ok {
+			return PermissionRule{}, fmt.Errorf("permission rule %q: missing closing parenthesis", s)
+		}
+		if spec == "" {
+			return PermissionRule{}, fmt.Errorf("permission rule %q: empty specifier", s)
+		}
+		rule.Specifier = spec
+	}
+	if err := rule.Validate(); err != nil {
+		return PermissionRule{}, err
+	}
+	return rule, nil
+}
+
+// MustParsePermissionRule is ParsePermissionRule for rules known at compile
+// time; it panics on invalid input.
+func MustParsePermissionRule(s string) PermissionRule {
+	rule, err := ParsePermissionRule(s)
+	if err != nil {
+		panic(err)
+	}
+	return rule
+}
+
+func (r PermissionRule) String() string {
+	if r.Specifier == "" {
+		return r.Tool
+	}
+	return r.Tool + "(" + r.Specifier + ")"
+}
+
+// Validate checks the tool name like AgentDefinition.Validate does. Bash
+// specifiers may only use the prefix wildcard ":*" at the end, and MCP
+// rules take no specifier. Commas are rejected because rules are passed to
+// the CLI as a comma-separated list.
+func (r PermissionRule) Validate() error {
+	if err := validateToolName(r.Tool); err != nil {
+		return fmt.Errorf("permission rule %q: %w", r.String(), err)
+	}
+	if r.Specifier == "" {
+		return nil
+	}
+	if strings.HasPrefix(r.Tool, "mcp__") {
+		return fmt.Errorf("permission rule %q: mcp rules take no specifier", r.String())
+	}
+	if strings.ContainsAny(r.Specifier, ",()") {
+		return fmt.Errorf("permission rule %q: specifier must not contain commas or parentheses", r.String())
+	}
+	if r.Tool == "Bash" {
+		if i := strings.Index(r.Specifier, ":*"); i >= 0 && i != len(r.Specifier)-2 {
+			return fmt.Errorf("permission rule %q: :* is only allowed at the end", r.String())
+		}
+	}
+	return nil
+}
+
+func (r PermissionRule) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *PermissionRule) UnmarshalText(text []byte) error {
+	rule, err := ParsePermissionRule(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
+
+func ruleStrings(rules []PermissionRule) []string {
+	out := make([]string, len(rules))
+	for i, rule := range rules {
+		out[i] = rule.String()
+	}
+	return out
+}
+
+// PermissionSettings is the "permissions" block of a settings file.
+type PermissionSettings struct {
+	Allow                 []PermissionRule `json:"allow,omitempty"`
+	Deny                  []PermissionRule `json:"deny,omitempty"`
+	Ask                   []PermissionRule `json:"ask,omitempty"`
+	DefaultMode           PermissionMode   `json:"defaultMode,omitempty"`
+	AdditionalDirectories []string         `json:"additionalDirectories,omitempty"`
+}
+
+// Settings is the subset of the CLI settings file modelled here; Extra
+// carries any other top-level keys verbatim.
+type Settings struct {
+	Model       string                     `json:"model,omitempty"`
+	Env         map[string]string          `json:"env,omitempty"`
+	Permissions *PermissionSettings        `json:"permissions,omitempty"`
+	Hooks       json.RawMessage            `json:"hooks,omitempty"`
+	Extra       map[string]json.RawMessage `json:"-"`
+}
+
+func (s Settings) MarshalJSON() ([]byte, error) {
+	type alias Settings
+	data, err := json.Marshal(alias(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Extra) == 0 {
+		return data, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range s.Extra {
+		if _, ok := fields[key]; !ok {
+			fields[key] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func (s *Settings) UnmarshalJSON(data []byte) error {
+	type alias Settings
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, known := range []string{"model", "env", "permissions", "hooks"} {
+		delete(fields, known)
+	}
+	*s = Settings(decoded)
+	if len(fields) > 0 {
+		s.Extra = fields
+	}
+	return nil
+}
+
+func (s Settings) Validate() error {
+	if s.Permissions == nil {
+		return nil
+	}
+	var errs []error
+	if mode := s.Permissions.DefaultMode; mode != "" {
+		if err := mode.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, list := range [][]PermissionRule{s.Permissions.Allow, s.Permissions.Deny, s.Permissions.Ask} {
+		for _, rule := range list {
+			if err := rule.Validate(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LoadSettings reads a settings file.
+func LoadSettings(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read settings: %w", err)
+	}
+	var s Settings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse settings %s: %w", path, err)
+	}
+	return &s, nil
+}
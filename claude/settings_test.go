@@ -0,0 +1,150 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePermissionRule(t *testing.T) {
+	valid := map[string]PermissionRule{
+		"Read":                    {Tool: "Read"},
+		"Bash(git:*)":             {Tool: "Bash", Specifier: "git:*"},
+		"Bash(npm run test)":      {Tool: "Bash", Specifier: "npm run test"},
+		"Edit(src/**)":            {Tool: "Edit", Specifier: "src/**"},
+		"WebFetch(domain:go.dev)": {Tool: "WebFetch", Specifier: "domain:go.dev"},
+		"mcp__github__get_pr":     {Tool: "mcp__github__get_pr"},
+	}
+	for input, want := range valid {
+		got, err := ParsePermissionRule(input)
+		if err != nil {
+			t.Fatalf("ParsePermissionRule(%q) error = %v", input, err)
+		}
+		if got != want || got.String() != input {
+			t.Fatalf("ParsePermissionRule(%q) = %#v (%s), want %#v", input, got, got, want)
+		}
+	}
+
+	invalid := []string{"", "Bsh", "Bash(", "Bash()", "Bash(git:*:x)", "Bash(a,b)", "Edit(src/**", "mcp__github(x)"}
+	for _, input := range invalid {
+		if _, err := ParsePermissionRule(input); err == nil {
+			t.Fatalf("ParsePermissionRule(%q) error = nil", input)
+		}
+	}
+}
+
+func TestSettingsJSON(t *testing.T) {
+	input := `{"model":"opus","permissions":{"allow":["Bash(git:*)"],"deny":["Read(.env)"],"defaultMode":"acceptEdits"},"statusLine":{"type":"command"}}`
+	var s Settings
+	if err := json.Unmarshal([]byte(input), &s); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if s.Permissions.Allow[0] != (PermissionRule{Tool: "Bash", Specifier: "git:*"}) {
+		t.Fatalf("allow = %+v", s.Permissions.Allow)
+	}
+	if string(s.Extra["statusLine"]) != `{"type":"command"}` {
+		t.Fatalf("Extra = %v", s.Extra)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var roundTrip, original map[string]any
+	_ = json.Unmarshal(data, &roundTrip)
+	_ = json.Unmarshal([]byte(input), &original)
+	if !reflect.DeepEqual(roundTrip, original) {
+		t.Fatalf("round trip = %s, want %s", data, input)
+	}
+
+	if err := json.Unmarshal([]byte(`{"permissions":{"allow":["Bash("]}}`), &s); err == nil {
+		t.Fatalf("Unmarshal(invalid rule) error = nil")
+	}
+}
+
+func TestClientBuilderSettingsArgs(t *testing.T) {
+	b := NewClientBuilder().
+		WithModel("opus").
+		WithAllowedTools("Read").
+		WithAllowRules(MustParsePermissionRule("Bash(git:*)")).
+		WithDenyRules(MustParsePermissionRule("Edit(secrets/**)")).
+		WithSettings(Settings{Env: map[string]string{"A": "1"}}).
+		WithSettingSources(SettingSourceUser, SettingSourceProject).
+		WithAddDirs("/data", "/logs").
+		WithFallbackModel("sonnet").
+		WithPermissionPromptTool("mcp__auth__approve").
+		WithMaxThinkingTokens(4096)
+
+	args, err := b.buildArgs()
+	if err != nil {
+		t.Fatalf("buildArgs() error = %v", err)
+	}
+	want := map[string]string{
+		"--allowed-tools":          "Read,Bash(git:*)",
+		"--disallowed-tools":       "Edit(secrets/**)",
+		"--settings":               `{"env":{"A":"1"}}`,
+		"--setting-sources":        "user,project",
+		"--fallback-model":         "sonnet",
+		"--permission-prompt-tool": "mcp__auth__approve",
+		"--max-thinking-tokens":    "4096",
+	}
+	for flag, value := range want {
+		if got := argValue(args, flag); got != value {
+			t.Fatalf("%s = %q, want %q (args %v)", flag, got, value, args)
+		}
+	}
+	if !strings.Contains(strings.Join(args, " "), "--add-dir /data --add-dir /logs") {
+		t.Fatalf("args = %v, want repeated --add-dir", args)
+	}
+
+	if args, _ := NewClientBuilder().WithSettingSources().buildArgs(); argValue(args, "--setting-sources") != "" || !containsArg(args, "--setting-sources") {
+		t.Fatalf("args = %v, want empty --setting-sources", args)
+	}
+}
+
+func TestClientBuilderSettingsValidation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	_, err := NewClientBuilder().
+		WithModel("opus").
+		WithSettings(Settings{}).
+		WithSettingsFile(path).
+		WithSettingSources("global").
+		WithFallbackModel("opus").
+		WithPermissionPromptTool("approve").
+		WithAllowRules(PermissionRule{Tool: "Bash", Specifier: "a:*b"}).
+		Build(context.Background())
+	if err == nil {
+		t.Fatalf("Build() error = nil")
+	}
+	for _, want := range []string{"mutually exclusive", `setting source "global"`, "fallback model", "permission prompt tool", `"Bash(a:*b)"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("Build() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestClientBuilderValidatesReadWriterPath(t *testing.T) {
+	builders := map[string]*ClientBuilder{
+		"agents":          NewClientBuilder().WithAgents(map[string]AgentDefinition{"reviewer": {}}),
+		"settings":        NewClientBuilder().WithSettingSources("global"),
+		"permission mode": NewClientBuilder().WithPermissionMode("sometimes"),
+		"can use tool": NewClientBuilder().WithCanUseTool(func(ctx context.Context, req ToolPermissionRequest) (PermissionResult, error) {
+			return PermissionResult{Decision: PermissionDecisionAllow}, nil
+		}),
+	}
+	for name, b := range builders {
+		_, err := b.WithReader(strings.NewReader("")).WithWriter(&strings.Builder{}).Build(context.Background())
+		if err == nil {
+			t.Errorf("%s: Build() error = nil, want validation error", name)
+		}
+	}
+}
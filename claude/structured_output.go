@@ -0,0 +1,80 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+)
+
+// StructuredOutputError is returned by QueryTyped when the result carries
+// no structured output or it does not match the schema or T. It matches
+// clerrors.ErrStructuredOutput and the underlying error, which is a
+// *jsonschema.ValidationError for schema mismatches.
+type StructuredOutputError struct {
+	Result *ResultMessage
+	Output json.RawMessage
+	Err    error
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("structured output: %v", e.Err)
+}
+
+func (e *StructuredOutputError) Unwrap() []error {
+	return []error{clerrors.ErrStructuredOutput, e.Err}
+}
+
+// QueryTyped sends prompt as the client's only input, closes input, and
+// decodes the result's structured output into T after validating it
+// against the schema passed to WithJSONSchema. The CLI only produces
+// structured output when given --json-schema, so clients built without
+// WithJSONSchema are rejected before anything is sent; jsonschema.For[T]
+// derives a schema from T.
+func QueryTyped[T any](ctx context.Context, client *Client, prompt string) (T, *ResultMessage, error) {
+	var zero T
+
+	schema := client.jsonSchema
+	if schema == nil {
+		return zero, nil, fmt.Errorf("structured output: client was built without WithJSONSchema")
+	}
+
+	if err := client.SendUserInput(ctx, UserInput{Type: UserInputTypePrompt, Prompt: prompt}); err != nil {
+		return zero, nil, err
+	}
+	if err := client.CloseInput(); err != nil {
+		return zero, nil, err
+	}
+
+	var result *ResultMessage
+	for result == nil {
+		msg, err := client.NextMessage(ctx)
+		if err != nil {
+			if clerrors.IsEOF(err) {
+				return zero, nil, fmt.Errorf("structured output: stream ended without a result: %w", err)
+			}
+			return zero, nil, err
+		}
+		if m, ok := msg.(*ResultMessage); ok {
+			result = m
+		}
+	}
+	if result.IsError {
+		return zero, result, fmt.Errorf("result %s: %s", result.Subtype, strings.Join(result.Errors, "; "))
+	}
+
+	output := result.StructuredOutput
+	if len(output) == 0 || string(output) == "null" {
+		return zero, result, &StructuredOutputError{Result: result, Err: fmt.Errorf("result has no structured_output")}
+	}
+	if err := schema.Validate(output); err != nil {
+		return zero, result, &StructuredOutputError{Result: result, Output: output, Err: err}
+	}
+	var value T
+	if err := json.Unmarshal(output, &value); err != nil {
+		return zero, result, &StructuredOutputError{Result: result, Output: output, Err: err}
+	}
+	return value, result, nil
+}
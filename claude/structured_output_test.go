@@ -0,0 +1,103 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	clerrors "github.com/flaneur2020/agentkit-go/claude/errors"
+	"github.com/flaneur2020/agentkit-go/claude/jsonschema"
+)
+
+type weatherReport struct {
+	City    string  `json:"city"`
+	TempC   float64 `json:"temp_c"`
+	Summary string  `json:"summary,omitempty"`
+}
+
+// structuredScript emits output as structured_output only when launched
+// with --json-schema, as the CLI does.
+func structuredScript(output string) string {
+	return `cat >/dev/null
+output=null
+case " $* " in *" --json-schema "*) output='` + output + `' ;; esac
+echo '{"type":"system","subtype":"init","session_id":"s1"}'
+echo '{"type":"result","subtype":"success","session_id":"s1","result":"","structured_output":'"$output"'}'
+`
+}
+
+func TestQueryTyped(t *testing.T) {
+	ctx := context.Background()
+	schema, err := jsonschema.For[weatherReport]()
+	if err != nil {
+		t.Fatalf("For() error = %v", err)
+	}
+
+	fake := newFakeCLI(structuredScript(`{"city":"Oslo","temp_c":-3.5}`))
+	client, err := fake.builder().WithJSONSchema(schema).Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	report, result, err := QueryTyped[weatherReport](ctx, client, "weather in Oslo")
+	if err != nil {
+		t.Fatalf("QueryTyped() error = %v", err)
+	}
+	if report.City != "Oslo" || report.TempC != -3.5 || result == nil || result.SessionID != "s1" {
+		t.Fatalf("QueryTyped() = %+v, %+v", report, result)
+	}
+	want := `{"additionalProperties":false,"properties":{"city":{"type":"string"},"summary":{"type":"string"},"temp_c":{"type":"number"}},"required":["city","temp_c"],"type":"object"}`
+	if got := argValue(fake.lastArgs(), "--json-schema"); got != want {
+		t.Fatalf("--json-schema = %s, want %s", got, want)
+	}
+}
+
+func TestQueryTypedMismatch(t *testing.T) {
+	ctx := context.Background()
+	schema, err := jsonschema.For[weatherReport]()
+	if err != nil {
+		t.Fatalf("For() error = %v", err)
+	}
+	for _, output := range []string{`{"city":"Oslo"}`, `null`} {
+		client, err := newFakeCLI(structuredScript(output)).builder().WithJSONSchema(schema).Build(ctx)
+		if err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+
+		_, result, err := QueryTyped[weatherReport](ctx, client, "weather")
+		var outErr *StructuredOutputError
+		if !errors.As(err, &outErr) || !errors.Is(err, clerrors.ErrStructuredOutput) {
+			t.Fatalf("QueryTyped(%s) error = %v, want StructuredOutputError", output, err)
+		}
+		if result == nil || outErr.Result != result {
+			t.Fatalf("result = %v, want the result message", result)
+		}
+		var vErr *jsonschema.ValidationError
+		if output != "null" && !errors.As(err, &vErr) {
+			t.Fatalf("error = %v, want wrapped ValidationError", err)
+		}
+		_ = client.Close()
+	}
+}
+
+func TestQueryTypedRequiresSchema(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	client, err := NewClientBuilder().
+		WithReader(strings.NewReader("")).
+		WithWriter(&out).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if _, _, err := QueryTyped[weatherReport](ctx, client, "weather"); err == nil {
+		t.Fatalf("QueryTyped() error = nil, want missing schema error")
+	}
+	if out.Len() != 0 {
+		t.Fatalf("written = %q, want nothing sent", out.String())
+	}
+}
@@ -0,0 +1,325 @@
+// Package transcript reads the session transcripts the claude CLI writes
+// under ~/.claude/projects/<encoded cwd>/<session id>.jsonl and rebuilds
+// the message tree from their uuid/parentUuid links.
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+)
+
+// DefaultRoot returns the directory holding per-project transcript folders,
+// honouring CLAUDE_CONFIG_DIR like the CLI does.
+func DefaultRoot() (string, error) {
+	if dir := os.Getenv("CLAUDE_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "projects"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate home dir: %w", err)
+	}
+	return filepath.Join(home, ".claude", "projects"), nil
+}
+
+// EncodeProjectPath maps a working directory to its transcript folder name:
+// every character other than an ASCII letter or digit becomes '-'.
+func EncodeProjectPath(cwd string) string {
+	var b strings.Builder
+	for _, r := range cwd {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+func ProjectDir(root, cwd string) string {
+	return filepath.Join(root, EncodeProjectPath(filepath.Clean(cwd)))
+}
+
+// Files lists the transcript files for cwd, oldest first.
+func Files(root, cwd string) ([]string, error) {
+	dir := ProjectDir(root, cwd)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list transcripts: %w", err)
+	}
+
+	type file struct {
+		path    string
+		modTime time.Time
+	}
+	var files []file
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".jsonl" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("stat transcript: %w", err)
+		}
+		files = append(files, file{path: filepath.Join(dir, entry.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].modTime.Equal(files[j].modTime) {
+			return files[i].path < files[j].path
+		}
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	out := make([]string, len(files))
+	for i, f := range files {
+		out[i] = f.path
+	}
+	return out, nil
+}
+
+// SessionFile returns the transcript path of one session.
+func SessionFile(root, cwd, sessionID string) string {
+	return filepath.Join(ProjectDir(root, cwd), sessionID+".jsonl")
+}
+
+// Entry is one transcript line. Message is parsed with claude's
+// MessageParser, so lines of unknown or unparseable shape are kept as
+// *claude.UnknownMessage. Lines that are not JSON at all, such as a
+// trailing line cut short by a crash, have no entry and are reported in
+// Transcript.Errors instead.
+type Entry struct {
+	Type       claude.MessageType
+	UUID       string
+	ParentUUID string
+	SessionID  string
+	// Timestamp is zero when RawTimestamp is missing or not RFC 3339.
+	Timestamp    time.Time
+	RawTimestamp string
+	IsSidechain  bool
+	CWD          string
+	Version      string
+	GitBranch    string
+	Message      claude.Message
+}
+
+type entryEnvelope struct {
+	Type        claude.MessageType `json:"type"`
+	UUID        string             `json:"uuid"`
+	ParentUUID  *string            `json:"parentUuid"`
+	SessionID   string             `json:"sessionId"`
+	Timestamp   string             `json:"timestamp"`
+	IsSidechain bool               `json:"isSidechain"`
+	CWD         string             `json:"cwd"`
+	Version     string             `json:"version"`
+	GitBranch   string             `json:"gitBranch"`
+	Summary     string             `json:"summary"`
+	LeafUUID    string             `json:"leafUuid"`
+}
+
+// Summary is a "summary" line the CLI writes to label a conversation leaf.
+type Summary struct {
+	Text     string
+	LeafUUID string
+}
+
+// LineError records a transcript line Parse skipped.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+type Transcript struct {
+	Entries   []*Entry
+	Summaries []Summary
+	// Errors lists the lines skipped because they could not be parsed.
+	Errors []*LineError
+	byUUID map[string]*Entry
+}
+
+func ReadFile(path string) (*Transcript, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open transcript: %w", err)
+	}
+	defer f.Close()
+	t, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Parse reads a transcript. Lines that cannot be parsed are skipped and
+// recorded in Errors; only read failures are returned.
+func Parse(r io.Reader) (*Transcript, error) {
+	parser := claude.NewMessageParser(strings.NewReader(""))
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	t := &Transcript{byUUID: map[string]*Entry{}}
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var env entryEnvelope
+		if err := json.Unmarshal(line, &env); err != nil {
+			t.Errors = append(t.Errors, &LineError{Line: lineNo, Err: fmt.Errorf("parse transcript entry: %w", err)})
+			continue
+		}
+		if env.Type == "summary" {
+			t.Summaries = append(t.Summaries, Summary{Text: env.Summary, LeafUUID: env.LeafUUID})
+			continue
+		}
+
+		msg, err := parser.ParseLine(line)
+		if err != nil {
+			t.Errors = append(t.Errors, &LineError{Line: lineNo, Err: err})
+			continue
+		}
+		entry := &Entry{
+			Type:         env.Type,
+			UUID:         env.UUID,
+			SessionID:    env.SessionID,
+			IsSidechain:  env.IsSidechain,
+			CWD:          env.CWD,
+			Version:      env.Version,
+			GitBranch:    env.GitBranch,
+			Message:      msg,
+			RawTimestamp: env.Timestamp,
+		}
+		if env.ParentUUID != nil {
+			entry.ParentUUID = *env.ParentUUID
+		}
+		if ts, err := time.Parse(time.RFC3339Nano, env.Timestamp); err == nil {
+			entry.Timestamp = ts
+		}
+
+		t.Entries = append(t.Entries, entry)
+		if entry.UUID != "" {
+			t.byUUID[entry.UUID] = entry
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+	return t, nil
+}
+
+func (t *Transcript) Entry(uuid string) (*Entry, bool) {
+	e, ok := t.byUUID[uuid]
+	return e, ok
+}
+
+// Node is an entry with its children in the conversation tree.
+type Node struct {
+	Entry    *Entry
+	Parent   *Node
+	Children []*Node
+}
+
+// Tree links entries by parentUuid and returns the roots in file order.
+// Entries whose parent is missing from the file (for example after the CLI
+// compacted or truncated it) become roots.
+func (t *Transcript) Tree() []*Node {
+	nodes := make(map[string]*Node, len(t.Entries))
+	all := make([]*Node, len(t.Entries))
+	for i, e := range t.Entries {
+		n := &Node{Entry: e}
+		all[i] = n
+		if e.UUID != "" {
+			nodes[e.UUID] = n
+		}
+	}
+
+	var roots []*Node
+	for _, n := range all {
+		parent, ok := nodes[n.Entry.ParentUUID]
+		if n.Entry.ParentUUID == "" || !ok || parent == n {
+			roots = append(roots, n)
+			continue
+		}
+		n.Parent = parent
+		parent.Children = append(parent.Children, n)
+	}
+	return roots
+}
+
+// Walk visits n and its descendants depth first.
+func (n *Node) Walk(fn func(n *Node, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *Node) walk(fn func(n *Node, depth int), depth int) {
+	fn(n, depth)
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// Thread returns the entries from the root down to leafUUID.
+func (t *Transcript) Thread(leafUUID string) ([]*Entry, error) {
+	var out []*Entry
+	seen := map[string]bool{}
+	for uuid := leafUUID; uuid != ""; {
+		if seen[uuid] {
+			return nil, fmt.Errorf("transcript cycle at %s", uuid)
+		}
+		seen[uuid] = true
+		e, ok := t.byUUID[uuid]
+		if !ok {
+			if uuid == leafUUID {
+				return nil, fmt.Errorf("transcript entry %s not found", leafUUID)
+			}
+			break
+		}
+		out = append(out, e)
+		uuid = e.ParentUUID
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// Leaves returns the uuids of entries no other entry points to, in file
+// order; the last non-sidechain leaf is the conversation's current tip.
+func (t *Transcript) Leaves() []string {
+	hasChild := map[string]bool{}
+	for _, e := range t.Entries {
+		if e.ParentUUID != "" {
+			hasChild[e.ParentUUID] = true
+		}
+	}
+	var out []string
+	for _, e := range t.Entries {
+		if e.UUID != "" && !hasChild[e.UUID] {
+			out = append(out, e.UUID)
+		}
+	}
+	return out
+}
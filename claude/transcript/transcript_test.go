@@ -0,0 +1,187 @@
+package transcript
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flaneur2020/agentkit-go/claude"
+)
+
+const sampleTranscript = `{"type":"summary","summary":"Fix the build","leafUuid":"a2"}
+{"type":"user","uuid":"u1","parentUuid":null,"sessionId":"s1","timestamp":"2025-06-01T10:00:00.000Z","cwd":"/work/app","version":"1.0.0","gitBranch":"main","isSidechain":false,"message":{"role":"user","content":"fix the build"}}
+{"type":"assistant","uuid":"a1","parentUuid":"u1","sessionId":"s1","timestamp":"2025-06-01T10:00:01.000Z","message":{"id":"m1","type":"message","role":"assistant","model":"claude","content":[{"type":"tool_use","id":"t1","name":"Task","input":{}}]}}
+{"type":"user","uuid":"u2","parentUuid":"a1","sessionId":"s1","timestamp":"2025-06-01T10:00:02.000Z","isSidechain":true,"message":{"role":"user","content":"sub task"}}
+{"type":"assistant","uuid":"a2","parentUuid":"a1","sessionId":"s1","timestamp":"2025-06-01T10:00:03.000Z","message":{"id":"m2","type":"message","role":"assistant","model":"claude","content":[{"type":"text","text":"done"}]}}
+`
+
+func TestParse(t *testing.T) {
+	tr, err := Parse(strings.NewReader(sampleTranscript))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(tr.Entries) != 4 {
+		t.Fatalf("entries = %d, want 4", len(tr.Entries))
+	}
+	if want := []Summary{{Text: "Fix the build", LeafUUID: "a2"}}; !reflect.DeepEqual(tr.Summaries, want) {
+		t.Fatalf("summaries = %#v, want %#v", tr.Summaries, want)
+	}
+
+	first := tr.Entries[0]
+	if first.UUID != "u1" || first.ParentUUID != "" || first.SessionID != "s1" || first.CWD != "/work/app" || first.GitBranch != "main" {
+		t.Fatalf("first entry = %#v", first)
+	}
+	if want := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC); !first.Timestamp.Equal(want) {
+		t.Fatalf("timestamp = %v, want %v", first.Timestamp, want)
+	}
+	user, ok := first.Message.(*claude.UserMessage)
+	if !ok {
+		t.Fatalf("message type = %T, want *claude.UserMessage", first.Message)
+	}
+	if len(user.Message.Content) != 1 || user.Message.Content[0].Text == nil || user.Message.Content[0].Text.Text != "fix the build" {
+		t.Fatalf("user content = %#v", user.Message.Content)
+	}
+
+	side, _ := tr.Entry("u2")
+	if side == nil || !side.IsSidechain {
+		t.Fatalf("u2 = %#v, want sidechain entry", side)
+	}
+	if _, ok := tr.Entries[3].Message.(*claude.AssistantMessage); !ok {
+		t.Fatalf("message type = %T, want *claude.AssistantMessage", tr.Entries[3].Message)
+	}
+}
+
+func TestParseInvalidLine(t *testing.T) {
+	tr, err := Parse(strings.NewReader("{\"type\":\"user\"}\nnot json\n{\"type\":\"user\",\"uuid\":\"u3\"}\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(tr.Entries) != 2 || tr.Entries[1].UUID != "u3" {
+		t.Fatalf("entries = %#v, want the lines around the invalid one", tr.Entries)
+	}
+	if len(tr.Errors) != 1 || tr.Errors[0].Line != 2 {
+		t.Fatalf("errors = %v, want line 2", tr.Errors)
+	}
+}
+
+func TestParseTruncatedTrailingLine(t *testing.T) {
+	data := sampleTranscript + `{"type":"assistant","uuid":"a3","parentUuid":"a2","message":{"id":"m3","con`
+	tr, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(tr.Entries) != 4 {
+		t.Fatalf("entries = %d, want 4", len(tr.Entries))
+	}
+	if _, ok := tr.Entry("a3"); ok {
+		t.Fatalf("truncated entry a3 was kept")
+	}
+	if len(tr.Errors) != 1 || tr.Errors[0].Line != 6 || !strings.Contains(tr.Errors[0].Error(), "line 6") {
+		t.Fatalf("errors = %v, want line 6", tr.Errors)
+	}
+}
+
+func TestParseMalformedTimestamp(t *testing.T) {
+	data := `{"type":"user","uuid":"u1","timestamp":"yesterday","message":{"role":"user","content":"hi"}}
+{"type":"user","uuid":"u2","parentUuid":"u1","timestamp":"2025-06-01T10:00:00Z","message":{"role":"user","content":"again"}}
+`
+	tr, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(tr.Entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(tr.Entries))
+	}
+	if first := tr.Entries[0]; !first.Timestamp.IsZero() || first.RawTimestamp != "yesterday" {
+		t.Fatalf("first entry timestamp = %v, raw %q", first.Timestamp, first.RawTimestamp)
+	}
+	if tr.Entries[1].Timestamp.IsZero() {
+		t.Fatalf("second entry timestamp is zero")
+	}
+}
+
+func TestTreeAndThread(t *testing.T) {
+	tr, err := Parse(strings.NewReader(sampleTranscript))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	roots := tr.Tree()
+	if len(roots) != 1 || roots[0].Entry.UUID != "u1" {
+		t.Fatalf("roots = %#v", roots)
+	}
+	var visited []string
+	roots[0].Walk(func(n *Node, depth int) {
+		visited = append(visited, strings.Repeat(">", depth)+n.Entry.UUID)
+	})
+	if want := []string{"u1", ">a1", ">>u2", ">>a2"}; !reflect.DeepEqual(visited, want) {
+		t.Fatalf("walk = %v, want %v", visited, want)
+	}
+
+	if got, want := tr.Leaves(), []string{"u2", "a2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Leaves() = %v, want %v", got, want)
+	}
+
+	thread, err := tr.Thread("a2")
+	if err != nil {
+		t.Fatalf("Thread() error = %v", err)
+	}
+	var ids []string
+	for _, e := range thread {
+		ids = append(ids, e.UUID)
+	}
+	if want := []string{"u1", "a1", "a2"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("Thread() = %v, want %v", ids, want)
+	}
+	if _, err := tr.Thread("missing"); err == nil {
+		t.Fatalf("Thread(missing) error = nil")
+	}
+}
+
+func TestFiles(t *testing.T) {
+	root := t.TempDir()
+	cwd := "/work/my_app.v2"
+	if got, want := EncodeProjectPath(cwd), "-work-my-app-v2"; got != want {
+		t.Fatalf("EncodeProjectPath() = %q, want %q", got, want)
+	}
+
+	dir := ProjectDir(root, cwd)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	older := filepath.Join(dir, "older.jsonl")
+	newer := SessionFile(root, cwd, "newer")
+	for _, path := range []string{newer, older, filepath.Join(dir, "notes.txt")} {
+		if err := os.WriteFile(path, []byte(sampleTranscript), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(older, past, past); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+
+	files, err := Files(root, cwd)
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	if want := []string{older, newer}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("Files() = %v, want %v", files, want)
+	}
+
+	tr, err := ReadFile(files[1])
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if len(tr.Entries) != 4 {
+		t.Fatalf("entries = %d, want 4", len(tr.Entries))
+	}
+
+	files, err = Files(root, "/nowhere")
+	if err != nil || files != nil {
+		t.Fatalf("Files(missing) = %v, %v; want nil, nil", files, err)
+	}
+}
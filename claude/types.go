@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 type MessageType string
@@ -14,6 +15,13 @@ const (
 	MessageTypeUser        MessageType = "user"
 	MessageTypeResult      MessageType = "result"
 	MessageTypeStreamEvent MessageType = "stream_event"
+
+	MessageTypeControlRequest       MessageType = "control_request"
+	MessageTypeControlResponse      MessageType = "control_response"
+	MessageTypeControlCancelRequest MessageType = "control_cancel_request"
+	MessageTypeKeepAlive            MessageType = "keep_alive"
+	MessageTypeToolProgress         MessageType = "tool_progress"
+	MessageTypeAuthStatus           MessageType = "auth_status"
 )
 
 type Message interface {
@@ -37,17 +45,30 @@ type messageEnvelope struct {
 	Type MessageType `json:"type"`
 }
 
-type SystemMessage struct {
+type SystemSubtype string
+
+const (
+	SystemSubtypeInit            SystemSubtype = "init"
+	SystemSubtypeCompactBoundary SystemSubtype = "compact_boundary"
+	SystemSubtypeHookResponse    SystemSubtype = "hook_response"
+	SystemSubtypeStatus          SystemSubtype = "status"
+	SystemSubtypeAPIError        SystemSubtype = "api_error"
+	SystemSubtypeAPIRetry        SystemSubtype = "api_retry"
+)
+
+// SystemInitMessage is the init system message the CLI emits at the start
+// of a session.
+type SystemInitMessage struct {
 	messageRaw
 	Type              MessageType      `json:"type"`
-	Subtype           string           `json:"subtype,omitempty"`
+	Subtype           SystemSubtype    `json:"subtype,omitempty"`
 	UUID              string           `json:"uuid,omitempty"`
 	SessionID         string           `json:"session_id,omitempty"`
 	CWD               string           `json:"cwd,omitempty"`
 	Model             string           `json:"model,omitempty"`
 	Tools             []string         `json:"tools,omitempty"`
 	MCPServers        []MCPServerState `json:"mcp_servers,omitempty"`
-	PermissionMode    string           `json:"permissionMode,omitempty"`
+	PermissionMode    PermissionMode   `json:"permissionMode,omitempty"`
 	APIKeySource      string           `json:"apiKeySource,omitempty"`
 	SlashCommands     []string         `json:"slash_commands,omitempty"`
 	Agents            []string         `json:"agents,omitempty"`
@@ -55,6 +76,33 @@ type SystemMessage struct {
 	OutputStyle       string           `json:"output_style,omitempty"`
 	Skills            []string         `json:"skills,omitempty"`
 	Plugins           []PluginInfo     `json:"plugins,omitempty"`
+	TranscriptFields
+}
+
+func (m *SystemInitMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *SystemInitMessage) UnmarshalJSON(data []byte) error {
+	type alias SystemInitMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = SystemInitMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
+// SystemMessage holds system messages of subtypes without a dedicated type,
+// with Raw() holding the full line.
+type SystemMessage struct {
+	messageRaw
+	Type      MessageType   `json:"type"`
+	Subtype   SystemSubtype `json:"subtype,omitempty"`
+	UUID      string        `json:"uuid,omitempty"`
+	SessionID string        `json:"session_id,omitempty"`
+	TranscriptFields
 }
 
 func (m *SystemMessage) GetType() MessageType {
@@ -72,9 +120,128 @@ func (m *SystemMessage) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// CompactBoundaryMessage marks where the CLI summarised the conversation;
+// messages before it are no longer in context.
+type CompactBoundaryMessage struct {
+	messageRaw
+	Type            MessageType     `json:"type"`
+	Subtype         SystemSubtype   `json:"subtype"`
+	UUID            string          `json:"uuid,omitempty"`
+	SessionID       string          `json:"session_id,omitempty"`
+	CompactMetadata CompactMetadata `json:"compact_metadata"`
+	TranscriptFields
+}
+
+func (m *CompactBoundaryMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *CompactBoundaryMessage) UnmarshalJSON(data []byte) error {
+	type alias CompactBoundaryMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = CompactBoundaryMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
+// HookResponseMessage reports the output of a hook command configured in
+// settings.
+type HookResponseMessage struct {
+	messageRaw
+	Type      MessageType   `json:"type"`
+	Subtype   SystemSubtype `json:"subtype"`
+	UUID      string        `json:"uuid,omitempty"`
+	SessionID string        `json:"session_id,omitempty"`
+	HookName  string        `json:"hook_name,omitempty"`
+	HookEvent string        `json:"hook_event,omitempty"`
+	Stdout    string        `json:"stdout,omitempty"`
+	Stderr    string        `json:"stderr,omitempty"`
+	ExitCode  *int          `json:"exit_code,omitempty"`
+	TranscriptFields
+}
+
+func (m *HookResponseMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *HookResponseMessage) UnmarshalJSON(data []byte) error {
+	type alias HookResponseMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = HookResponseMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
+// StatusMessage reports a change of session state, such as compaction in
+// progress (Status "compacting") or a new permission mode. A null status
+// clears the previous one.
+type StatusMessage struct {
+	messageRaw
+	Type           MessageType    `json:"type"`
+	Subtype        SystemSubtype  `json:"subtype"`
+	UUID           string         `json:"uuid,omitempty"`
+	SessionID      string         `json:"session_id,omitempty"`
+	Status         *string        `json:"status"`
+	PermissionMode PermissionMode `json:"permissionMode,omitempty"`
+	TranscriptFields
+}
+
+func (m *StatusMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *StatusMessage) UnmarshalJSON(data []byte) error {
+	type alias StatusMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = StatusMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
+// APIErrorMessage is emitted for api_error and api_retry notices when an
+// API request fails; RetryInMS and RetryAttempt are set while the CLI is
+// still retrying.
+type APIErrorMessage struct {
+	messageRaw
+	Type         MessageType     `json:"type"`
+	Subtype      SystemSubtype   `json:"subtype"`
+	UUID         string          `json:"uuid,omitempty"`
+	SessionID    string          `json:"session_id,omitempty"`
+	Level        string          `json:"level,omitempty"`
+	Error        json.RawMessage `json:"error,omitempty"`
+	RetryInMS    float64         `json:"retryInMs,omitempty"`
+	RetryAttempt int             `json:"retryAttempt,omitempty"`
+	MaxRetries   int             `json:"maxRetries,omitempty"`
+	TranscriptFields
+}
+
+func (m *APIErrorMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *APIErrorMessage) UnmarshalJSON(data []byte) error {
+	type alias APIErrorMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = APIErrorMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
 type MCPServerState struct {
-	Name   string `json:"name"`
-	Status string `json:"status"`
+	Name   string          `json:"name"`
+	Status MCPServerStatus `json:"status"`
 }
 
 type PluginInfo struct {
@@ -90,6 +257,7 @@ type AssistantMessage struct {
 	ParentToolUseID *string          `json:"parent_tool_use_id"`
 	Message         AssistantPayload `json:"message"`
 	ToolUseResult   *ToolUseResult   `json:"tool_use_result,omitempty"`
+	TranscriptFields
 }
 
 func (m *AssistantMessage) GetType() MessageType {
@@ -129,6 +297,7 @@ type UserMessage struct {
 	ToolUseResult   *ToolUseResult  `json:"tool_use_result,omitempty"`
 	Usage           *Usage          `json:"usage,omitempty"`
 	Metadata        json.RawMessage `json:"metadata,omitempty"`
+	TranscriptFields
 }
 
 func (m *UserMessage) GetType() MessageType {
@@ -146,11 +315,50 @@ func (m *UserMessage) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// TranscriptFields are set on messages read from the CLI's on-disk session
+// transcripts; they are empty on the live stream-json output.
+type TranscriptFields struct {
+	ParentUUID  *string `json:"parentUuid,omitempty"`
+	IsSidechain bool    `json:"isSidechain,omitempty"`
+	Timestamp   string  `json:"timestamp,omitempty"`
+}
+
 type UserPayload struct {
 	Role    string         `json:"role,omitempty"`
 	Content []ContentBlock `json:"content,omitempty"`
 }
 
+// UnmarshalJSON also accepts plain string content, which transcripts use
+// for prompts typed by the user, as a single text block.
+func (p *UserPayload) UnmarshalJSON(data []byte) error {
+	var probe struct {
+		Role    string          `json:"role,omitempty"`
+		Content json.RawMessage `json:"content,omitempty"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return err
+	}
+	p.Role = probe.Role
+	p.Content = nil
+
+	content := bytes.TrimSpace(probe.Content)
+	if len(content) == 0 || bytes.Equal(content, []byte("null")) {
+		return nil
+	}
+	if content[0] == '"' {
+		var text string
+		if err := json.Unmarshal(content, &text); err != nil {
+			return fmt.Errorf("parse user content string: %w", err)
+		}
+		p.Content = []ContentBlock{{
+			Type: ContentBlockTypeText,
+			Text: &TextContentBlock{Type: ContentBlockTypeText, Text: text},
+		}}
+		return nil
+	}
+	return json.Unmarshal(content, &p.Content)
+}
+
 type ToolUseResult struct {
 	Filenames   []string `json:"filenames,omitempty"`
 	DurationMS  int64    `json:"durationMs,omitempty"`
@@ -160,7 +368,12 @@ type ToolUseResult struct {
 	Stderr      string   `json:"stderr,omitempty"`
 	Interrupted bool     `json:"interrupted,omitempty"`
 	IsImage     bool     `json:"isImage,omitempty"`
-	Text        string   `json:"-"`
+	// Type, FilePath and OriginalFile are reported by the Write and Edit
+	// tools; OriginalFile is the file content before the edit.
+	Type         string  `json:"type,omitempty"`
+	FilePath     string  `json:"filePath,omitempty"`
+	OriginalFile *string `json:"originalFile,omitempty"`
+	Text         string  `json:"-"`
 }
 
 func (r *ToolUseResult) UnmarshalJSON(data []byte) error {
@@ -438,6 +651,37 @@ type ToolResultContentBlock struct {
 	Type      ContentBlockType `json:"type"`
 	ToolUseID string           `json:"tool_use_id,omitempty"`
 	Content   json.RawMessage  `json:"content,omitempty"`
+	IsError   bool             `json:"is_error,omitempty"`
+}
+
+// Text returns the result's text: the content itself when it is a string,
+// or its text blocks joined by newlines.
+func (b *ToolResultContentBlock) Text() string {
+	content := bytes.TrimSpace(b.Content)
+	if len(content) == 0 {
+		return ""
+	}
+	if content[0] == '"' {
+		var text string
+		if err := json.Unmarshal(content, &text); err == nil {
+			return text
+		}
+		return ""
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &blocks); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, block := range blocks {
+		if block.Type == string(ContentBlockTypeText) {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
 }
 
 type Usage struct {
@@ -461,6 +705,82 @@ type ModelUsage struct {
 	MaxOutputTokens         int64   `json:"maxOutputTokens,omitempty"`
 }
 
+// KeepAliveMessage is sent periodically on idle streams and carries no
+// data.
+type KeepAliveMessage struct {
+	messageRaw
+	Type MessageType `json:"type"`
+}
+
+func (m *KeepAliveMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *KeepAliveMessage) UnmarshalJSON(data []byte) error {
+	type alias KeepAliveMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = KeepAliveMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
+// ToolProgressMessage reports that a long-running tool is still executing.
+type ToolProgressMessage struct {
+	messageRaw
+	Type               MessageType `json:"type"`
+	UUID               string      `json:"uuid,omitempty"`
+	SessionID          string      `json:"session_id,omitempty"`
+	ToolUseID          string      `json:"tool_use_id"`
+	ToolName           string      `json:"tool_name,omitempty"`
+	ParentToolUseID    *string     `json:"parent_tool_use_id"`
+	ElapsedTimeSeconds float64     `json:"elapsed_time_seconds,omitempty"`
+}
+
+func (m *ToolProgressMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *ToolProgressMessage) UnmarshalJSON(data []byte) error {
+	type alias ToolProgressMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = ToolProgressMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
+// AuthStatusMessage reports progress of an interactive authentication
+// flow started by the CLI.
+type AuthStatusMessage struct {
+	messageRaw
+	Type             MessageType `json:"type"`
+	UUID             string      `json:"uuid,omitempty"`
+	SessionID        string      `json:"session_id,omitempty"`
+	IsAuthenticating bool        `json:"isAuthenticating"`
+	Output           []string    `json:"output,omitempty"`
+	Error            string      `json:"error,omitempty"`
+}
+
+func (m *AuthStatusMessage) GetType() MessageType {
+	return m.Type
+}
+
+func (m *AuthStatusMessage) UnmarshalJSON(data []byte) error {
+	type alias AuthStatusMessage
+	var decoded alias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = AuthStatusMessage(decoded)
+	m.setRaw(data)
+	return nil
+}
+
 type UnknownMessage struct {
 	messageRaw
 	Type       MessageType `json:"type,omitempty"`
@@ -547,22 +867,117 @@ type UserInputContentBlock struct {
 	Text      string `json:"text,omitempty"`
 }
 
+const (
+	MCPProtocolVersion20241105 = "2024-11-05"
+	MCPProtocolVersion20250326 = "2025-03-26"
+	MCPProtocolVersion20250618 = "2025-06-18"
+
+	LatestMCPProtocolVersion = MCPProtocolVersion20250618
+)
+
+// SupportedMCPProtocolVersions lists the MCP revisions this package speaks,
+// newest first.
+var SupportedMCPProtocolVersions = []string{
+	MCPProtocolVersion20250618,
+	MCPProtocolVersion20250326,
+	MCPProtocolVersion20241105,
+}
+
+func IsSupportedMCPProtocolVersion(version string) bool {
+	for _, v := range SupportedMCPProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
 type InitializeParams struct {
-	ClientInfo   ClientInfo             `json:"clientInfo,omitempty"`
-	Capabilities map[string]interface{} `json:"capabilities,omitempty"`
+	ProtocolVersion string             `json:"protocolVersion,omitempty"`
+	ClientInfo      ClientInfo         `json:"clientInfo,omitempty"`
+	Capabilities    ClientCapabilities `json:"capabilities"`
+}
+
+type ClientCapabilities struct {
+	Roots        *RootsCapability           `json:"roots,omitempty"`
+	Sampling     *SamplingCapability        `json:"sampling,omitempty"`
+	Elicitation  *ElicitationCapability     `json:"elicitation,omitempty"`
+	Experimental map[string]json.RawMessage `json:"experimental,omitempty"`
+}
+
+type RootsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+type SamplingCapability struct{}
+
+type ElicitationCapability struct{}
+
 type ClientInfo struct {
 	Name    string `json:"name,omitempty"`
 	Version string `json:"version,omitempty"`
 }
 
 type InitializeResult struct {
-	ProtocolVersion string                 `json:"protocolVersion,omitempty"`
-	ServerInfo      ServerInfo             `json:"serverInfo,omitempty"`
-	Capabilities    map[string]interface{} `json:"capabilities,omitempty"`
+	ProtocolVersion string             `json:"protocolVersion,omitempty"`
+	ServerInfo      ServerInfo         `json:"serverInfo,omitempty"`
+	Capabilities    ServerCapabilities `json:"capabilities"`
+	Instructions    string             `json:"instructions,omitempty"`
 }
 
+type MCPCapability string
+
+const (
+	MCPCapabilityTools       MCPCapability = "tools"
+	MCPCapabilityResources   MCPCapability = "resources"
+	MCPCapabilityPrompts     MCPCapability = "prompts"
+	MCPCapabilityLogging     MCPCapability = "logging"
+	MCPCapabilityCompletions MCPCapability = "completions"
+)
+
+type ServerCapabilities struct {
+	Tools        *ToolsCapability           `json:"tools,omitempty"`
+	Resources    *ResourcesCapability       `json:"resources,omitempty"`
+	Prompts      *PromptsCapability         `json:"prompts,omitempty"`
+	Logging      *LoggingCapability         `json:"logging,omitempty"`
+	Completions  *CompletionsCapability     `json:"completions,omitempty"`
+	Experimental map[string]json.RawMessage `json:"experimental,omitempty"`
+}
+
+func (c ServerCapabilities) Supports(capability MCPCapability) bool {
+	switch capability {
+	case MCPCapabilityTools:
+		return c.Tools != nil
+	case MCPCapabilityResources:
+		return c.Resources != nil
+	case MCPCapabilityPrompts:
+		return c.Prompts != nil
+	case MCPCapabilityLogging:
+		return c.Logging != nil
+	case MCPCapabilityCompletions:
+		return c.Completions != nil
+	default:
+		return false
+	}
+}
+
+type ToolsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+type LoggingCapability struct{}
+
+type CompletionsCapability struct{}
+
 type ServerInfo struct {
 	Name    string `json:"name,omitempty"`
 	Version string `json:"version,omitempty"`
@@ -573,9 +988,10 @@ type ToolsListResult struct {
 }
 
 type ToolDefinition struct {
-	Name        string          `json:"name"`
-	Description string          `json:"description,omitempty"`
-	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description,omitempty"`
+	InputSchema  json.RawMessage `json:"inputSchema,omitempty"`
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
 }
 
 type ToolsCallParams struct {
@@ -584,11 +1000,41 @@ type ToolsCallParams struct {
 }
 
 type ToolsCallResult struct {
-	Content []ToolResultContent `json:"content,omitempty"`
-	IsError bool                `json:"isError,omitempty"`
+	Content           []ToolResultContent `json:"content,omitempty"`
+	StructuredContent json.RawMessage     `json:"structuredContent,omitempty"`
+	IsError           bool                `json:"isError,omitempty"`
 }
 
+type ToolResultContentType string
+
+const (
+	ToolResultContentTypeText         ToolResultContentType = "text"
+	ToolResultContentTypeImage        ToolResultContentType = "image"
+	ToolResultContentTypeAudio        ToolResultContentType = "audio"
+	ToolResultContentTypeResourceLink ToolResultContentType = "resource_link"
+	ToolResultContentTypeResource     ToolResultContentType = "resource"
+)
+
+// ToolResultContent is one item of an MCP tools/call result. Which fields
+// are set depends on Type: Text for text, Data and MIMEType for image and
+// audio, URI/Name/Description/MIMEType for resource_link, Resource for
+// embedded resources.
 type ToolResultContent struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+	Type        ToolResultContentType `json:"type"`
+	Text        string                `json:"text,omitempty"`
+	Data        string                `json:"data,omitempty"`
+	MIMEType    string                `json:"mimeType,omitempty"`
+	URI         string                `json:"uri,omitempty"`
+	Name        string                `json:"name,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Resource    *EmbeddedResource     `json:"resource,omitempty"`
+	Annotations json.RawMessage       `json:"annotations,omitempty"`
+}
+
+// EmbeddedResource carries either Text or base64 Blob contents.
+type EmbeddedResource struct {
+	URI      string `json:"uri"`
+	MIMEType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
 }